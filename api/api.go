@@ -72,6 +72,39 @@ func cacheFlusher() {
 	}
 }
 
+// Channel used to communicate with acquireDatabase goroutine,
+// nil when database is not opened/closed on demand (no-lock mode is off)
+var dbRequests chan dbRequest
+
+// Acquire database connection: reopens database if it was closed
+// and counts the client, does nothing if no-lock mode is not active
+func acquireDatabaseConnection() error {
+	if dbRequests == nil {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() { dbAcquireDurationSeconds.Observe(time.Since(start).Seconds()) }()
+
+	errCh := make(chan error)
+	dbRequests <- dbRequest{acquiredb, errCh}
+
+	return <-errCh
+}
+
+// Release database connection, when last client releases connection
+// database gets closed
+func releaseDatabaseConnection() error {
+	if dbRequests == nil {
+		return nil
+	}
+
+	errCh := make(chan error)
+	dbRequests <- dbRequest{releasedb, errCh}
+
+	return <-errCh
+}
+
 // Acquire database lock and release it when not needed anymore.
 //
 // Should be run in a goroutine!
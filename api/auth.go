@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Key in gin context which holds authenticated identity
+const identityKey = "identity"
+
+var errNoCredentials = errors.New("authentication required")
+
+// authEnabled checks whether any authentication method is configured
+func authEnabled(config *utils.APIAuthConfig) bool {
+	return len(config.Tokens) > 0 || len(config.Users) > 0
+}
+
+// authenticate returns identity of the client based on either bearer token
+// or HTTP basic auth credentials
+func authenticate(config *utils.APIAuthConfig, r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+
+	if strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+
+		for identity, expected := range config.Tokens {
+			if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+				return identity, nil
+			}
+		}
+
+		return "", errors.New("invalid bearer token")
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", errNoCredentials
+	}
+
+	hash, exists := config.Users[user]
+	if !exists || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return "", errors.New("invalid user name or password")
+	}
+
+	return user, nil
+}
+
+// matchResource checks whether resource (path relative to /api/) is covered by pattern,
+// pattern covers the resource itself and everything below it, each path segment
+// in pattern is a glob
+func matchResource(pattern, resource string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return true
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	resourceParts := strings.Split(strings.Trim(resource, "/"), "/")
+
+	if len(resourceParts) < len(patternParts) {
+		return false
+	}
+
+	for i := range patternParts {
+		matched, err := path.Match(patternParts[i], resourceParts[i])
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// authorize checks whether request with method on resource is allowed
+// by the list of permissions
+func authorize(permissions []utils.APIPermission, method, resource string) bool {
+	for _, permission := range permissions {
+		methodAllowed := false
+		for _, m := range permission.Methods {
+			if m == "*" || strings.EqualFold(m, method) {
+				methodAllowed = true
+				break
+			}
+		}
+
+		if !methodAllowed {
+			continue
+		}
+
+		for _, pattern := range permission.Resources {
+			if matchResource(pattern, resource) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// authMiddleware rejects requests with missing or invalid credentials (401)
+// and requests not allowed for authenticated identity (403)
+func authMiddleware(config *utils.APIAuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := authenticate(config, c.Request)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="aptly"`)
+			c.AbortWithError(401, err)
+			return
+		}
+
+		permissions, restricted := config.Permissions[identity]
+		if restricted {
+			resource := strings.TrimPrefix(c.Request.URL.Path, "/api/")
+
+			if !authorize(permissions, c.Request.Method, resource) {
+				c.AbortWithError(403, fmt.Errorf("%s is not allowed to %s %s", identity, c.Request.Method, c.Request.URL.Path))
+				return
+			}
+		}
+
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
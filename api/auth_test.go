@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aptly-dev/aptly/utils"
+	"golang.org/x/crypto/bcrypt"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type AuthSuite struct {
+	config utils.APIAuthConfig
+}
+
+var _ = Suite(&AuthSuite{})
+
+func (s *AuthSuite) SetUpTest(c *C) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	c.Assert(err, IsNil)
+
+	s.config = utils.APIAuthConfig{
+		Tokens: map[string]string{"ci": "t0ken"},
+		Users:  map[string]string{"alice": string(hash)},
+	}
+}
+
+func (s *AuthSuite) TestAuthEnabled(c *C) {
+	c.Check(authEnabled(&s.config), Equals, true)
+	c.Check(authEnabled(&utils.APIAuthConfig{}), Equals, false)
+}
+
+func (s *AuthSuite) TestAuthenticate(c *C) {
+	r, _ := http.NewRequest("GET", "/api/repos", nil)
+	_, err := authenticate(&s.config, r)
+	c.Check(err, Equals, errNoCredentials)
+
+	r.Header.Set("Authorization", "Bearer t0ken")
+	identity, err := authenticate(&s.config, r)
+	c.Check(err, IsNil)
+	c.Check(identity, Equals, "ci")
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	_, err = authenticate(&s.config, r)
+	c.Check(err, ErrorMatches, "invalid bearer token")
+
+	r.Header.Del("Authorization")
+	r.SetBasicAuth("alice", "secret")
+	identity, err = authenticate(&s.config, r)
+	c.Check(err, IsNil)
+	c.Check(identity, Equals, "alice")
+
+	r.SetBasicAuth("alice", "wrong")
+	_, err = authenticate(&s.config, r)
+	c.Check(err, ErrorMatches, "invalid user name or password")
+
+	r.SetBasicAuth("bob", "secret")
+	_, err = authenticate(&s.config, r)
+	c.Check(err, ErrorMatches, "invalid user name or password")
+}
+
+func (s *AuthSuite) TestMatchResource(c *C) {
+	c.Check(matchResource("", "repos/ci-1"), Equals, true)
+	c.Check(matchResource("*", "repos/ci-1"), Equals, true)
+	c.Check(matchResource("repos", "repos"), Equals, true)
+	c.Check(matchResource("repos", "repos/ci-1/packages"), Equals, true)
+	c.Check(matchResource("repos/ci-*", "repos/ci-1"), Equals, true)
+	c.Check(matchResource("repos/ci-*", "repos/ci-1/file/upload"), Equals, true)
+	c.Check(matchResource("/repos/ci-*/", "repos/ci-1"), Equals, true)
+	c.Check(matchResource("repos/ci-*", "repos/prod"), Equals, false)
+	c.Check(matchResource("repos/ci-*", "repos"), Equals, false)
+	c.Check(matchResource("repos", "snapshots"), Equals, false)
+	c.Check(matchResource("repos", "repos-foo"), Equals, false)
+}
+
+func (s *AuthSuite) TestAuthorize(c *C) {
+	readOnly := []utils.APIPermission{{Methods: []string{"GET"}, Resources: []string{"*"}}}
+	c.Check(authorize(readOnly, "GET", "publish"), Equals, true)
+	c.Check(authorize(readOnly, "DELETE", "publish/:./wheezy"), Equals, false)
+
+	ci := []utils.APIPermission{
+		{Methods: []string{"*"}, Resources: []string{"repos/ci-*", "files"}},
+		{Methods: []string{"get"}, Resources: []string{"tasks"}},
+	}
+	c.Check(authorize(ci, "POST", "repos/ci-main/file/upload"), Equals, true)
+	c.Check(authorize(ci, "DELETE", "files/upload"), Equals, true)
+	c.Check(authorize(ci, "GET", "tasks/1"), Equals, true)
+	c.Check(authorize(ci, "DELETE", "tasks/1"), Equals, false)
+	c.Check(authorize(ci, "POST", "repos/main/file/upload"), Equals, false)
+
+	c.Check(authorize(nil, "GET", "version"), Equals, false)
+}
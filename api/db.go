@@ -0,0 +1,87 @@
+package api
+
+import (
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/gin-gonic/gin"
+)
+
+// lockCollections locks all the collections in canonical order, returns function to unlock them
+func lockCollections(factory *deb.CollectionFactory) func() {
+	r := factory.RemoteRepoCollection()
+	r.Lock()
+	l := factory.LocalRepoCollection()
+	l.Lock()
+	s := factory.SnapshotCollection()
+	s.Lock()
+	p := factory.PublishedRepoCollection()
+	p.Lock()
+
+	// collections are captured, as they might be flushed while being locked
+	return func() {
+		p.Unlock()
+		s.Unlock()
+		l.Unlock()
+		r.Unlock()
+	}
+}
+
+// rlockCollections takes read locks on all the collections in canonical order, returns function to unlock them
+func rlockCollections(factory *deb.CollectionFactory) func() {
+	r := factory.RemoteRepoCollection()
+	r.RLock()
+	l := factory.LocalRepoCollection()
+	l.RLock()
+	s := factory.SnapshotCollection()
+	s.RLock()
+	p := factory.PublishedRepoCollection()
+	p.RLock()
+
+	return func() {
+		p.RUnlock()
+		s.RUnlock()
+		l.RUnlock()
+		r.RUnlock()
+	}
+}
+
+// GET /api/db/export
+func apiDbExport(c *gin.Context) {
+	factory := context.CollectionFactory()
+
+	unlock := lockCollections(factory)
+	defer unlock()
+
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", "attachment; filename=\"aptly-db.jsonl.gz\"")
+	c.Status(200)
+
+	// response is streamed, so error can't be reported with status code,
+	// dump without trailer would be rejected by import
+	if _, err := factory.Export(c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+// POST /api/db/import
+func apiDbImport(c *gin.Context) {
+	factory := context.CollectionFactory()
+
+	unlock := lockCollections(factory)
+	defer unlock()
+
+	stats, err := factory.Import(c.Request.Body)
+
+	// collections cache lists of objects, so they should be reloaded
+	factory.Flush()
+
+	if err != nil {
+		if err == deb.ErrDatabaseNotEmpty {
+			c.AbortWithError(409, err)
+		} else {
+			c.AbortWithError(400, err)
+		}
+		return
+	}
+
+	c.JSON(200, stats)
+}
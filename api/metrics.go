@@ -0,0 +1,287 @@
+package api
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	apiRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aptly_api_http_requests_total",
+			Help: "Total number of API requests by route, method and status code.",
+		},
+		[]string{"method", "path", "code"},
+	)
+	apiRequestDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "aptly_api_http_request_duration_seconds",
+			Help: "Duration of API requests by route, method and status code.",
+		},
+		[]string{"method", "path", "code"},
+	)
+	dbAcquireDurationSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name: "aptly_api_db_acquire_duration_seconds",
+			Help: "Time spent waiting to acquire database connection.",
+		},
+	)
+	publishDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aptly_publish_duration_seconds",
+			Help:    "Duration of publishing by published repository.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+		},
+		[]string{"prefix", "distribution"},
+	)
+
+	repoPackagesDesc = prometheus.NewDesc(
+		"aptly_repo_packages",
+		"Number of packages in local repository.",
+		[]string{"name"}, nil,
+	)
+	mirrorPackagesDesc = prometheus.NewDesc(
+		"aptly_mirror_packages",
+		"Number of packages in mirror.",
+		[]string{"name"}, nil,
+	)
+	snapshotPackagesDesc = prometheus.NewDesc(
+		"aptly_snapshot_packages",
+		"Number of packages in snapshot.",
+		[]string{"name"}, nil,
+	)
+)
+
+// collectionsMetricsTTL is how long package counts of repos and mirrors reported
+// by collectionsCollector are cached
+const collectionsMetricsTTL = time.Minute
+
+func init() {
+	prometheus.MustRegister(apiRequestsTotal, apiRequestDurationSeconds, dbAcquireDurationSeconds,
+		publishDurationSeconds, &collectionsCollector{})
+}
+
+// packageCount is number of packages in repo, mirror or snapshot
+type packageCount struct {
+	desc  *prometheus.Desc
+	name  string
+	count int
+}
+
+// cachedCount is number of packages loaded at some point in time
+type cachedCount struct {
+	count  int
+	loaded time.Time
+}
+
+// collectionsCollector reports number of packages in repos, mirrors and snapshots
+//
+// Counting packages requires loading complete package lists, so counts are cached by UUID:
+// counts of repos and mirrors are reloaded once they're older than collectionsMetricsTTL,
+// snapshots never change, so they're loaded only once
+type collectionsCollector struct {
+	sync.Mutex
+	cache map[string]cachedCount
+}
+
+// Describe implements prometheus.Collector
+func (collector *collectionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- repoPackagesDesc
+	ch <- mirrorPackagesDesc
+	ch <- snapshotPackagesDesc
+}
+
+// Collect implements prometheus.Collector
+func (collector *collectionsCollector) Collect(ch chan<- prometheus.Metric) {
+	if context == nil {
+		return
+	}
+
+	for _, count := range collector.packageCounts(context.CollectionFactory(), time.Now()) {
+		ch <- prometheus.MustNewConstMetric(count.desc, prometheus.GaugeValue, float64(count.count), count.name)
+	}
+}
+
+// packageCounts returns number of packages in repos, mirrors and snapshots using cached
+// counts when possible, each collection is locked for reading only while it's being walked
+func (collector *collectionsCollector) packageCounts(factory *deb.CollectionFactory, now time.Time) []packageCount {
+	collector.Lock()
+	defer collector.Unlock()
+
+	var counts []packageCount
+	cache := make(map[string]cachedCount)
+
+	count := func(uuid string, expires bool, load func() (int, error)) (int, bool) {
+		cached, ok := collector.cache[uuid]
+		if !ok || (expires && now.Sub(cached.loaded) >= collectionsMetricsTTL) {
+			n, err := load()
+			if err != nil {
+				return 0, false
+			}
+			cached = cachedCount{count: n, loaded: now}
+		}
+		cache[uuid] = cached
+		return cached.count, true
+	}
+
+	mirrorCollection := factory.RemoteRepoCollection()
+	mirrorCollection.RLock()
+	mirrorCollection.ForEach(func(repo *deb.RemoteRepo) error {
+		n, ok := count(repo.UUID, true, func() (int, error) {
+			err := mirrorCollection.LoadComplete(repo)
+			return repo.NumPackages(), err
+		})
+		if ok {
+			counts = append(counts, packageCount{mirrorPackagesDesc, repo.Name, n})
+		}
+		return nil
+	})
+	mirrorCollection.RUnlock()
+
+	localCollection := factory.LocalRepoCollection()
+	localCollection.RLock()
+	localCollection.ForEach(func(repo *deb.LocalRepo) error {
+		n, ok := count(repo.UUID, true, func() (int, error) {
+			err := localCollection.LoadComplete(repo)
+			return repo.NumPackages(), err
+		})
+		if ok {
+			counts = append(counts, packageCount{repoPackagesDesc, repo.Name, n})
+		}
+		return nil
+	})
+	localCollection.RUnlock()
+
+	snapshotCollection := factory.SnapshotCollection()
+	snapshotCollection.RLock()
+	snapshotCollection.ForEach(func(snapshot *deb.Snapshot) error {
+		n, ok := count(snapshot.UUID, false, func() (int, error) {
+			err := snapshotCollection.LoadComplete(snapshot)
+			return snapshot.NumPackages(), err
+		})
+		if ok {
+			counts = append(counts, packageCount{snapshotPackagesDesc, snapshot.Name, n})
+		}
+		return nil
+	})
+	snapshotCollection.RUnlock()
+
+	// objects which are gone are dropped from the cache
+	collector.cache = cache
+
+	return counts
+}
+
+// unmatchedRoute labels requests which don't match any route, so that
+// arbitrary paths don't produce new metrics series
+const unmatchedRoute = "<unmatched>"
+
+// routeTemplates keeps templates of registered routes by HTTP method
+type routeTemplates map[string][][]string
+
+// add remembers route template
+func (routes routeTemplates) add(method, template string) {
+	routes[method] = append(routes[method], strings.Split(template, "/"))
+}
+
+// match returns template of the route request path matches
+func (routes routeTemplates) match(method, requestPath string) string {
+	parts := strings.Split(requestPath, "/")
+
+	for _, template := range routes[method] {
+		if matchTemplate(template, parts) {
+			return strings.Join(template, "/")
+		}
+	}
+
+	return unmatchedRoute
+}
+
+// matchTemplate checks request path segments against route template segments
+func matchTemplate(template, parts []string) bool {
+	for i, segment := range template {
+		if strings.HasPrefix(segment, "*") {
+			return len(parts) >= i
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if parts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != parts[i] {
+			return false
+		}
+	}
+
+	return len(parts) == len(template)
+}
+
+// instrumentedGroup registers routes keeping their templates, so that metrics
+// are labeled by route and not by every repo or snapshot name
+type instrumentedGroup struct {
+	*gin.RouterGroup
+	routes routeTemplates
+}
+
+// Handle registers route and its template
+func (group instrumentedGroup) Handle(method, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	group.routes.add(method, path.Join(group.BasePath(), relativePath))
+	return group.RouterGroup.Handle(method, relativePath, handlers...)
+}
+
+// GET registers GET route
+func (group instrumentedGroup) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return group.Handle("GET", relativePath, handlers...)
+}
+
+// POST registers POST route
+func (group instrumentedGroup) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return group.Handle("POST", relativePath, handlers...)
+}
+
+// PUT registers PUT route
+func (group instrumentedGroup) PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return group.Handle("PUT", relativePath, handlers...)
+}
+
+// DELETE registers DELETE route
+func (group instrumentedGroup) DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return group.Handle("DELETE", relativePath, handlers...)
+}
+
+// instrumentHandler records request count & latency labeled by route template
+func instrumentHandler(routes routeTemplates) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		code := strconv.Itoa(c.Writer.Status())
+		route := routes.match(c.Request.Method, c.Request.URL.Path)
+
+		apiRequestsTotal.WithLabelValues(c.Request.Method, route, code).Inc()
+		apiRequestDurationSeconds.WithLabelValues(c.Request.Method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observePublish records duration of publishing since start
+func observePublish(published *deb.PublishedRepo, start time.Time) {
+	publishDurationSeconds.WithLabelValues(published.StoragePrefix(), published.Distribution).Observe(time.Since(start).Seconds())
+}
+
+// GET /api/metrics
+func apiMetricsGet() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/gin-gonic/gin"
+
+	. "gopkg.in/check.v1"
+)
+
+type MetricsSuite struct{}
+
+var _ = Suite(&MetricsSuite{})
+
+func (s *MetricsSuite) TestRouteTemplates(c *C) {
+	routes := routeTemplates{}
+	root := instrumentedGroup{RouterGroup: gin.New().Group("/api"), routes: routes}
+
+	handler := func(*gin.Context) {}
+	root.GET("/repos", handler)
+	root.GET("/repos/:name", handler)
+	root.GET("/repos/:name/packages", handler)
+	root.POST("/publish/:prefix/:distribution/rollback", handler)
+
+	c.Check(routes.match("GET", "/api/repos"), Equals, "/api/repos")
+	c.Check(routes.match("GET", "/api/repos/repos"), Equals, "/api/repos/:name")
+	c.Check(routes.match("GET", "/api/repos/packages/packages"), Equals, "/api/repos/:name/packages")
+	c.Check(routes.match("POST", "/api/publish/s3:repos/wheezy/rollback"), Equals, "/api/publish/:prefix/:distribution/rollback")
+
+	c.Check(routes.match("DELETE", "/api/repos/repos"), Equals, unmatchedRoute)
+	c.Check(routes.match("GET", "/api/repos/"), Equals, unmatchedRoute)
+	c.Check(routes.match("GET", "/api/no/such/route"), Equals, unmatchedRoute)
+}
+
+func (s *MetricsSuite) TestCollectionsCollectorCache(c *C) {
+	db, err := goleveldb.NewOpenDB(c.MkDir())
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	factory := deb.NewCollectionFactory(db)
+
+	list := deb.NewPackageList()
+	c.Assert(list.Add(deb.NewPackageFromControlFile(deb.Stanza{"Package": "app", "Version": "1.0", "Architecture": "i386"})), IsNil)
+
+	repo := deb.NewLocalRepo("local", "")
+	repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+	c.Assert(factory.LocalRepoCollection().Add(repo), IsNil)
+
+	snapshot, err := deb.NewSnapshotFromLocalRepo("snap", repo)
+	c.Assert(err, IsNil)
+	c.Assert(factory.SnapshotCollection().Add(snapshot), IsNil)
+
+	now := time.Now()
+	collector := &collectionsCollector{}
+	c.Check(collector.packageCounts(factory, now), DeepEquals, []packageCount{
+		{repoPackagesDesc, "local", 1},
+		{snapshotPackagesDesc, "snap", 1},
+	})
+
+	// new objects are counted right away, counts of existing ones are cached
+	repo.UpdateRefList(deb.NewPackageRefList())
+	c.Assert(factory.LocalRepoCollection().Update(repo), IsNil)
+	other := deb.NewLocalRepo("other", "")
+	c.Assert(factory.LocalRepoCollection().Add(other), IsNil)
+
+	c.Check(collector.packageCounts(factory, now.Add(time.Second)), HasLen, 3)
+	c.Check(collector.cache[repo.UUID].count, Equals, 1)
+	c.Check(collector.cache[other.UUID].count, Equals, 0)
+
+	// repos are reloaded once cached counts expire, snapshots never change
+	collector.cache[snapshot.UUID] = cachedCount{count: 42, loaded: now}
+
+	counts := collector.packageCounts(factory, now.Add(collectionsMetricsTTL))
+	c.Check(collector.cache[repo.UUID].count, Equals, 0)
+	c.Check(counts[len(counts)-1], DeepEquals, packageCount{snapshotPackagesDesc, "snap", 42})
+
+	c.Assert(factory.SnapshotCollection().Drop(snapshot), IsNil)
+	collector.packageCounts(factory, now)
+	_, cached := collector.cache[snapshot.UUID]
+	c.Check(cached, Equals, false)
+}
@@ -0,0 +1,779 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func getVerifier(ignoreSignatures bool, keyRings []string) (pgp.Verifier, error) {
+	if ignoreSignatures || context.Config().GpgDisableVerify {
+		return nil, nil
+	}
+
+	verifier := context.GetVerifier()
+	for _, keyRing := range keyRings {
+		verifier.AddKeyring(keyRing)
+	}
+
+	err := verifier.InitKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	return verifier, nil
+}
+
+// GET /api/mirrors
+func apiMirrorsList(c *gin.Context) {
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.RLock()
+	defer collection.RUnlock()
+
+	result := []*deb.RemoteRepo{}
+	collection.ForEach(func(repo *deb.RemoteRepo) error {
+		result = append(result, repo)
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	c.JSON(200, result)
+}
+
+// POST /api/mirrors
+func apiMirrorsCreate(c *gin.Context) {
+	var err error
+	var b struct {
+		Name                  string `binding:"required"`
+		ArchiveURL            string `binding:"required"`
+		FallbackURLs          []string
+		Distribution          string
+		Filter                string
+		Components            []string
+		Architectures         []string
+		Keyrings              []string
+		DownloadSources       bool
+		DownloadUdebs         bool
+		DownloadInstaller     bool
+		InstallerFlavours     []string
+		DownloadAppStream     bool
+		FilterWithDeps        bool
+		SkipComponentCheck    bool
+		SkipArchitectureCheck bool
+		IgnoreSignatures      bool
+		Retention             *deb.RetentionPolicy
+		DownloadSettings      *deb.DownloadSettings
+		Connection            *deb.ConnectionSettings
+	}
+
+	b.DownloadSources = context.Config().DownloadSourcePackages
+	b.Architectures = context.ArchitecturesList()
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.Lock()
+	defer collection.Unlock()
+
+	if strings.HasPrefix(b.ArchiveURL, "ppa:") {
+		b.ArchiveURL, b.Distribution, b.Components, err = deb.ParsePPA(b.ArchiveURL, context.Config())
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	if b.Filter != "" {
+		_, err = query.Parse(b.Filter)
+		if err != nil {
+			c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+	}
+
+	repo, err := deb.NewRemoteRepo(b.Name, b.ArchiveURL, b.Distribution, b.Components, b.Architectures,
+		b.DownloadSources, b.DownloadUdebs, b.DownloadInstaller)
+
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	err = repo.SetFallbackRoots(b.FallbackURLs)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	err = repo.CheckLocalRoots(context.Config().LocalArchiveRoots)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	err = deb.ValidateInstallerFlavours(b.InstallerFlavours)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+	repo.InstallerFlavours = b.InstallerFlavours
+	repo.DownloadAppStream = b.DownloadAppStream
+
+	repo.Filter = b.Filter
+	repo.FilterWithDeps = b.FilterWithDeps
+	repo.SkipComponentCheck = b.SkipComponentCheck
+	repo.SkipArchitectureCheck = b.SkipArchitectureCheck
+
+	if !b.Retention.IsEmpty() {
+		err = b.Retention.Validate()
+		if err != nil {
+			c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+		repo.Retention = b.Retention
+	}
+
+	if !b.DownloadSettings.IsEmpty() {
+		err = b.DownloadSettings.Validate()
+		if err != nil {
+			c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+		repo.DownloadSettings = b.DownloadSettings
+	}
+
+	if !b.Connection.IsEmpty() {
+		err = b.Connection.Validate()
+		if err != nil {
+			c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+		repo.Connection = b.Connection
+	}
+
+	verifier, err := getVerifier(b.IgnoreSignatures, b.Keyrings)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
+		return
+	}
+
+	downloader, err := context.MirrorDownloader(repo)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	err = repo.Fetch(downloader, verifier)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to fetch mirror: %s", err))
+		return
+	}
+
+	err = collection.Add(repo)
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to add mirror: %s", err))
+		return
+	}
+
+	c.JSON(201, repo)
+}
+
+// DELETE /api/mirrors/:name
+func apiMirrorsDrop(c *gin.Context) {
+	name := c.Params.ByName("name")
+	force := c.Request.URL.Query().Get("force") == "1"
+
+	mirrorCollection := context.CollectionFactory().RemoteRepoCollection()
+	mirrorCollection.Lock()
+	defer mirrorCollection.Unlock()
+
+	snapshotCollection := context.CollectionFactory().SnapshotCollection()
+	snapshotCollection.RLock()
+	defer snapshotCollection.RUnlock()
+
+	repo, err := mirrorCollection.ByName(name)
+	if err != nil {
+		c.AbortWithError(404, fmt.Errorf("unable to drop: %s", err))
+		return
+	}
+
+	err = repo.CheckLock()
+	if err != nil {
+		c.AbortWithError(409, fmt.Errorf("unable to drop: %s", err))
+		return
+	}
+
+	if !force {
+		snapshots := snapshotCollection.ByRemoteRepoSource(repo)
+
+		if len(snapshots) > 0 {
+			c.AbortWithError(409, fmt.Errorf("won't delete mirror with snapshots, use ?force=1 to override"))
+			return
+		}
+	}
+
+	err = mirrorCollection.Drop(repo)
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to drop: %s", err))
+		return
+	}
+
+	err = os.RemoveAll(repo.IndexCacheDir(context.IndexCachePath()))
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to drop: %s", err))
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// GET /api/mirrors/:name
+func apiMirrorsShow(c *gin.Context) {
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.RLock()
+	defer collection.RUnlock()
+
+	repo, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		c.AbortWithError(404, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(repo)
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	c.JSON(200, repo)
+}
+
+// GET /api/mirrors/:name/packages
+func apiMirrorsPackages(c *gin.Context) {
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.RLock()
+	defer collection.RUnlock()
+
+	repo, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		c.AbortWithError(404, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(repo)
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	if repo.LastDownloadDate.IsZero() {
+		c.AbortWithError(404, fmt.Errorf("unable to show package list, mirror hasn't been downloaded yet"))
+		return
+	}
+
+	showPackages(c, repo.RefList())
+}
+
+// PUT /api/mirrors/:name
+func apiMirrorsUpdate(c *gin.Context) {
+	var (
+		err    error
+		name   string
+		remote *deb.RemoteRepo
+	)
+
+	var b struct {
+		Name                 string
+		ArchiveURL           string
+		FallbackURLs         []string
+		Filter               *string
+		FilterWithDeps       *bool
+		DownloadSources      *bool
+		DownloadUdebs        *bool
+		DownloadInstaller    *bool
+		InstallerFlavours    []string
+		DownloadAppStream    *bool
+		SkipComponentCheck   *bool
+		Retention            *deb.RetentionPolicy
+		DownloadSettings     *deb.DownloadSettings
+		Connection           *deb.ConnectionSettings
+		ResetConnection      bool
+		IgnoreSignatures     bool
+		Keyrings             []string
+		ForceUpdate          bool
+		SkipExistingPackages bool
+		IgnoreChecksums      bool
+		DryRun               bool
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	verifier, err := getVerifier(b.IgnoreSignatures, b.Keyrings)
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
+		return
+	}
+
+	// apply settings first, collection should be released before actual update starts
+	code, err := func() (int, error) {
+		collection := context.CollectionFactory().RemoteRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
+
+		var stored *deb.RemoteRepo
+		stored, err = collection.ByName(c.Params.ByName("name"))
+		if err != nil {
+			return 404, fmt.Errorf("unable to update: %s", err)
+		}
+		name = stored.Name
+
+		// settings are applied to the copy of the mirror, which is saved only
+		// when update succeeds
+		remote = &deb.RemoteRepo{}
+		err = remote.Decode(stored.Encode())
+		if err != nil {
+			return 500, fmt.Errorf("unable to update: %s", err)
+		}
+
+		if !b.ForceUpdate {
+			err = remote.CheckLock()
+			if err != nil {
+				return 409, fmt.Errorf("unable to update: %s", err)
+			}
+		}
+
+		if b.Name != "" && b.Name != remote.Name {
+			_, err = collection.ByName(b.Name)
+			if err == nil {
+				return 409, fmt.Errorf("unable to rename: mirror %s already exists", b.Name)
+			}
+			remote.Name = b.Name
+		}
+
+		if b.ArchiveURL != "" {
+			remote.SetArchiveRoot(b.ArchiveURL)
+		}
+		if b.FallbackURLs != nil {
+			err = remote.SetFallbackRoots(b.FallbackURLs)
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+		}
+		err = remote.CheckLocalRoots(context.Config().LocalArchiveRoots)
+		if err != nil {
+			return 400, fmt.Errorf("unable to update: %s", err)
+		}
+		if b.Retention != nil {
+			err = b.Retention.Validate()
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+			remote.Retention = b.Retention
+			if remote.Retention.IsEmpty() {
+				remote.Retention = nil
+			}
+		}
+		if b.DownloadSettings != nil {
+			err = b.DownloadSettings.Validate()
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+			remote.DownloadSettings = b.DownloadSettings
+			if remote.DownloadSettings.IsEmpty() {
+				remote.DownloadSettings = nil
+			}
+		}
+		if b.ResetConnection {
+			remote.Connection = nil
+		}
+		if b.Connection != nil {
+			// settings are merged with stored ones, as credentials are hidden from GET output
+			var connection *deb.ConnectionSettings
+			connection, err = remote.Connection.Update(b.Connection)
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+			err = connection.Validate()
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+			remote.Connection = connection
+			if remote.Connection.IsEmpty() {
+				remote.Connection = nil
+			}
+		}
+		if b.Filter != nil {
+			remote.Filter = *b.Filter
+		}
+		if b.FilterWithDeps != nil {
+			remote.FilterWithDeps = *b.FilterWithDeps
+		}
+		if b.DownloadSources != nil {
+			remote.DownloadSources = *b.DownloadSources
+		}
+		if b.DownloadUdebs != nil {
+			remote.DownloadUdebs = *b.DownloadUdebs
+		}
+		if b.DownloadInstaller != nil {
+			remote.DownloadInstaller = *b.DownloadInstaller
+		}
+		if b.InstallerFlavours != nil {
+			err = deb.ValidateInstallerFlavours(b.InstallerFlavours)
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+			remote.InstallerFlavours = b.InstallerFlavours
+			if len(remote.InstallerFlavours) == 0 {
+				remote.InstallerFlavours = nil
+			}
+		}
+		if b.DownloadAppStream != nil {
+			remote.DownloadAppStream = *b.DownloadAppStream
+		}
+		if b.SkipComponentCheck != nil {
+			remote.SkipComponentCheck = *b.SkipComponentCheck
+		}
+
+		if remote.IsFlat() && remote.DownloadUdebs {
+			return 400, fmt.Errorf("unable to update: flat mirrors don't support udebs")
+		}
+
+		if remote.Filter != "" {
+			_, err = query.Parse(remote.Filter)
+			if err != nil {
+				return 400, fmt.Errorf("unable to update: %s", err)
+			}
+		}
+
+		return 200, nil
+	}()
+
+	if err != nil {
+		c.AbortWithError(code, err)
+		return
+	}
+
+	if b.DryRun {
+		maybeRunTaskInBackground(c, "Preview update of mirror "+name, func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+			return previewMirrorUpdate(out, remote, verifier, b.IgnoreChecksums, b.SkipExistingPackages)
+		})
+		return
+	}
+
+	maybeRunTaskInBackground(c, "Update mirror "+name, func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		return updateMirror(out, name, remote, verifier, b.ForceUpdate, b.IgnoreChecksums, b.SkipExistingPackages)
+	})
+}
+
+// Fetches Release file and package indexes of the mirror, applying the filter
+//
+// indexCachePath is empty when index cache shouldn't be updated (e.g. for dry run)
+func fetchMirrorIndexes(out aptly.Progress, remote *deb.RemoteRepo, downloader aptly.Downloader, verifier pgp.Verifier,
+	ignoreMismatch bool, indexCachePath string) (int, error) {
+	err := remote.Fetch(downloader, verifier)
+	if err != nil {
+		return 500, fmt.Errorf("unable to update: %s", err)
+	}
+
+	out.Printf("Downloading & parsing package files...\n")
+	err = remote.DownloadPackageIndexes(out, downloader, verifier, context.CollectionFactory(), ignoreMismatch, indexCachePath)
+	if err != nil {
+		return 500, fmt.Errorf("unable to update: %s", err)
+	}
+
+	if remote.Filter != "" {
+		var filterQuery deb.PackageQuery
+
+		filterQuery, err = query.Parse(remote.Filter)
+		if err != nil {
+			return 400, fmt.Errorf("unable to update: %s", err)
+		}
+
+		var oldLen, newLen int
+		oldLen, newLen, err = remote.ApplyFilter(context.DependencyOptions(), filterQuery, out)
+		if err != nil {
+			return 500, fmt.Errorf("unable to update: %s", err)
+		}
+		out.Printf("Packages filtered: %d -> %d.\n", oldLen, newLen)
+	}
+
+	return 200, nil
+}
+
+// Downloads and filters package indexes of the mirror, reporting changes update would bring
+//
+// remote is a copy of the mirror with requested settings applied, nothing is saved
+func previewMirrorUpdate(out aptly.Progress, remote *deb.RemoteRepo, verifier pgp.Verifier,
+	ignoreMismatch, skipExistingPackages bool) (*task.ProcessReturnValue, error) {
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.RLock()
+	err := collection.LoadComplete(remote)
+	collection.RUnlock()
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	downloader, err := context.MirrorDownloader(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	code, err := fetchMirrorIndexes(out, remote, downloader, verifier, ignoreMismatch, "")
+	if err != nil {
+		return &task.ProcessReturnValue{Code: code}, err
+	}
+
+	out.Printf("Building download queue...\n")
+	queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), context.CollectionFactory().PackageCollection(),
+		deb.ReadOnlyChecksumStorage{ChecksumStorage: context.CollectionFactory().ChecksumCollection(nil)}, skipExistingPackages)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	preview, err := remote.PreviewUpdate(context.CollectionFactory().PackageCollection(), queue, downloadSize)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	return &task.ProcessReturnValue{Code: 200, Value: preview}, nil
+}
+
+// Downloads package indexes and package files of the mirror, importing them into the pool
+//
+// edited is a copy of the mirror with new settings applied, settings are saved only
+// if update succeeds
+func updateMirror(out aptly.Progress, name string, edited *deb.RemoteRepo, verifier pgp.Verifier,
+	force, ignoreMismatch, skipExistingPackages bool) (*task.ProcessReturnValue, error) {
+	collection := context.CollectionFactory().RemoteRepoCollection()
+	collection.Lock()
+
+	stored, err := collection.ByName(name)
+	if err != nil {
+		collection.Unlock()
+		return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	if !force {
+		err = stored.CheckLock()
+		if err != nil {
+			collection.Unlock()
+			return &task.ProcessReturnValue{Code: 409}, fmt.Errorf("unable to update: %s", err)
+		}
+	}
+
+	remote := stored
+	if edited != nil {
+		if edited.UUID != stored.UUID {
+			collection.Unlock()
+			return &task.ProcessReturnValue{Code: 409}, fmt.Errorf("unable to update: mirror %s has been replaced", name)
+		}
+		if edited.Name != stored.Name {
+			_, err = collection.ByName(edited.Name)
+			if err == nil {
+				collection.Unlock()
+				return &task.ProcessReturnValue{Code: 409}, fmt.Errorf("unable to rename: mirror %s already exists", edited.Name)
+			}
+		}
+		remote = edited
+	}
+
+	err = collection.LoadComplete(remote)
+	if err != nil {
+		collection.Unlock()
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	// only updating mark is saved, new settings are kept aside until update succeeds
+	stored.MarkAsUpdating()
+	err = collection.Update(stored)
+	if err != nil {
+		stored.MarkAsIdle()
+		collection.Unlock()
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	// release the collection while fetching indexes and downloading, mirror is protected by updating mark
+	collection.Unlock()
+
+	defer func() {
+		// on any failure, unlock the mirror
+		collection.Lock()
+		defer collection.Unlock()
+
+		if stored.Status == deb.MirrorUpdating {
+			stored.MarkAsIdle()
+			collection.Update(stored)
+		}
+	}()
+
+	downloader, err := context.MirrorDownloader(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	code, err := fetchMirrorIndexes(out, remote, downloader, verifier, ignoreMismatch, context.IndexCachePath())
+	if err != nil {
+		return &task.ProcessReturnValue{Code: code}, err
+	}
+
+	out.Printf("Building download queue...\n")
+	queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), context.CollectionFactory().PackageCollection(),
+		context.CollectionFactory().ChecksumCollection(nil), skipExistingPackages)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	out.Printf("Download queue: %d items (%s)\n", len(queue), utils.HumanBytes(downloadSize))
+
+	downloadQueue := make(chan int)
+
+	var (
+		errors  []string
+		errLock sync.Mutex
+	)
+
+	pushError := func(err error) {
+		errLock.Lock()
+		errors = append(errors, err.Error())
+		errLock.Unlock()
+	}
+
+	go func() {
+		for idx := range queue {
+			downloadQueue <- idx
+		}
+		close(downloadQueue)
+	}()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < remote.DownloadSettings.GetConcurrency(context.Config().DownloadConcurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range downloadQueue {
+				dlTask := &queue[idx]
+
+				var e error
+
+				// provision download location
+				dlTask.TempDownPath, e = context.PackagePool().(aptly.LocalPackagePool).GenerateTempPath(dlTask.File.Filename)
+				if e != nil {
+					pushError(e)
+					continue
+				}
+
+				// download file, trying archive roots one by one...
+				for _, url := range dlTask.URLs {
+					if _, ok := http.LocalPath(url); ok {
+						// local archive: verify file in place, it would be imported into the pool from there
+						dlTask.LocalPath, e = http.VerifyLocalFile(url, context.Config().LocalArchiveRoots, &dlTask.File.Checksums, ignoreMismatch, downloader.GetProgress())
+					} else {
+						e = downloader.DownloadWithChecksum(
+							context,
+							url,
+							dlTask.TempDownPath,
+							&dlTask.File.Checksums,
+							ignoreMismatch)
+					}
+					if e == nil {
+						break
+					}
+				}
+				if e != nil {
+					pushError(e)
+					continue
+				}
+
+				dlTask.Done = true
+			}
+		}()
+	}
+
+	// Wait for all download goroutines to finish
+	wg.Wait()
+
+	if len(errors) == 0 {
+		if remote.DownloadAppStream {
+			out.Printf("Downloading AppStream metadata...\n")
+		}
+		err = remote.DownloadAppStreamFiles(downloader, context.PackagePool(), context.CollectionFactory().ChecksumCollection(nil), ignoreMismatch)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+		}
+	}
+
+	collection.Lock()
+	defer collection.Unlock()
+
+	// Import downloaded files
+	for idx := range queue {
+		dlTask := &queue[idx]
+
+		if !dlTask.Done {
+			// download not finished yet
+			continue
+		}
+
+		// and import it back to the pool
+		if dlTask.LocalPath != "" {
+			// file from local archive, keep original in place
+			dlTask.File.PoolPath, err = context.PackagePool().Import(dlTask.LocalPath, dlTask.File.Filename, &dlTask.File.Checksums, false, context.CollectionFactory().ChecksumCollection(nil))
+		} else {
+			dlTask.File.PoolPath, err = context.PackagePool().Import(dlTask.TempDownPath, dlTask.File.Filename, &dlTask.File.Checksums, true, context.CollectionFactory().ChecksumCollection(nil))
+		}
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to import file: %s", err)
+		}
+
+		// update "attached" files if any
+		for _, additionalTask := range dlTask.Additional {
+			additionalTask.File.PoolPath = dlTask.File.PoolPath
+			additionalTask.File.Checksums = dlTask.File.Checksums
+		}
+	}
+
+	if len(errors) > 0 {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
+	}
+
+	if remote.Name != stored.Name {
+		// mirror might have been created with the same name while collection was released
+		_, err = collection.ByName(remote.Name)
+		if err == nil {
+			return &task.ProcessReturnValue{Code: 409}, fmt.Errorf("unable to rename: mirror %s already exists", remote.Name)
+		}
+	}
+
+	err = remote.FinalizeDownload(context.CollectionFactory(), out)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	stored.MarkAsIdle()
+	remote.MarkAsIdle()
+	err = collection.Update(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	out.Printf("\nMirror `%s` has been successfully updated.\n", remote.Name)
+
+	return &task.ProcessReturnValue{Code: 200, Value: remote}, nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/pool/verify
+func apiPoolVerify(c *gin.Context) {
+	var b struct {
+		Quick  bool
+		Repair bool
+	}
+
+	// body is optional
+	if c.Request.ContentLength != 0 && c.Bind(&b) != nil {
+		return
+	}
+
+	maybeRunTaskInBackground(c, "Verify package pool", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		factory := context.CollectionFactory()
+
+		// packages are verified with read locks taken, collections are locked
+		// exclusively only while repaired files are imported into the pool
+		options := deb.PoolVerifyOptions{
+			Quick:  b.Quick,
+			Repair: b.Repair,
+			LockCollections: func(exclusive bool) func() {
+				if exclusive {
+					return lockCollections(factory)
+				}
+				return rlockCollections(factory)
+			},
+		}
+
+		report, err := deb.VerifyPool(factory, context.PackagePool(), options, context.MirrorDownloader, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to verify: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: 200, Value: report}, nil
+	})
+}
@@ -3,9 +3,13 @@ package api
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -84,6 +88,34 @@ func apiPublishList(c *gin.Context) {
 	c.JSON(200, result)
 }
 
+// parseValidFor parses validity period of Release file
+func parseValidFor(validFor string) (time.Duration, error) {
+	duration, err := time.ParseDuration(validFor)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse ValidFor: %s", err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("ValidFor should not be negative")
+	}
+	return duration, nil
+}
+
+// publishTarget is additional published repository sharing index files with the main one
+type publishTarget struct {
+	// [<endpoint>:]<prefix>, defaults to storage & prefix of the main published repository
+	Prefix       string
+	Distribution string `binding:"required"`
+}
+
+// storagePrefix returns storage & prefix of the target
+func (target *publishTarget) storagePrefix(published *deb.PublishedRepo) (storage, prefix string) {
+	if target.Prefix == "" {
+		return published.Storage, published.Prefix
+	}
+
+	return deb.ParsePrefix(target.Prefix)
+}
+
 // POST /publish/:prefix
 func apiPublishRepoOrSnapshot(c *gin.Context) {
 	param := parseEscapedPath(c.Params.ByName("prefix"))
@@ -102,9 +134,16 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 		ButAutomaticUpgrades string
 		ForceOverwrite       bool
 		SkipContents         *bool
+		Translations         *bool
 		Architectures        []string
 		Signing              SigningOptions
 		AcquireByHash        *bool
+		Compression          []string
+		ValidFor             *string
+		SignedBy             *string
+		Atomic               *bool
+		KeepGenerations      *int
+		Targets              []publishTarget
 	}
 
 	if c.Bind(&b) != nil {
@@ -117,115 +156,174 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 		return
 	}
 
+	var validFor time.Duration
+	if b.ValidFor != nil {
+		validFor, err = parseValidFor(*b.ValidFor)
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	if b.KeepGenerations != nil && *b.KeepGenerations < 0 {
+		c.AbortWithError(400, fmt.Errorf("KeepGenerations should not be negative"))
+		return
+	}
+
 	if len(b.Sources) == 0 {
 		c.AbortWithError(400, fmt.Errorf("unable to publish: soures are empty"))
 		return
 	}
 
-	var components []string
-	var sources []interface{}
+	maybeRunTaskInBackground(c, "Publish "+b.SourceKind+" to "+param, func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		var components []string
+		var sources []interface{}
 
-	if b.SourceKind == "snapshot" {
-		var snapshot *deb.Snapshot
+		if b.SourceKind == "snapshot" {
+			var snapshot *deb.Snapshot
 
-		snapshotCollection := context.CollectionFactory().SnapshotCollection()
-		snapshotCollection.Lock()
-		defer snapshotCollection.Unlock()
+			snapshotCollection := context.CollectionFactory().SnapshotCollection()
+			snapshotCollection.Lock()
+			defer snapshotCollection.Unlock()
 
-		for _, source := range b.Sources {
-			components = append(components, source.Component)
+			for _, source := range b.Sources {
+				components = append(components, source.Component)
 
-			snapshot, err = snapshotCollection.ByName(source.Name)
-			if err != nil {
-				c.AbortWithError(404, fmt.Errorf("unable to publish: %s", err))
-				return
+				snapshot, err = snapshotCollection.ByName(source.Name)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to publish: %s", err)
+				}
+
+				err = snapshotCollection.LoadComplete(snapshot)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
+				}
+
+				sources = append(sources, snapshot)
 			}
+		} else if b.SourceKind == deb.SourceLocalRepo {
+			var localRepo *deb.LocalRepo
+
+			localCollection := context.CollectionFactory().LocalRepoCollection()
+			localCollection.Lock()
+			defer localCollection.Unlock()
+
+			for _, source := range b.Sources {
+				components = append(components, source.Component)
+
+				localRepo, err = localCollection.ByName(source.Name)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to publish: %s", err)
+				}
+
+				err = localCollection.LoadComplete(localRepo)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
+				}
+
+				sources = append(sources, localRepo)
+			}
+		} else {
+			return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unknown SourceKind")
+		}
+
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
 
-			err = snapshotCollection.LoadComplete(snapshot)
+		newPublished := func(storage, prefix, distribution string) (*deb.PublishedRepo, *task.ProcessReturnValue, error) {
+			published, err := deb.NewPublishedRepo(storage, prefix, distribution, b.Architectures, components, sources, context.CollectionFactory())
 			if err != nil {
-				c.AbortWithError(500, fmt.Errorf("unable to publish: %s", err))
-				return
+				return nil, &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
 			}
+			if b.Origin != "" {
+				published.Origin = b.Origin
+			}
+			if b.NotAutomatic != "" {
+				published.NotAutomatic = b.NotAutomatic
+			}
+			if b.ButAutomaticUpgrades != "" {
+				published.ButAutomaticUpgrades = b.ButAutomaticUpgrades
+			}
+			published.Label = b.Label
 
-			sources = append(sources, snapshot)
-		}
-	} else if b.SourceKind == deb.SourceLocalRepo {
-		var localRepo *deb.LocalRepo
+			published.SkipContents = context.Config().SkipContentsPublishing
+			published.Compression = context.Config().PublishCompression
+			if b.SkipContents != nil {
+				published.SkipContents = *b.SkipContents
+			}
 
-		localCollection := context.CollectionFactory().LocalRepoCollection()
-		localCollection.Lock()
-		defer localCollection.Unlock()
+			if b.AcquireByHash != nil {
+				published.AcquireByHash = *b.AcquireByHash
+			}
 
-		for _, source := range b.Sources {
-			components = append(components, source.Component)
+			if b.Translations != nil {
+				published.Translations = *b.Translations
+			}
 
-			localRepo, err = localCollection.ByName(source.Name)
-			if err != nil {
-				c.AbortWithError(404, fmt.Errorf("unable to publish: %s", err))
-				return
+			if b.Compression != nil {
+				published.Compression = b.Compression
 			}
 
-			err = localCollection.LoadComplete(localRepo)
-			if err != nil {
-				c.AbortWithError(500, fmt.Errorf("unable to publish: %s", err))
+			if b.ValidFor != nil {
+				published.ValidFor = validFor
 			}
 
-			sources = append(sources, localRepo)
-		}
-	} else {
-		c.AbortWithError(400, fmt.Errorf("unknown SourceKind"))
-		return
-	}
+			if b.SignedBy != nil {
+				published.SignedBy = *b.SignedBy
+			}
 
-	collection := context.CollectionFactory().PublishedRepoCollection()
-	collection.Lock()
-	defer collection.Unlock()
+			if b.Atomic != nil {
+				published.Atomic = *b.Atomic
+				published.KeepGenerations = deb.DefaultKeepGenerations
+			}
 
-	published, err := deb.NewPublishedRepo(storage, prefix, b.Distribution, b.Architectures, components, sources, context.CollectionFactory())
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to publish: %s", err))
-		return
-	}
-	if b.Origin != "" {
-		published.Origin = b.Origin
-	}
-	if b.NotAutomatic != "" {
-		published.NotAutomatic = b.NotAutomatic
-	}
-	if b.ButAutomaticUpgrades != "" {
-		published.ButAutomaticUpgrades = b.ButAutomaticUpgrades
-	}
-	published.Label = b.Label
+			if b.KeepGenerations != nil {
+				published.KeepGenerations = *b.KeepGenerations
+			}
 
-	published.SkipContents = context.Config().SkipContentsPublishing
-	if b.SkipContents != nil {
-		published.SkipContents = *b.SkipContents
-	}
+			duplicate := collection.CheckDuplicate(published)
+			if duplicate != nil {
+				context.CollectionFactory().PublishedRepoCollection().LoadComplete(duplicate, context.CollectionFactory())
+				return nil, &task.ProcessReturnValue{Code: 400}, fmt.Errorf("prefix/distribution already used by another published repo: %s", duplicate)
+			}
 
-	if b.AcquireByHash != nil {
-		published.AcquireByHash = *b.AcquireByHash
-	}
+			return published, nil, nil
+		}
 
-	duplicate := collection.CheckDuplicate(published)
-	if duplicate != nil {
-		context.CollectionFactory().PublishedRepoCollection().LoadComplete(duplicate, context.CollectionFactory())
-		c.AbortWithError(400, fmt.Errorf("prefix/distribution already used by another published repo: %s", duplicate))
-		return
-	}
+		published, retValue, err := newPublished(storage, prefix, b.Distribution)
+		if err != nil {
+			return retValue, err
+		}
 
-	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, nil, b.ForceOverwrite)
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to publish: %s", err))
-		return
-	}
+		repos := []*deb.PublishedRepo{published}
+		for _, target := range b.Targets {
+			targetStorage, targetPrefix := target.storagePrefix(published)
 
-	err = collection.Add(published)
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to save to DB: %s", err))
-		return
-	}
+			var alias *deb.PublishedRepo
+			alias, retValue, err = newPublished(targetStorage, targetPrefix, target.Distribution)
+			if err != nil {
+				return retValue, err
+			}
+			repos = append(repos, alias)
+		}
+
+		publishStart := time.Now()
+		err = deb.PublishBatch(repos, context.PackagePool(), context, context.CollectionFactory(), signer, out, b.ForceOverwrite)
+		for _, repo := range repos {
+			observePublish(repo, publishStart)
+		}
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = collection.UpdateBatch(repos)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to save to DB: %s", err)
+		}
 
-	c.JSON(201, published)
+		return &task.ProcessReturnValue{Code: 201, Value: published}, nil
+	})
 }
 
 // PUT /publish/:prefix/:distribution
@@ -238,12 +336,24 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 		ForceOverwrite bool
 		Signing        SigningOptions
 		SkipContents   *bool
+		Translations   *bool
 		SkipCleanup    *bool
 		Snapshots      []struct {
 			Component string `binding:"required"`
 			Name      string `binding:"required"`
 		}
-		AcquireByHash *bool
+		AcquireByHash   *bool
+		Compression     []string
+		ValidFor        *string
+		SignedBy        *string
+		Atomic          *bool
+		KeepGenerations *int
+		Targets         []publishTarget
+		PhasedUpdates   []struct {
+			Query      string `binding:"required"`
+			Percentage *int   `binding:"required"`
+		}
+		DryRun bool
 	}
 
 	if c.Bind(&b) != nil {
@@ -256,99 +366,423 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 		return
 	}
 
-	// published.LoadComplete would touch local repo collection
-	localRepoCollection := context.CollectionFactory().LocalRepoCollection()
-	localRepoCollection.Lock()
-	defer localRepoCollection.Unlock()
-
-	snapshotCollection := context.CollectionFactory().SnapshotCollection()
-	snapshotCollection.Lock()
-	defer snapshotCollection.Unlock()
-
-	collection := context.CollectionFactory().PublishedRepoCollection()
-	collection.Lock()
-	defer collection.Unlock()
+	var validFor time.Duration
+	if b.ValidFor != nil {
+		validFor, err = parseValidFor(*b.ValidFor)
+		if err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
 
-	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
-	if err != nil {
-		c.AbortWithError(404, fmt.Errorf("unable to update: %s", err))
+	if b.KeepGenerations != nil && *b.KeepGenerations < 0 {
+		c.AbortWithError(400, fmt.Errorf("KeepGenerations should not be negative"))
 		return
 	}
-	err = collection.LoadComplete(published, context.CollectionFactory())
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to update: %s", err))
-		return
+
+	phaseQueries := make([]deb.PackageQuery, len(b.PhasedUpdates))
+	for i, phase := range b.PhasedUpdates {
+		phaseQueries[i], err = query.Parse(phase.Query)
+		if err != nil {
+			c.AbortWithError(400, fmt.Errorf("unable to update: %s", err))
+			return
+		}
 	}
 
-	var updatedComponents []string
+	maybeRunTaskInBackground(c, "Update published "+param+" ("+distribution+")", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		// published.LoadComplete would touch local repo collection
+		localRepoCollection := context.CollectionFactory().LocalRepoCollection()
+		localRepoCollection.Lock()
+		defer localRepoCollection.Unlock()
 
-	if published.SourceKind == deb.SourceLocalRepo {
-		if len(b.Snapshots) > 0 {
-			c.AbortWithError(400, fmt.Errorf("snapshots shouldn't be given when updating local repo"))
-			return
+		snapshotCollection := context.CollectionFactory().SnapshotCollection()
+		snapshotCollection.Lock()
+		defer snapshotCollection.Unlock()
+
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
+
+		published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to update: %s", err)
 		}
-		updatedComponents = published.Components()
-		for _, component := range updatedComponents {
-			published.UpdateLocalRepo(component)
+		err = collection.LoadComplete(published, context.CollectionFactory())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
 		}
-	} else if published.SourceKind == "snapshot" {
-		publishedComponents := published.Components()
-		for _, snapshotInfo := range b.Snapshots {
-			if !utils.StrSliceHasItem(publishedComponents, snapshotInfo.Component) {
-				c.AbortWithError(404, fmt.Errorf("component %s is not in published repository", snapshotInfo.Component))
-				return
+
+		repos := []*deb.PublishedRepo{published}
+		for _, target := range b.Targets {
+			targetStorage, targetPrefix := target.storagePrefix(published)
+
+			alias, err2 := collection.ByStoragePrefixDistribution(targetStorage, targetPrefix, target.Distribution)
+			if err2 != nil {
+				return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to update: %s", err2)
+			}
+			if alias.SourceKind != published.SourceKind {
+				return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to update: published repository %s/%s has different source kind",
+					alias.StoragePrefix(), alias.Distribution)
 			}
 
-			snapshot, err2 := snapshotCollection.ByName(snapshotInfo.Name)
+			err2 = collection.LoadComplete(alias, context.CollectionFactory())
 			if err2 != nil {
-				c.AbortWithError(404, err2)
-				return
+				return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err2)
+			}
+			repos = append(repos, alias)
+		}
+
+		var updatedComponents []string
+		// with DryRun, new package lists are only previewed
+		refLists := map[string]*deb.PackageRefList{}
+
+		if published.SourceKind == deb.SourceLocalRepo {
+			if len(b.Snapshots) > 0 {
+				return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("snapshots shouldn't be given when updating local repo")
+			}
+			updatedComponents = published.Components()
+			if b.DryRun {
+				for _, component := range updatedComponents {
+					localRepo, err2 := localRepoCollection.ByUUID(published.Sources[component])
+					if err2 != nil {
+						return &task.ProcessReturnValue{Code: 404}, err2
+					}
+
+					err2 = localRepoCollection.LoadComplete(localRepo)
+					if err2 != nil {
+						return &task.ProcessReturnValue{Code: 500}, err2
+					}
+
+					refLists[component] = localRepo.RefList()
+				}
+			} else {
+				for _, repo := range repos {
+					for _, component := range repo.Components() {
+						repo.UpdateLocalRepo(component)
+					}
+				}
 			}
+		} else if published.SourceKind == "snapshot" {
+			publishedComponents := published.Components()
+			for _, snapshotInfo := range b.Snapshots {
+				if !utils.StrSliceHasItem(publishedComponents, snapshotInfo.Component) {
+					return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("component %s is not in published repository", snapshotInfo.Component)
+				}
+
+				snapshot, err2 := snapshotCollection.ByName(snapshotInfo.Name)
+				if err2 != nil {
+					return &task.ProcessReturnValue{Code: 404}, err2
+				}
+
+				err2 = snapshotCollection.LoadComplete(snapshot)
+				if err2 != nil {
+					return &task.ProcessReturnValue{Code: 500}, err2
+				}
+
+				if b.DryRun {
+					refLists[snapshotInfo.Component] = snapshot.RefList()
+				} else {
+					for _, repo := range repos {
+						repo.UpdateSnapshot(snapshotInfo.Component, snapshot)
+					}
+				}
+				updatedComponents = append(updatedComponents, snapshotInfo.Component)
+			}
+		} else {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unknown published repository type")
+		}
 
-			err2 = snapshotCollection.LoadComplete(snapshot)
+		if b.DryRun {
+			diffs, err2 := collection.PreviewSources(repos, refLists, context, context.CollectionFactory(), out)
 			if err2 != nil {
-				c.AbortWithError(500, err2)
-				return
+				return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to update: %s", err2)
 			}
 
-			published.UpdateSnapshot(snapshotInfo.Component, snapshot)
-			updatedComponents = append(updatedComponents, snapshotInfo.Component)
+			return &task.ProcessReturnValue{Code: 200, Value: diffs}, nil
 		}
-	} else {
-		c.AbortWithError(500, fmt.Errorf("unknown published repository type"))
+
+		for _, published := range repos {
+			if b.SkipContents != nil {
+				published.SkipContents = *b.SkipContents
+			}
+
+			if b.AcquireByHash != nil {
+				published.AcquireByHash = *b.AcquireByHash
+			}
+
+			if b.Translations != nil {
+				published.Translations = *b.Translations
+			}
+
+			if b.Compression != nil {
+				published.Compression = b.Compression
+			}
+
+			if b.ValidFor != nil {
+				published.ValidFor = validFor
+			}
+
+			if b.SignedBy != nil {
+				published.SignedBy = *b.SignedBy
+			}
+
+			if b.Atomic != nil {
+				if !*b.Atomic && published.Atomic {
+					return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to update: atomic publishing can't be disabled once enabled")
+				}
+				if *b.Atomic && !published.Atomic && b.KeepGenerations == nil {
+					published.KeepGenerations = deb.DefaultKeepGenerations
+				}
+				published.Atomic = *b.Atomic
+			}
+
+			if b.KeepGenerations != nil {
+				published.KeepGenerations = *b.KeepGenerations
+			}
+		}
+
+		// new versions are phased before new indexes are published
+		for i, phase := range b.PhasedUpdates {
+			for _, published := range repos {
+				_, err = published.SetPhasedUpdate(phaseQueries[i], *phase.Percentage, context.CollectionFactory().PackageCollection(), out)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to update: %s: %s", published.String(), err)
+				}
+			}
+		}
+
+		publishStart := time.Now()
+		err = deb.PublishBatch(repos, context.PackagePool(), context, context.CollectionFactory(), signer, out, b.ForceOverwrite)
+		for _, repo := range repos {
+			observePublish(repo, publishStart)
+		}
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+		}
+
+		err = collection.UpdateBatch(repos)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to save to DB: %s", err)
+		}
+
+		if b.SkipCleanup == nil || !*b.SkipCleanup {
+			cleaned := map[string]bool{}
+			for _, repo := range repos {
+				if cleaned[repo.StoragePrefix()] {
+					continue
+				}
+				cleaned[repo.StoragePrefix()] = true
+
+				err = collection.CleanupPrefixComponentFiles(repo.Prefix, updatedComponents,
+					context.GetPublishedStorage(repo.Storage), context.CollectionFactory(), out)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to update: %s", err)
+				}
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: 200, Value: published}, nil
+	})
+}
+
+// POST /publish/:prefix/:distribution/rollback
+func apiPublishRollback(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	var b struct {
+		SkipCleanup bool
+	}
+
+	// body is optional
+	if c.Request.ContentLength != 0 && c.Bind(&b) != nil {
 		return
 	}
 
-	if b.SkipContents != nil {
-		published.SkipContents = *b.SkipContents
+	maybeRunTaskInBackground(c, "Roll back published "+param+" ("+distribution+")", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		// published.LoadComplete would touch local repo collection
+		localRepoCollection := context.CollectionFactory().LocalRepoCollection()
+		localRepoCollection.RLock()
+		defer localRepoCollection.RUnlock()
+
+		snapshotCollection := context.CollectionFactory().SnapshotCollection()
+		snapshotCollection.RLock()
+		defer snapshotCollection.RUnlock()
+
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
+
+		published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to roll back: %s", err)
+		}
+
+		err = collection.LoadComplete(published, context.CollectionFactory())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to roll back: %s", err)
+		}
+
+		// components published by the dropped generation might be gone after rollback
+		components := published.GenerationComponents()
+
+		err = collection.Rollback(context, published, context.CollectionFactory(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to roll back: %s", err)
+		}
+
+		if !b.SkipCleanup {
+			err = collection.CleanupPrefixComponentFiles(published.Prefix, components,
+				context.GetPublishedStorage(published.Storage), context.CollectionFactory(), out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to roll back: %s", err)
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: 200, Value: published}, nil
+	})
+}
+
+// POST /publish/:prefix/:distribution/phase
+func apiPublishPhase(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	var b struct {
+		Query          string `binding:"required"`
+		Percentage     *int   `binding:"required"`
+		ForceOverwrite bool
+		Signing        SigningOptions
 	}
 
-	if b.AcquireByHash != nil {
-		published.AcquireByHash = *b.AcquireByHash
+	if c.Bind(&b) != nil {
+		return
 	}
 
-	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, nil, b.ForceOverwrite)
+	q, err := query.Parse(b.Query)
 	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to update: %s", err))
+		c.AbortWithError(400, fmt.Errorf("unable to phase: %s", err))
 		return
 	}
 
-	err = collection.Update(published)
+	signer, err := getSigner(&b.Signing)
 	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to save to DB: %s", err))
+		c.AbortWithError(500, fmt.Errorf("unable to initialize GPG signer: %s", err))
 		return
 	}
 
-	if b.SkipCleanup == nil || !*b.SkipCleanup {
-		err = collection.CleanupPrefixComponentFiles(published.Prefix, updatedComponents,
-			context.GetPublishedStorage(storage), context.CollectionFactory(), nil)
+	maybeRunTaskInBackground(c, "Phase published "+param+" ("+distribution+")", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		// published.LoadComplete would touch local repo collection
+		localRepoCollection := context.CollectionFactory().LocalRepoCollection()
+		localRepoCollection.RLock()
+		defer localRepoCollection.RUnlock()
+
+		snapshotCollection := context.CollectionFactory().SnapshotCollection()
+		snapshotCollection.RLock()
+		defer snapshotCollection.RUnlock()
+
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
+
+		published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
 		if err != nil {
-			c.AbortWithError(500, fmt.Errorf("unable to update: %s", err))
-			return
+			return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to phase: %s", err)
+		}
+		err = collection.LoadComplete(published, context.CollectionFactory())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to phase: %s", err)
+		}
+
+		_, err = published.SetPhasedUpdate(q, *b.Percentage, context.CollectionFactory().PackageCollection(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to phase: %s", err)
+		}
+
+		publishStart := time.Now()
+		err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, out, b.ForceOverwrite)
+		observePublish(published, publishStart)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
 		}
+
+		err = collection.Update(published)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to save to DB: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: 200, Value: published}, nil
+	})
+}
+
+// POST /publish/:prefix/:distribution/overrides
+func apiPublishOverrides(c *gin.Context) {
+	param := parseEscapedPath(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := c.Params.ByName("distribution")
+
+	var b struct {
+		Component      string `binding:"required"`
+		Source         bool
+		Overrides      map[string]deb.PackageOverride
+		ForceOverwrite bool
+		Signing        SigningOptions
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	signer, err := getSigner(&b.Signing)
+	if err != nil {
+		c.AbortWithError(500, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
 	}
 
-	c.JSON(200, published)
+	maybeRunTaskInBackground(c, "Override published "+param+" ("+distribution+")", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		// published.LoadComplete would touch local repo collection
+		localRepoCollection := context.CollectionFactory().LocalRepoCollection()
+		localRepoCollection.RLock()
+		defer localRepoCollection.RUnlock()
+
+		snapshotCollection := context.CollectionFactory().SnapshotCollection()
+		snapshotCollection.RLock()
+		defer snapshotCollection.RUnlock()
+
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
+
+		published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 404}, fmt.Errorf("unable to override: %s", err)
+		}
+		err = collection.LoadComplete(published, context.CollectionFactory())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to override: %s", err)
+		}
+
+		err = published.SetOverrides(b.Component, b.Source, b.Overrides)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 400}, fmt.Errorf("unable to override: %s", err)
+		}
+
+		publishStart := time.Now()
+		err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, out, b.ForceOverwrite)
+		observePublish(published, publishStart)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = collection.Update(published)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to save to DB: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: 200, Value: published}, nil
+	})
 }
 
 // DELETE /publish/:prefix/:distribution
@@ -360,21 +794,22 @@ func apiPublishDrop(c *gin.Context) {
 	storage, prefix := deb.ParsePrefix(param)
 	distribution := c.Params.ByName("distribution")
 
-	// published.LoadComplete would touch local repo collection
-	localRepoCollection := context.CollectionFactory().LocalRepoCollection()
-	localRepoCollection.Lock()
-	defer localRepoCollection.Unlock()
+	maybeRunTaskInBackground(c, "Delete published "+param+" ("+distribution+")", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		// published.LoadComplete would touch local repo collection
+		localRepoCollection := context.CollectionFactory().LocalRepoCollection()
+		localRepoCollection.Lock()
+		defer localRepoCollection.Unlock()
 
-	collection := context.CollectionFactory().PublishedRepoCollection()
-	collection.Lock()
-	defer collection.Unlock()
+		collection := context.CollectionFactory().PublishedRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
 
-	err := collection.Remove(context, storage, prefix, distribution,
-		context.CollectionFactory(), context.Progress(), force, skipCleanup)
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to drop: %s", err))
-		return
-	}
+		err := collection.Remove(context, storage, prefix, distribution,
+			context.CollectionFactory(), out, force, skipCleanup)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to drop: %s", err)
+		}
 
-	c.JSON(200, gin.H{})
+		return &task.ProcessReturnValue{Code: 200, Value: gin.H{}}, nil
+	})
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -143,6 +144,11 @@ func apiReposDrop(c *gin.Context) {
 		return
 	}
 
+	if len(publishedCollection.ByGenerationSource(repo.UUID)) > 0 {
+		c.AbortWithError(409, fmt.Errorf("unable to drop, local repo is referenced by kept publish generation"))
+		return
+	}
+
 	if !force {
 		snapshots := snapshotCollection.ByLocalRepoSource(repo)
 		if len(snapshots) > 0 {
@@ -281,91 +287,91 @@ func apiReposPackageFromDir(c *gin.Context) {
 		return
 	}
 
-	collection := context.CollectionFactory().LocalRepoCollection()
-	collection.Lock()
-	defer collection.Unlock()
+	name := c.Params.ByName("name")
+	dirParam := c.Params.ByName("dir")
 
-	repo, err := collection.ByName(c.Params.ByName("name"))
-	if err != nil {
-		c.AbortWithError(404, err)
-		return
+	var sources []string
+	if fileParam == "" {
+		sources = []string{filepath.Join(context.UploadPath(), dirParam)}
+	} else {
+		sources = []string{filepath.Join(context.UploadPath(), dirParam, fileParam)}
 	}
 
-	err = collection.LoadComplete(repo)
-	if err != nil {
-		c.AbortWithError(500, err)
-		return
-	}
+	maybeRunTaskInBackground(c, "Add packages from "+dirParam+" to repo "+name, func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		collection := context.CollectionFactory().LocalRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
 
-	verifier := context.GetVerifier()
+		repo, err := collection.ByName(name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 404}, err
+		}
 
-	var (
-		sources                      []string
-		packageFiles, failedFiles    []string
-		otherFiles                   []string
-		processedFiles, failedFiles2 []string
-		reporter                     = &aptly.RecordingResultReporter{
-			Warnings:     []string{},
-			AddedLines:   []string{},
-			RemovedLines: []string{},
+		err = collection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, err
 		}
-		list *deb.PackageList
-	)
 
-	if fileParam == "" {
-		sources = []string{filepath.Join(context.UploadPath(), c.Params.ByName("dir"))}
-	} else {
-		sources = []string{filepath.Join(context.UploadPath(), c.Params.ByName("dir"), c.Params.ByName("file"))}
-	}
+		verifier := context.GetVerifier()
 
-	packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(sources, reporter)
+		var (
+			packageFiles, failedFiles    []string
+			otherFiles                   []string
+			processedFiles, failedFiles2 []string
+			reporter                     = &aptly.RecordingResultReporter{
+				Warnings:     []string{},
+				AddedLines:   []string{},
+				RemovedLines: []string{},
+			}
+			list *deb.PackageList
+		)
 
-	list, err = deb.NewPackageListFromRefList(repo.RefList(), context.CollectionFactory().PackageCollection(), nil)
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to load packages: %s", err))
-		return
-	}
+		packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(sources, reporter)
 
-	processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
-		context.CollectionFactory().PackageCollection(), reporter, nil, context.CollectionFactory().ChecksumCollection)
-	failedFiles = append(failedFiles, failedFiles2...)
+		list, err = deb.NewPackageListFromRefList(repo.RefList(), context.CollectionFactory().PackageCollection(), nil)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to load packages: %s", err)
+		}
 
-	processedFiles = append(processedFiles, otherFiles...)
+		processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
+			context.CollectionFactory().PackageCollection(), reporter, nil, context.CollectionFactory().ChecksumCollection)
+		failedFiles = append(failedFiles, failedFiles2...)
 
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to import package files: %s", err))
-		return
-	}
+		processedFiles = append(processedFiles, otherFiles...)
 
-	repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to import package files: %s", err)
+		}
 
-	err = context.CollectionFactory().LocalRepoCollection().Update(repo)
-	if err != nil {
-		c.AbortWithError(500, fmt.Errorf("unable to save: %s", err))
-		return
-	}
+		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
-	if !noRemove {
-		processedFiles = utils.StrSliceDeduplicate(processedFiles)
+		err = context.CollectionFactory().LocalRepoCollection().Update(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, fmt.Errorf("unable to save: %s", err)
+		}
 
-		for _, file := range processedFiles {
-			err := os.Remove(file)
-			if err != nil {
-				reporter.Warning("unable to remove file %s: %s", file, err)
+		if !noRemove {
+			processedFiles = utils.StrSliceDeduplicate(processedFiles)
+
+			for _, file := range processedFiles {
+				err := os.Remove(file)
+				if err != nil {
+					reporter.Warning("unable to remove file %s: %s", file, err)
+				}
 			}
-		}
 
-		// atempt to remove dir, if it fails, that's fine: probably it's not empty
-		os.Remove(filepath.Join(context.UploadPath(), c.Params.ByName("dir")))
-	}
+			// atempt to remove dir, if it fails, that's fine: probably it's not empty
+			os.Remove(filepath.Join(context.UploadPath(), dirParam))
+		}
 
-	if failedFiles == nil {
-		failedFiles = []string{}
-	}
+		if failedFiles == nil {
+			failedFiles = []string{}
+		}
 
-	c.JSON(200, gin.H{
-		"Report":      reporter,
-		"FailedFiles": failedFiles,
+		return &task.ProcessReturnValue{Code: 200, Value: gin.H{
+			"Report":      reporter,
+			"FailedFiles": failedFiles,
+		}}, nil
 	})
 }
 
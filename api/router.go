@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	ctx "github.com/aptly-dev/aptly/context"
+	"github.com/aptly-dev/aptly/task"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,32 +14,33 @@ var context *ctx.AptlyContext
 func Router(c *ctx.AptlyContext) http.Handler {
 	context = c
 
+	routes := routeTemplates{}
+
 	router := gin.Default()
 	router.Use(gin.ErrorLogger())
+	router.Use(instrumentHandler(routes))
+
+	if authEnabled(&context.Config().APIAuth) {
+		router.Use(authMiddleware(&context.Config().APIAuth))
+	}
 
 	if context.Flags().Lookup("no-lock").Value.Get().(bool) {
 		// We use a goroutine to count the number of
 		// concurrent requests. When no more requests are
 		// running, we close the database to free the lock.
-		requests := make(chan dbRequest)
+		dbRequests = make(chan dbRequest)
 
-		go acquireDatabase(requests)
+		go acquireDatabase(dbRequests)
 
 		router.Use(func(c *gin.Context) {
-			var err error
-
-			errCh := make(chan error)
-			requests <- dbRequest{acquiredb, errCh}
-
-			err = <-errCh
+			err := acquireDatabaseConnection()
 			if err != nil {
 				c.AbortWithError(500, err)
 				return
 			}
 
 			defer func() {
-				requests <- dbRequest{releasedb, errCh}
-				err = <-errCh
+				err = releaseDatabaseConnection()
 				if err != nil {
 					c.AbortWithError(500, err)
 				}
@@ -51,10 +53,14 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		go cacheFlusher()
 	}
 
-	root := router.Group("/api")
+	taskList = task.NewList()
+	go runTasks(taskList)
+
+	root := instrumentedGroup{RouterGroup: router.Group("/api"), routes: routes}
 
 	{
 		root.GET("/version", apiVersion)
+		root.GET("/metrics", apiMetricsGet())
 	}
 
 	{
@@ -78,6 +84,14 @@ func Router(c *ctx.AptlyContext) http.Handler {
 	}
 
 	{
+		root.GET("/mirrors", apiMirrorsList)
+		root.POST("/mirrors", apiMirrorsCreate)
+		root.GET("/mirrors/:name", apiMirrorsShow)
+		root.PUT("/mirrors/:name", apiMirrorsUpdate)
+		root.DELETE("/mirrors/:name", apiMirrorsDrop)
+
+		root.GET("/mirrors/:name/packages", apiMirrorsPackages)
+
 		root.POST("/mirrors/:name/snapshots", apiSnapshotsCreateFromMirror)
 	}
 
@@ -94,6 +108,9 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		root.POST("/publish", apiPublishRepoOrSnapshot)
 		root.POST("/publish/:prefix", apiPublishRepoOrSnapshot)
 		root.PUT("/publish/:prefix/:distribution", apiPublishUpdateSwitch)
+		root.POST("/publish/:prefix/:distribution/rollback", apiPublishRollback)
+		root.POST("/publish/:prefix/:distribution/phase", apiPublishPhase)
+		root.POST("/publish/:prefix/:distribution/overrides", apiPublishOverrides)
 		root.DELETE("/publish/:prefix/:distribution", apiPublishDrop)
 	}
 
@@ -104,6 +121,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		root.GET("/snapshots/:name", apiSnapshotsShow)
 		root.GET("/snapshots/:name/packages", apiSnapshotsSearchPackages)
 		root.DELETE("/snapshots/:name", apiSnapshotsDrop)
+		root.POST("/snapshots/prune", apiSnapshotsPrune)
 		root.GET("/snapshots/:name/diff/:withSnapshot", apiSnapshotsDiff)
 	}
 
@@ -115,5 +133,25 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		root.GET("/graph.:ext", apiGraph)
 	}
 
+	{
+		root.GET("/db/export", apiDbExport)
+		root.POST("/db/import", apiDbImport)
+	}
+
+	{
+		root.POST("/pool/verify", apiPoolVerify)
+	}
+
+	{
+		root.GET("/tasks", apiTasksList)
+		root.POST("/tasks-clear", apiTasksClear)
+		root.GET("/tasks-wait", apiTasksWait)
+		root.GET("/tasks/:id/wait", apiTasksWaitForTaskByID)
+		root.GET("/tasks/:id/output", apiTasksOutputShow)
+		root.GET("/tasks/:id/return_value", apiTasksReturnValueShow)
+		root.GET("/tasks/:id", apiTasksShow)
+		root.DELETE("/tasks/:id", apiTasksDelete)
+	}
+
 	return router
 }
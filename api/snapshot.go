@@ -3,8 +3,10 @@ package api
 import (
 	"fmt"
 
+	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
 	"github.com/gin-gonic/gin"
 )
 
@@ -31,12 +33,6 @@ func apiSnapshotsList(c *gin.Context) {
 
 // POST /api/mirrors/:name/snapshots/
 func apiSnapshotsCreateFromMirror(c *gin.Context) {
-	var (
-		err      error
-		repo     *deb.RemoteRepo
-		snapshot *deb.Snapshot
-	)
-
 	var b struct {
 		Name        string `binding:"required"`
 		Description string
@@ -46,49 +42,48 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		return
 	}
 
-	collection := context.CollectionFactory().RemoteRepoCollection()
-	collection.Lock()
-	defer collection.Unlock()
+	name := c.Params.ByName("name")
 
-	snapshotCollection := context.CollectionFactory().SnapshotCollection()
-	snapshotCollection.Lock()
-	defer snapshotCollection.Unlock()
+	maybeRunTaskInBackground(c, "Create snapshot "+b.Name+" from mirror "+name, func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		collection := context.CollectionFactory().RemoteRepoCollection()
+		collection.Lock()
+		defer collection.Unlock()
 
-	repo, err = collection.ByName(c.Params.ByName("name"))
-	if err != nil {
-		c.AbortWithError(404, err)
-		return
-	}
+		snapshotCollection := context.CollectionFactory().SnapshotCollection()
+		snapshotCollection.Lock()
+		defer snapshotCollection.Unlock()
 
-	err = repo.CheckLock()
-	if err != nil {
-		c.AbortWithError(409, err)
-		return
-	}
+		repo, err := collection.ByName(name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 404}, err
+		}
 
-	err = collection.LoadComplete(repo)
-	if err != nil {
-		c.AbortWithError(500, err)
-		return
-	}
+		err = repo.CheckLock()
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 409}, err
+		}
 
-	snapshot, err = deb.NewSnapshotFromRepository(b.Name, repo)
-	if err != nil {
-		c.AbortWithError(400, err)
-		return
-	}
+		err = collection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 500}, err
+		}
 
-	if b.Description != "" {
-		snapshot.Description = b.Description
-	}
+		snapshot, err := deb.NewSnapshotFromRepository(b.Name, repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 400}, err
+		}
 
-	err = snapshotCollection.Add(snapshot)
-	if err != nil {
-		c.AbortWithError(400, err)
-		return
-	}
+		if b.Description != "" {
+			snapshot.Description = b.Description
+		}
 
-	c.JSON(201, snapshot)
+		err = snapshotCollection.Add(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: 400}, err
+		}
+
+		return &task.ProcessReturnValue{Code: 201, Value: snapshot}, nil
+	})
 }
 
 // POST /api/snapshots
@@ -320,6 +315,11 @@ func apiSnapshotsDrop(c *gin.Context) {
 		return
 	}
 
+	if len(publishedCollection.ByGenerationSource(snapshot.UUID)) > 0 {
+		c.AbortWithError(409, fmt.Errorf("unable to drop: snapshot is referenced by kept publish generation"))
+		return
+	}
+
 	if !force {
 		snapshots := snapshotCollection.BySnapshotSource(snapshot)
 		if len(snapshots) > 0 {
@@ -337,6 +337,104 @@ func apiSnapshotsDrop(c *gin.Context) {
 	c.JSON(200, gin.H{})
 }
 
+// POST /api/snapshots/prune
+func apiSnapshotsPrune(c *gin.Context) {
+	var b struct {
+		Mirrors   []string
+		Retention *deb.RetentionPolicy
+		DryRun    bool
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Retention != nil {
+		if err := b.Retention.Validate(); err != nil {
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	type keptSnapshot struct {
+		Name   string
+		Reason string
+	}
+
+	type pruneResult struct {
+		Mirror    string
+		Retention *deb.RetentionPolicy
+		Dropped   []string
+		Kept      []keptSnapshot
+	}
+
+	mirrorCollection := context.CollectionFactory().RemoteRepoCollection()
+	mirrorCollection.RLock()
+	defer mirrorCollection.RUnlock()
+
+	snapshotCollection := context.CollectionFactory().SnapshotCollection()
+	snapshotCollection.Lock()
+	defer snapshotCollection.Unlock()
+
+	publishedCollection := context.CollectionFactory().PublishedRepoCollection()
+	publishedCollection.RLock()
+	defer publishedCollection.RUnlock()
+
+	var mirrors []*deb.RemoteRepo
+	if len(b.Mirrors) > 0 {
+		for _, name := range b.Mirrors {
+			repo, err := mirrorCollection.ByName(name)
+			if err != nil {
+				c.AbortWithError(404, err)
+				return
+			}
+			mirrors = append(mirrors, repo)
+		}
+	} else {
+		mirrorCollection.ForEach(func(repo *deb.RemoteRepo) error {
+			mirrors = append(mirrors, repo)
+			return nil
+		})
+	}
+
+	results := []pruneResult{}
+
+	for _, repo := range mirrors {
+		policy := b.Retention
+		if policy.IsEmpty() {
+			policy = repo.Retention
+		}
+
+		if policy.IsEmpty() {
+			continue
+		}
+
+		prune, protected := context.CollectionFactory().SnapshotsToPrune(repo, policy)
+
+		result := pruneResult{Mirror: repo.Name, Retention: policy, Dropped: []string{}, Kept: []keptSnapshot{}}
+
+		for _, p := range protected {
+			result.Kept = append(result.Kept, keptSnapshot{Name: p.Snapshot.Name, Reason: p.Reason})
+		}
+
+		for _, snapshot := range prune {
+			if !b.DryRun {
+				err := snapshotCollection.Drop(snapshot)
+				if err != nil {
+					c.AbortWithError(500, err)
+					return
+				}
+			}
+
+			result.Dropped = append(result.Dropped, snapshot.Name)
+		}
+
+		results = append(results, result)
+	}
+
+	c.JSON(200, results)
+}
+
 // GET /api/snapshots/:name/diff/:withSnapshot
 func apiSnapshotsDiff(c *gin.Context) {
 	onlyMatching := c.Request.URL.Query().Get("onlyMatching") == "1"
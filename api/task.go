@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+)
+
+// List of tasks executed in background
+var taskList *task.List
+
+// Executes tasks queued in background one by one, so that
+// database access is serialized just like for synchronous requests.
+//
+// Should be run in a goroutine!
+func runTasks(list *task.List) {
+	for t := list.Next(); t != nil; t = list.Next() {
+		err := acquireDatabaseConnection()
+		if err != nil {
+			list.Fail(t, fmt.Errorf("unable to acquire database: %s", err))
+			continue
+		}
+
+		list.Run(t)
+
+		err = releaseDatabaseConnection()
+		if err != nil {
+			t.Output().Printf("Unable to release database: %s\n", err)
+		}
+	}
+}
+
+// Runs process either synchronously writing result back as response, or
+// puts it into the task queue if requested with _async=true.
+func maybeRunTaskInBackground(c *gin.Context, name string, proc task.Process) {
+	if c.Request.URL.Query().Get("_async") == "true" {
+		t := taskList.RunTaskInBackground(name, proc)
+		c.JSON(202, t)
+		return
+	}
+
+	// output of synchronous request is discarded once request completes
+	retValue, err := proc(task.NewOutput())
+	if err != nil {
+		code := 500
+		if retValue != nil {
+			code = retValue.Code
+		}
+		c.AbortWithError(code, err)
+		return
+	}
+
+	if retValue != nil {
+		c.JSON(retValue.Code, retValue.Value)
+	} else {
+		c.JSON(200, gin.H{})
+	}
+}
+
+func parseTaskID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err != nil {
+		c.AbortWithError(400, fmt.Errorf("invalid task id: %s", err))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// GET /api/tasks
+func apiTasksList(c *gin.Context) {
+	c.JSON(200, taskList.GetTasks())
+}
+
+// POST /api/tasks-clear
+func apiTasksClear(c *gin.Context) {
+	taskList.Clear()
+	c.JSON(200, gin.H{})
+}
+
+// GET /api/tasks-wait
+func apiTasksWait(c *gin.Context) {
+	taskList.Wait()
+	c.JSON(200, gin.H{})
+}
+
+// GET /api/tasks/:id/wait
+func apiTasksWaitForTaskByID(c *gin.Context) {
+	id, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	t, err := taskList.WaitForTaskByID(id)
+	if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	c.JSON(200, t)
+}
+
+// GET /api/tasks/:id
+func apiTasksShow(c *gin.Context) {
+	id, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	t, err := taskList.GetTaskByID(id)
+	if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	c.JSON(200, t)
+}
+
+// GET /api/tasks/:id/output
+func apiTasksOutputShow(c *gin.Context) {
+	id, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	output, err := taskList.GetTaskOutputByID(id)
+	if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	c.JSON(200, output)
+}
+
+// GET /api/tasks/:id/return_value
+func apiTasksReturnValueShow(c *gin.Context) {
+	id, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	t, err := taskList.GetTaskByID(id)
+	if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	retValue, err := taskList.GetTaskReturnValueByID(id)
+	if err != nil {
+		c.AbortWithError(409, err)
+		return
+	}
+
+	if t.Err() != nil {
+		c.JSON(200, gin.H{"Error": t.Err().Error()})
+		return
+	}
+
+	if retValue == nil {
+		c.JSON(200, gin.H{})
+		return
+	}
+
+	c.JSON(200, retValue.Value)
+}
+
+// DELETE /api/tasks/:id
+func apiTasksDelete(c *gin.Context) {
+	id, ok := parseTaskID(c)
+	if !ok {
+		return
+	}
+
+	t, err := taskList.GetTaskByID(id)
+	if err != nil {
+		c.AbortWithError(404, err)
+		return
+	}
+
+	t, err = taskList.DeleteTaskByID(id)
+	if err != nil {
+		c.AbortWithError(409, err)
+		return
+	}
+
+	c.JSON(200, t)
+}
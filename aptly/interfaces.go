@@ -85,6 +85,13 @@ type PublishedStorage interface {
 	ReadLink(path string) (string, error)
 }
 
+// AtomicPublishedStorage is implemented by published storages which can switch
+// published directory to another one in a single operation
+type AtomicPublishedStorage interface {
+	// SwitchDir atomically replaces path with a link to target directory
+	SwitchDir(path, target string) error
+}
+
 // FileSystemPublishedStorage is published storage on filesystem
 type FileSystemPublishedStorage interface {
 	// PublicPath returns root of public part
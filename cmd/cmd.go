@@ -107,6 +107,7 @@ package environment to new version.`,
 			makeCmdPublish(),
 			makeCmdVersion(),
 			makeCmdPackage(),
+			makeCmdPool(),
 			makeCmdAPI(),
 		},
 	}
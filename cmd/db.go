@@ -11,6 +11,9 @@ func makeCmdDb() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdDbCleanup(),
 			makeCmdDbRecover(),
+			makeCmdDbMigrate(),
+			makeCmdDbExport(),
+			makeCmdDbImport(),
 		},
 	}
 }
@@ -28,136 +28,56 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 	// used only in verbose mode to report package use source
 	packageRefSources := map[string][]string{}
 
-	context.Progress().ColoredPrintf("@{w!}Loading mirrors, local repos, snapshots and published repos...@|")
-	if verbose {
-		context.Progress().ColoredPrintf("@{y}Loading mirrors:@|")
-	}
-	err = context.CollectionFactory().RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
-		if verbose {
-			context.Progress().ColoredPrintf("- @{g}%s@|", repo.Name)
-		}
-
-		e := context.CollectionFactory().RemoteRepoCollection().LoadComplete(repo)
-		if e != nil {
-			return e
-		}
-		if repo.RefList() != nil {
-			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
-
-			if verbose {
-				description := fmt.Sprintf("mirror %s", repo.Name)
-				repo.RefList().ForEach(func(key []byte) error {
-					packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
-					return nil
-				})
-			}
-		}
-
-		return nil
-	})
-	if err != nil {
-		return err
+	// DEP-11 files referenced by mirrors and snapshots
+	appStreamFiles := []string{}
+
+	kindTitles := map[string]string{
+		deb.RefSourceMirror:    "mirrors",
+		deb.RefSourceLocalRepo: "local repos",
+		deb.RefSourceSnapshot:  "snapshots",
+		deb.RefSourcePublished: "published repositories",
 	}
 
-	context.CollectionFactory().Flush()
-
-	if verbose {
-		context.Progress().ColoredPrintf("@{y}Loading local repos:@|")
-	}
-	err = context.CollectionFactory().LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
+	context.Progress().ColoredPrintf("@{w!}Loading mirrors, local repos, snapshots and published repos...@|")
+	for _, kind := range deb.RefSourceKinds {
 		if verbose {
-			context.Progress().ColoredPrintf("- @{g}%s@|", repo.Name)
-		}
-
-		e := context.CollectionFactory().LocalRepoCollection().LoadComplete(repo)
-		if e != nil {
-			return e
+			context.Progress().ColoredPrintf("@{y}Loading %s:@|", kindTitles[kind])
 		}
 
-		if repo.RefList() != nil {
-			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
-
+		err = context.CollectionFactory().ForEachPackageRefs(kind, func(refs *deb.PackageRefs) error {
 			if verbose {
-				description := fmt.Sprintf("local repo %s", repo.Name)
-				repo.RefList().ForEach(func(key []byte) error {
-					packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
-					return nil
-				})
+				if refs.Kind == deb.RefSourcePublished {
+					context.Progress().ColoredPrintf("- @{g}%s{|}", refs.Name)
+				} else {
+					context.Progress().ColoredPrintf("- @{g}%s@|", refs.Name)
+				}
 			}
-		}
-
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-
-	context.CollectionFactory().Flush()
 
-	if verbose {
-		context.Progress().ColoredPrintf("@{y}Loading snapshots:@|")
-	}
-	err = context.CollectionFactory().SnapshotCollection().ForEach(func(snapshot *deb.Snapshot) error {
-		if verbose {
-			context.Progress().ColoredPrintf("- @{g}%s@|", snapshot.Name)
-		}
-
-		e := context.CollectionFactory().SnapshotCollection().LoadComplete(snapshot)
-		if e != nil {
-			return e
-		}
-
-		existingPackageRefs = existingPackageRefs.Merge(snapshot.RefList(), false, true)
+			for _, f := range refs.AppStreamFiles {
+				appStreamFiles = append(appStreamFiles, f.PoolPath)
+			}
 
-		if verbose {
-			description := fmt.Sprintf("snapshot %s", snapshot.Name)
-			snapshot.RefList().ForEach(func(key []byte) error {
-				packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
-				return nil
-			})
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
+			for _, source := range refs.Sources {
+				existingPackageRefs = existingPackageRefs.Merge(source.RefList, false, true)
 
-	context.CollectionFactory().Flush()
+				if verbose {
+					description := source.Description
+					source.RefList.ForEach(func(key []byte) error {
+						packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
+						return nil
+					})
+				}
+			}
 
-	if verbose {
-		context.Progress().ColoredPrintf("@{y}Loading published repositories:@|")
-	}
-	err = context.CollectionFactory().PublishedRepoCollection().ForEach(func(published *deb.PublishedRepo) error {
-		if verbose {
-			context.Progress().ColoredPrintf("- @{g}%s:%s/%s{|}", published.Storage, published.Prefix, published.Distribution)
-		}
-		if published.SourceKind != deb.SourceLocalRepo {
 			return nil
-		}
-		e := context.CollectionFactory().PublishedRepoCollection().LoadComplete(published, context.CollectionFactory())
-		if e != nil {
-			return e
+		})
+		if err != nil {
+			return err
 		}
 
-		for _, component := range published.Components() {
-			existingPackageRefs = existingPackageRefs.Merge(published.RefList(component), false, true)
-			if verbose {
-				description := fmt.Sprintf("published repository %s:%s/%s component %s",
-					published.Storage, published.Prefix, published.Distribution, component)
-				published.RefList(component).ForEach(func(key []byte) error {
-					packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
-					return nil
-				})
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return err
+		context.CollectionFactory().Flush()
 	}
 
-	context.CollectionFactory().Flush()
-
 	// ... and compare it to the list of all packages
 	context.Progress().ColoredPrintf("@{w!}Loading list of all packages...@|")
 	allPackageRefs := context.CollectionFactory().PackageCollection().AllPackageRefs()
@@ -234,6 +154,7 @@ func aptlyDbCleanup(cmd *commander.Command, args []string) error {
 		return err
 	}
 
+	referencedFiles = append(referencedFiles, appStreamFiles...)
 	sort.Strings(referencedFiles)
 	context.Progress().ShutdownBar()
 
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+// aptly db export
+func aptlyDbExport(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	filename := args[0]
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	context.Progress().Printf("Exporting database to %s...\n", filename)
+
+	stats, err := context.CollectionFactory().Export(f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+
+	if err != nil {
+		os.Remove(filename)
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	printDumpStats(stats)
+
+	return nil
+}
+
+func printDumpStats(stats deb.DumpStats) {
+	context.Progress().Printf("Mirrors: %d, local repos: %d, snapshots: %d, published repos: %d, packages: %d (%d records total).\n",
+		stats["mirrors"], stats["repos"], stats["snapshots"], stats["published"], stats["packages"], stats.Total())
+}
+
+func makeCmdDbExport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyDbExport,
+		UsageLine: "export <file>",
+		Short:     "export database to portable dump",
+		Long: `
+Command export writes contents of aptly database (mirrors, local repos,
+snapshots, published repositories, packages and checksums) to the file
+in portable dump format: gzip-compressed JSON Lines with format version
+and integrity checksum. Dump doesn't depend on database backend and could
+be loaded back with 'aptly db import'. Package pool is not included in the
+dump and should be backed up separately.
+
+Example:
+
+  $ aptly db export aptly-backup.jsonl.gz
+`,
+	}
+
+	return cmd
+}
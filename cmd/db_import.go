@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/smira/commander"
+)
+
+// aptly db import
+func aptlyDbImport(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	filename := args[0]
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+	defer f.Close()
+
+	context.Progress().Printf("Importing database from %s...\n", filename)
+
+	stats, err := context.CollectionFactory().Import(f)
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+
+	printDumpStats(stats)
+
+	return nil
+}
+
+func makeCmdDbImport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyDbImport,
+		UsageLine: "import <file>",
+		Short:     "import database from portable dump",
+		Long: `
+Command import loads database dump created by 'aptly db export' into
+aptly database. Database should be empty. Dump is verified while being
+loaded, if it turns out to be truncated or corrupted, database is left empty.
+
+Example:
+
+  $ aptly db import aptly-backup.jsonl.gz
+`,
+	}
+
+	return cmd
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+)
+
+// aptly db migrate
+func aptlyDbMigrate(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	backend := args[0]
+	if backend == context.DatabaseBackend() {
+		return fmt.Errorf("unable to migrate: database backend %s is already in use", backend)
+	}
+
+	source, err := context.Database()
+	if err != nil {
+		return fmt.Errorf("unable to open DB: %s", err)
+	}
+
+	target, err := context.NewDatabase(backend)
+	if err != nil {
+		return fmt.Errorf("unable to migrate: %s", err)
+	}
+
+	err = target.Open()
+	if err != nil {
+		return fmt.Errorf("unable to open target DB: %s", err)
+	}
+	defer target.Close()
+
+	if target.HasPrefix(nil) {
+		return fmt.Errorf("unable to migrate: target %s database is not empty", backend)
+	}
+
+	context.Progress().Printf("Migrating database to %s...\n", backend)
+
+	const batchSize = 10000
+
+	var count int
+	batch := target.CreateBatch()
+
+	err = source.ProcessByPrefix(nil, func(key, value []byte) error {
+		if e := batch.Put(key, value); e != nil {
+			return e
+		}
+
+		count++
+		if count%batchSize == 0 {
+			if e := batch.Write(); e != nil {
+				return e
+			}
+			batch = target.CreateBatch()
+		}
+
+		return nil
+	})
+	if err == nil {
+		err = batch.Write()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to migrate: %s", err)
+	}
+
+	context.Progress().Printf("Migrated %d keys, set \"databaseBackend\": %q in the configuration file to use new database.\n",
+		count, backend)
+
+	return target.Close()
+}
+
+func makeCmdDbMigrate() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyDbMigrate,
+		UsageLine: "migrate <backend>",
+		Short:     "copy database contents to another backend",
+		Long: `
+Command migrate copies all the data from the database of the currently
+configured backend (see databaseBackend in configuration file) to the
+database of another backend. Supported backends are goleveldb and bbolt.
+Source database is not modified, target database should be empty.
+
+Once migration is complete, switch databaseBackend in the configuration file
+to start using new database.
+
+Example:
+
+  $ aptly db migrate bbolt
+`,
+	}
+
+	return cmd
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/smira/commander"
 
+	ctx "github.com/aptly-dev/aptly/context"
 	"github.com/aptly-dev/aptly/database/goleveldb"
 )
 
@@ -15,6 +18,10 @@ func aptlyDbRecover(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	if context.DatabaseBackend() != ctx.DatabaseBackendGoLevelDB {
+		return fmt.Errorf("unable to recover: recover is supported only for %s database backend", ctx.DatabaseBackendGoLevelDB)
+	}
+
 	context.Progress().Printf("Recovering database...\n")
 	err = goleveldb.RecoverDB(context.DBPath())
 
@@ -3,6 +3,7 @@ package cmd
 import (
 	"strings"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -45,6 +46,178 @@ func (k *keyRingsFlag) String() string {
 	return strings.Join(k.keyRings, ",")
 }
 
+type stringsFlag struct {
+	values []string
+}
+
+func (s *stringsFlag) Set(value string) error {
+	s.values = append(s.values, value)
+	return nil
+}
+
+func (s *stringsFlag) Get() interface{} {
+	return s.values
+}
+
+func (s *stringsFlag) String() string {
+	return strings.Join(s.values, ",")
+}
+
+// retentionPolicyFromFlags applies -keep-* flags to the snapshot retention policy,
+// policy is returned unchanged if none of the flags were set
+func retentionPolicyFromFlags(flags *flag.FlagSet, policy *deb.RetentionPolicy) (*deb.RetentionPolicy, error) {
+	result := deb.RetentionPolicy{}
+	if policy != nil {
+		result = *policy
+	}
+
+	changed := false
+	flags.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "keep-last":
+			result.KeepLast = flag.Value.Get().(int)
+		case "keep-daily":
+			result.KeepDaily = flag.Value.Get().(int)
+		case "keep-weekly":
+			result.KeepWeekly = flag.Value.Get().(int)
+		default:
+			return
+		}
+		changed = true
+	})
+
+	if !changed {
+		return policy, nil
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	if result.IsEmpty() {
+		return nil, nil
+	}
+
+	return &result, nil
+}
+
+func addRetentionFlags(flags *flag.FlagSet) {
+	flags.Int("keep-last", 0, "snapshot retention: keep N most recent snapshots")
+	flags.Int("keep-daily", 0, "snapshot retention: keep most recent snapshot for each of N last days")
+	flags.Int("keep-weekly", 0, "snapshot retention: keep most recent snapshot for each of N last weeks")
+}
+
+// downloadSettingsFromFlags applies -download-* flags to the mirror download settings,
+// settings are returned unchanged if none of the flags were set
+func downloadSettingsFromFlags(flags *flag.FlagSet, settings *deb.DownloadSettings) (*deb.DownloadSettings, error) {
+	result := deb.DownloadSettings{}
+	if settings != nil {
+		result = *settings
+	}
+
+	changed := false
+	flags.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "download-concurrency":
+			result.Concurrency = flag.Value.Get().(int)
+		case "download-speed-limit":
+			result.SpeedLimit = flag.Value.Get().(int64)
+		case "download-retries":
+			result.Retries = flag.Value.Get().(int)
+		case "download-window":
+			result.BandwidthWindows = nil
+			for _, window := range flag.Value.Get().([]string) {
+				if window != "" {
+					result.BandwidthWindows = append(result.BandwidthWindows, window)
+				}
+			}
+		default:
+			return
+		}
+		changed = true
+	})
+
+	if !changed {
+		return settings, nil
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	if result.IsEmpty() {
+		return nil, nil
+	}
+
+	return &result, nil
+}
+
+func addDownloadSettingsFlags(flags *flag.FlagSet) {
+	flags.Int("download-concurrency", 0, "number of parallel downloads for the mirror (0: use global setting)")
+	flags.Int64("download-speed-limit", 0, "download speed limit for the mirror, kbytes/sec (0: use global setting)")
+	flags.Int("download-retries", 0, "number of retries for failed downloads of the mirror (0: use global setting)")
+	flags.Var(&stringsFlag{}, "download-window", "download speed limit for part of the day: HH:MM-HH:MM=<kbytes/sec> (could be specified multiple times)")
+}
+
+// connectionSettingsFromFlags applies TLS, proxy and credentials flags to the mirror
+// connection settings, empty value of the flag resets the setting
+func connectionSettingsFromFlags(flags *flag.FlagSet, settings *deb.ConnectionSettings) (*deb.ConnectionSettings, error) {
+	result := deb.ConnectionSettings{}
+	if settings != nil {
+		result = *settings
+	}
+
+	changed := false
+	flags.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "ca-file":
+			result.CAFile = flag.Value.String()
+		case "client-cert":
+			result.ClientCertFile = flag.Value.String()
+		case "client-key":
+			result.ClientKeyFile = flag.Value.String()
+		case "insecure-skip-verify":
+			result.InsecureSkipVerify = flag.Value.Get().(bool)
+		case "proxy-url":
+			result.ProxyURL = flag.Value.String()
+		case "http-user":
+			result.Username = flag.Value.String()
+		case "http-password":
+			result.Password = flag.Value.String()
+		case "http-token":
+			result.BearerToken = flag.Value.String()
+		default:
+			return
+		}
+		changed = true
+	})
+
+	if !changed {
+		return settings, nil
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	if result.IsEmpty() {
+		return nil, nil
+	}
+
+	return &result, nil
+}
+
+func addConnectionSettingsFlags(flags *flag.FlagSet) {
+	flags.String("ca-file", "", "file with PEM-encoded CA certificates trusted in addition to system ones")
+	flags.String("client-cert", "", "file with PEM-encoded client certificate for TLS authentication")
+	flags.String("client-key", "", "file with PEM-encoded client key for TLS authentication")
+	flags.Bool("insecure-skip-verify", false, "don't verify server TLS certificate (insecure)")
+	flags.String("proxy-url", "", "HTTP proxy URL for the mirror (overrides proxy environment variables)")
+	flags.String("http-user", "", "username for HTTP basic authentication")
+	flags.String("http-password", "", "password for HTTP basic authentication")
+	flags.String("http-token", "", "token for HTTP bearer authentication")
+}
+
 func makeCmdMirror() *commander.Command {
 	return &commander.Command{
 		UsageLine: "mirror",
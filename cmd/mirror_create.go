@@ -42,11 +42,43 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to create mirror: %s", err)
 	}
 
+	err = repo.SetFallbackRoots(context.Flags().Lookup("fallback-url").Value.Get().([]string))
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	err = repo.CheckLocalRoots(context.Config().LocalArchiveRoots)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.InstallerFlavours = context.Flags().Lookup("installer-flavour").Value.Get().([]string)
+	err = deb.ValidateInstallerFlavours(repo.InstallerFlavours)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.DownloadAppStream = context.Flags().Lookup("with-appstream").Value.Get().(bool)
 	repo.Filter = context.Flags().Lookup("filter").Value.String()
 	repo.FilterWithDeps = context.Flags().Lookup("filter-with-deps").Value.Get().(bool)
 	repo.SkipComponentCheck = context.Flags().Lookup("force-components").Value.Get().(bool)
 	repo.SkipArchitectureCheck = context.Flags().Lookup("force-architectures").Value.Get().(bool)
 
+	repo.Retention, err = retentionPolicyFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.DownloadSettings, err = downloadSettingsFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.Connection, err = connectionSettingsFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
 	if repo.Filter != "" {
 		_, err = query.Parse(repo.Filter)
 		if err != nil {
@@ -59,7 +91,12 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier)
+	downloader, err := context.MirrorDownloader(repo)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	err = repo.Fetch(downloader, verifier)
 	if err != nil {
 		return fmt.Errorf("unable to fetch mirror: %s", err)
 	}
@@ -87,6 +124,31 @@ PPA urls could specified in short format:
 
   $ aptly mirror create <name> ppa:<user>/<project>
 
+Archives on local filesystem (e.g. NFS mount or unpacked DVD) could be mirrored
+using file:// urls, package files are imported into the pool directly without copying
+to temporary location (using hardlinks or reflinks where filesystem allows).
+Local directories should be listed in localArchiveRoots in the configuration file:
+
+  $ aptly mirror create <name> file:///media/cdrom/ bookworm main
+
+Additional archive roots (mirrors of the same archive) could be specified with
+-fallback-url: if Release file can't be downloaded from the archive url, fails
+to verify or is older than the one fetched last time, next root is used.
+Package downloads are spread across all the roots serving the same Release file.
+
+With -with-installer, Debian installer images are downloaded as well, hash sum file
+is verified via Release file or its detached signature. Image flavours could be selected
+with -installer-flavour globs matching directories under images/ (e.g. netboot, cdrom/*).
+
+With -with-appstream, DEP-11 AppStream metadata (dep11/Components-*.yml) and icon tarballs
+listed in Release file are mirrored as well and published along with snapshots of the mirror.
+If mirror is filtered, metadata of packages not included into the mirror is dropped.
+
+Repositories behind mutual TLS or using private CA could be mirrored with -ca-file,
+-client-cert and -client-key flags, HTTP proxy and credentials could be configured
+per mirror with -proxy-url, -http-user/-http-password (basic authentication) or -http-token
+(bearer token). Credentials are stored in aptly database, but never displayed.
+
 Example:
 
   $ aptly mirror create wheezy-main http://mirror.yandex.ru/debian/ wheezy main
@@ -96,13 +158,19 @@ Example:
 
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
+	cmd.Flag.Var(&stringsFlag{}, "installer-flavour", "(only with -with-installer) download only installer images matching glob, e.g. netboot (could be specified multiple times)")
+	cmd.Flag.Bool("with-appstream", false, "download DEP-11 AppStream metadata and icons")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
 	cmd.Flag.Bool("with-udebs", false, "download .udeb packages (Debian installer support)")
+	cmd.Flag.Var(&stringsFlag{}, "fallback-url", "archive url to fall back to if archive url is not available (could be specified multiple times)")
 	cmd.Flag.String("filter", "", "filter packages in mirror")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("force-components", false, "(only with component list) skip check that requested components are listed in Release file")
 	cmd.Flag.Bool("force-architectures", false, "(only with architecture list) skip check that requested architectures are listed in Release file")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	addRetentionFlags(&cmd.Flag)
+	addDownloadSettingsFlags(&cmd.Flag)
+	addConnectionSettingsFlags(&cmd.Flag)
 
 	return cmd
 }
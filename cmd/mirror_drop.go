@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -45,6 +46,11 @@ func aptlyMirrorDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
+	err = os.RemoveAll(repo.IndexCacheDir(context.IndexCachePath()))
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
 	fmt.Printf("Mirror `%s` has been removed.\n", repo.Name)
 
 	return err
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
@@ -35,6 +37,15 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			repo.FilterWithDeps = flag.Value.Get().(bool)
 		case "with-installer":
 			repo.DownloadInstaller = flag.Value.Get().(bool)
+		case "installer-flavour":
+			repo.InstallerFlavours = nil
+			for _, flavour := range flag.Value.Get().([]string) {
+				if flavour != "" {
+					repo.InstallerFlavours = append(repo.InstallerFlavours, flavour)
+				}
+			}
+		case "with-appstream":
+			repo.DownloadAppStream = flag.Value.Get().(bool)
 		case "with-sources":
 			repo.DownloadSources = flag.Value.Get().(bool)
 		case "with-udebs":
@@ -42,9 +53,41 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 		case "archive-url":
 			repo.SetArchiveRoot(flag.Value.String())
 			fetchMirror = true
+		case "fallback-url":
+			err = repo.SetFallbackRoots(flag.Value.Get().([]string))
+			fetchMirror = true
 		}
 	})
 
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	err = repo.CheckLocalRoots(context.Config().LocalArchiveRoots)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Retention, err = retentionPolicyFromFlags(context.Flags(), repo.Retention)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.DownloadSettings, err = downloadSettingsFromFlags(context.Flags(), repo.DownloadSettings)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Connection, err = connectionSettingsFromFlags(context.Flags(), repo.Connection)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	err = deb.ValidateInstallerFlavours(repo.InstallerFlavours)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
 	if repo.IsFlat() && repo.DownloadUdebs {
 		return fmt.Errorf("unable to edit: flat mirrors don't support udebs")
 	}
@@ -68,7 +111,13 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 		}
 
-		err = repo.Fetch(context.Downloader(), verifier)
+		var downloader aptly.Downloader
+		downloader, err = context.MirrorDownloader(repo)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+
+		err = repo.Fetch(downloader, verifier)
 		if err != nil {
 			return fmt.Errorf("unable to edit: %s", err)
 		}
@@ -90,23 +139,42 @@ func makeCmdMirrorEdit() *commander.Command {
 		Short:     "edit mirror settings",
 		Long: `
 Command edit allows one to change settings of mirror:
-filters, list of architectures.
+filters, list of architectures, snapshot retention policy, download settings,
+TLS, proxy and authentication settings.
+
+Download settings override global configuration for the mirror. With -download-window
+download speed limit could be changed for part of the day (local time), e.g. to limit
+download speed during business hours; outside of windows -download-speed-limit applies.
+Speed limit 0 in the window means no limit, empty -download-window= clears the list of windows.
+
+Empty -installer-flavour= clears the list of installer flavours (all images are downloaded).
+
+Empty value of -ca-file, -client-cert, -client-key, -proxy-url, -http-user, -http-password
+or -http-token resets corresponding setting.
 
 Example:
 
   $ aptly mirror edit -filter=nginx -filter-with-deps some-mirror
+
+  $ aptly mirror edit -download-concurrency=2 -download-window=09:00-18:00=256 vendor-mirror
 `,
 		Flag: *flag.NewFlagSet("aptly-mirror-edit", flag.ExitOnError),
 	}
 
 	cmd.Flag.String("archive-url", "", "archive url is the root of archive")
+	cmd.Flag.Var(&stringsFlag{}, "fallback-url", "archive url to fall back to if archive url is not available (could be specified multiple times, empty value clears the list)")
 	cmd.Flag.String("filter", "", "filter packages in mirror")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
+	cmd.Flag.Var(&stringsFlag{}, "installer-flavour", "(only with -with-installer) download only installer images matching glob, e.g. netboot (could be specified multiple times)")
+	cmd.Flag.Bool("with-appstream", false, "download DEP-11 AppStream metadata and icons")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
 	cmd.Flag.Bool("with-udebs", false, "download .udeb packages (Debian installer support)")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	addRetentionFlags(&cmd.Flag)
+	addDownloadSettingsFlags(&cmd.Flag)
+	addConnectionSettingsFlags(&cmd.Flag)
 
 	return cmd
 }
@@ -47,6 +47,9 @@ func aptlyMirrorShowTxt(cmd *commander.Command, args []string) error {
 		fmt.Printf("Status: In Update (PID %d)\n", repo.WorkerPID)
 	}
 	fmt.Printf("Archive Root URL: %s\n", repo.ArchiveRoot)
+	if len(repo.FallbackRoots()) > 0 {
+		fmt.Printf("Fallback Root URLs: %s\n", strings.Join(repo.FallbackRoots(), ", "))
+	}
 	fmt.Printf("Distribution: %s\n", repo.Distribution)
 	fmt.Printf("Components: %s\n", strings.Join(repo.Components, ", "))
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, ", "))
@@ -60,6 +63,12 @@ func aptlyMirrorShowTxt(cmd *commander.Command, args []string) error {
 		downloadUdebs = Yes
 	}
 	fmt.Printf("Download .udebs: %s\n", downloadUdebs)
+	if repo.DownloadAppStream {
+		fmt.Printf("Download AppStream: %s\n", Yes)
+	}
+	if len(repo.InstallerFlavours) > 0 {
+		fmt.Printf("Installer Flavours: %s\n", strings.Join(repo.InstallerFlavours, ", "))
+	}
 	if repo.Filter != "" {
 		fmt.Printf("Filter: %s\n", repo.Filter)
 		filterWithDeps := No
@@ -68,6 +77,15 @@ func aptlyMirrorShowTxt(cmd *commander.Command, args []string) error {
 		}
 		fmt.Printf("Filter With Deps: %s\n", filterWithDeps)
 	}
+	if repo.Retention != nil {
+		fmt.Printf("Snapshot Retention: %s\n", repo.Retention)
+	}
+	if repo.DownloadSettings != nil {
+		fmt.Printf("Download Settings: %s\n", repo.DownloadSettings)
+	}
+	if repo.Connection != nil {
+		fmt.Printf("Connection Settings: %s\n", repo.Connection)
+	}
 	if repo.LastDownloadDate.IsZero() {
 		fmt.Printf("Last update: never\n")
 	} else {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/http"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/commander"
@@ -47,13 +48,26 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier)
+	downloader, err := context.MirrorDownloader(repo)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	err = repo.Fetch(downloader, verifier)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	// dry run shouldn't touch index cache, as mirror is not updated
+	indexCachePath := context.IndexCachePath()
+	if dryRun {
+		indexCachePath = ""
+	}
+
 	context.Progress().Printf("Downloading & parsing package files...\n")
-	err = repo.DownloadPackageIndexes(context.Progress(), context.Downloader(), verifier, context.CollectionFactory(), ignoreMismatch)
+	err = repo.DownloadPackageIndexes(context.Progress(), downloader, verifier, context.CollectionFactory(), ignoreMismatch, indexCachePath)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
@@ -82,14 +96,30 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 
 	skipExistingPackages := context.Flags().Lookup("skip-existing-packages").Value.Get().(bool)
 
+	var checksumStorage aptly.ChecksumStorage = context.CollectionFactory().ChecksumCollection(nil)
+	if dryRun {
+		checksumStorage = deb.ReadOnlyChecksumStorage{ChecksumStorage: checksumStorage}
+	}
+
 	context.Progress().Printf("Building download queue...\n")
 	queue, downloadSize, err = repo.BuildDownloadQueue(context.PackagePool(), context.CollectionFactory().PackageCollection(),
-		context.CollectionFactory().ChecksumCollection(nil), skipExistingPackages)
+		checksumStorage, skipExistingPackages)
 
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	if dryRun {
+		var preview *deb.UpdatePreview
+		preview, err = repo.PreviewUpdate(context.CollectionFactory().PackageCollection(), queue, downloadSize)
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+
+		printUpdatePreview(repo, preview)
+		return nil
+	}
+
 	defer func() {
 		// on any interruption, unlock the mirror
 		err = context.ReOpenDatabase()
@@ -144,7 +174,7 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 
 	var wg sync.WaitGroup
 
-	for i := 0; i < context.Config().DownloadConcurrency; i++ {
+	for i := 0; i < repo.DownloadSettings.GetConcurrency(context.Config().DownloadConcurrency); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -166,13 +196,23 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 						continue
 					}
 
-					// download file...
-					e = context.Downloader().DownloadWithChecksum(
-						context,
-						repo.PackageURL(task.File.DownloadURL()).String(),
-						task.TempDownPath,
-						&task.File.Checksums,
-						ignoreMismatch)
+					// download file, trying archive roots one by one...
+					for _, url := range task.URLs {
+						if _, ok := http.LocalPath(url); ok {
+							// local archive: verify file in place, it would be imported into the pool from there
+							task.LocalPath, e = http.VerifyLocalFile(url, context.Config().LocalArchiveRoots, &task.File.Checksums, ignoreMismatch, downloader.GetProgress())
+						} else {
+							e = downloader.DownloadWithChecksum(
+								context,
+								url,
+								task.TempDownPath,
+								&task.File.Checksums,
+								ignoreMismatch)
+						}
+						if e == nil {
+							break
+						}
+					}
 					if e != nil {
 						pushError(e)
 						continue
@@ -210,7 +250,12 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		}
 
 		// and import it back to the pool
-		task.File.PoolPath, err = context.PackagePool().Import(task.TempDownPath, task.File.Filename, &task.File.Checksums, true, context.CollectionFactory().ChecksumCollection(nil))
+		if task.LocalPath != "" {
+			// file from local archive, keep original in place
+			task.File.PoolPath, err = context.PackagePool().Import(task.LocalPath, task.File.Filename, &task.File.Checksums, false, context.CollectionFactory().ChecksumCollection(nil))
+		} else {
+			task.File.PoolPath, err = context.PackagePool().Import(task.TempDownPath, task.File.Filename, &task.File.Checksums, true, context.CollectionFactory().ChecksumCollection(nil))
+		}
 		if err != nil {
 			return fmt.Errorf("unable to import file: %s", err)
 		}
@@ -234,6 +279,14 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
 	}
 
+	if repo.DownloadAppStream {
+		context.Progress().Printf("Downloading AppStream metadata...\n")
+	}
+	err = repo.DownloadAppStreamFiles(downloader, context.PackagePool(), context.CollectionFactory().ChecksumCollection(nil), ignoreMismatch)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	repo.FinalizeDownload(context.CollectionFactory(), context.Progress())
 	err = context.CollectionFactory().RemoteRepoCollection().Update(repo)
 	if err != nil {
@@ -244,6 +297,37 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 	return err
 }
 
+func printUpdatePreview(repo *deb.RemoteRepo, preview *deb.UpdatePreview) {
+	context.Progress().Printf("\nDry run, mirror `%s` has not been updated.\n", repo.Name)
+
+	if preview.IsEmpty() {
+		context.Progress().Printf("\nNo changes in package list.\n")
+	}
+
+	if len(preview.Added) > 0 {
+		context.Progress().Printf("\nPackages to be added (%d):\n", len(preview.Added))
+		for _, p := range preview.Added {
+			context.Progress().Printf("  %s\n", p)
+		}
+	}
+
+	if len(preview.Removed) > 0 {
+		context.Progress().Printf("\nPackages to be removed (%d):\n", len(preview.Removed))
+		for _, p := range preview.Removed {
+			context.Progress().Printf("  %s\n", p)
+		}
+	}
+
+	if len(preview.Upgraded) > 0 {
+		context.Progress().Printf("\nPackages to be upgraded (%d):\n", len(preview.Upgraded))
+		for _, p := range preview.Upgraded {
+			context.Progress().Printf("  %s -> %s\n", p.From, p.To)
+		}
+	}
+
+	context.Progress().Printf("\nDownload queue: %d items (%s)\n", preview.DownloadCount, utils.HumanBytes(preview.DownloadSize))
+}
+
 func makeCmdMirrorUpdate() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyMirrorUpdate,
@@ -254,6 +338,14 @@ Updates remote mirror (downloads package files and meta information). When mirro
 this command should be run for the first time to fetch mirror contents. This command can be
 run multiple times to get updated repository contents. If interrupted, command can be safely restarted.
 
+Copies of package indexes are kept in the aptly root directory, so if remote repository
+publishes incremental updates (Packages.diff/Index), only the changes are downloaded
+on subsequent updates.
+
+With -dry-run, package indexes are downloaded and filter is applied, but instead of
+updating the mirror aptly displays packages which would be added, removed or upgraded
+and the size of files to download. Neither database nor package pool is modified.
+
 Example:
 
   $ aptly mirror update wheezy-main
@@ -262,6 +354,7 @@ Example:
 	}
 
 	cmd.Flag.Bool("force", false, "force update mirror even if it is locked by another process")
+	cmd.Flag.Bool("dry-run", false, "don't update mirror, just show what would be changed")
 	cmd.Flag.Bool("ignore-checksums", false, "ignore checksum mismatches while downloading package files and metadata")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("skip-existing-packages", false, "do not check file existence for packages listed in the internal database of the mirror")
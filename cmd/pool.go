@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/smira/commander"
+)
+
+func makeCmdPool() *commander.Command {
+	return &commander.Command{
+		UsageLine: "pool",
+		Short:     "manage package pool",
+		Subcommands: []*commander.Command{
+			makeCmdPoolVerify(),
+		},
+	}
+}
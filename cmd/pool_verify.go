@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// aptly pool verify
+func aptlyPoolVerify(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	options := deb.PoolVerifyOptions{
+		Quick:  context.Flags().Lookup("quick").Value.Get().(bool),
+		Repair: context.Flags().Lookup("repair").Value.Get().(bool),
+	}
+
+	context.Progress().Printf("Verifying package files...\n")
+	report, err := deb.VerifyPool(context.CollectionFactory(), context.PackagePool(), options, context.MirrorDownloader, context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	context.Progress().Printf("Verified %d packages (%d files).\n", report.Packages, report.Files)
+
+	for _, kind := range []string{deb.PoolFileMissing, deb.PoolFileCorrupt} {
+		var problems []deb.PoolFileProblem
+		for _, problem := range report.Problems {
+			if problem.Problem == kind {
+				problems = append(problems, problem)
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+
+		if kind == deb.PoolFileMissing {
+			context.Progress().Printf("\nMissing files (%d):\n", len(problems))
+		} else {
+			context.Progress().Printf("\nCorrupt files (%d):\n", len(problems))
+		}
+
+		for _, problem := range problems {
+			switch {
+			case problem.Repaired:
+				context.Progress().Printf("  %s [repaired]\n", &problem)
+			case problem.RepairError != "":
+				context.Progress().Printf("  %s [repair failed: %s]\n", &problem, problem.RepairError)
+			default:
+				context.Progress().Printf("  %s\n", &problem)
+			}
+		}
+	}
+
+	if len(report.Problems) == 0 {
+		context.Progress().Printf("\nAll files are present and correct.\n")
+		return nil
+	}
+
+	if unrepaired := report.Unrepaired(); unrepaired > 0 {
+		return fmt.Errorf("unable to verify: %d files are missing or corrupt", unrepaired)
+	}
+
+	context.Progress().Printf("\nAll files have been repaired.\n")
+	return nil
+}
+
+func makeCmdPoolVerify() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPoolVerify,
+		UsageLine: "verify",
+		Short:     "verify files in package pool",
+		Long: `
+Command verify checks that every file of packages referenced by mirrors, local repos,
+snapshots and published repositories exists in the package pool and matches checksums.
+Missing and corrupt files are reported, command fails if any problems were found.
+
+By default checksums of all the files are recalculated, which might take long time
+for big pools. With -quick, checksums stored in aptly database are trusted, so
+only missing and truncated files are detected.
+
+With -repair, missing and corrupt files of packages coming from mirrors are
+downloaded again from the mirror (or from the mirror snapshot was created from).
+
+Example:
+
+  $ aptly pool verify -repair
+`,
+		Flag: *flag.NewFlagSet("aptly-pool-verify", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("quick", false, "trust checksums stored in the database, detect only missing and truncated files")
+	cmd.Flag.Bool("repair", false, "download missing and corrupt files of mirror packages again")
+
+	return cmd
+}
@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -26,6 +31,175 @@ func getSigner(flags *flag.FlagSet) (pgp.Signer, error) {
 
 }
 
+// getCompression parses comma-separated list of index compression formats
+func getCompression(flags *flag.FlagSet) []string {
+	formats := strings.Split(flags.Lookup("compression").Value.String(), ",")
+	for i := range formats {
+		formats[i] = strings.TrimSpace(formats[i])
+	}
+
+	return formats
+}
+
+// updateReleaseOptions applies -valid-for & -signed-by flags to published repository
+func updateReleaseOptions(flags *flag.FlagSet, published *deb.PublishedRepo) error {
+	if flags.IsSet("valid-for") {
+		validFor, err := time.ParseDuration(flags.Lookup("valid-for").Value.String())
+		if err != nil {
+			return fmt.Errorf("unable to parse -valid-for: %s", err)
+		}
+		if validFor < 0 {
+			return fmt.Errorf("-valid-for should not be negative")
+		}
+		published.ValidFor = validFor
+	}
+
+	if flags.IsSet("signed-by") {
+		published.SignedBy = flags.Lookup("signed-by").Value.String()
+	}
+
+	return nil
+}
+
+// updateGenerationOptions applies -atomic & -keep-generations flags to published repository
+func updateGenerationOptions(flags *flag.FlagSet, published *deb.PublishedRepo) error {
+	if flags.IsSet("keep-generations") {
+		keepGenerations := flags.Lookup("keep-generations").Value.Get().(int)
+		if keepGenerations < 0 {
+			return fmt.Errorf("-keep-generations should not be negative")
+		}
+		published.KeepGenerations = keepGenerations
+	}
+
+	if flags.IsSet("atomic") {
+		atomic := flags.Lookup("atomic").Value.Get().(bool)
+		if !atomic && published.Atomic {
+			return fmt.Errorf("atomic publishing can't be disabled once enabled")
+		}
+		if atomic && !published.Atomic && !flags.IsSet("keep-generations") {
+			published.KeepGenerations = deb.DefaultKeepGenerations
+		}
+		published.Atomic = atomic
+	}
+
+	return nil
+}
+
+// publishTarget is additional published repository given with -target flag
+type publishTarget struct {
+	storage, prefix, distribution string
+}
+
+// getPublishTargets parses -target flags in form [[<endpoint>:]<prefix>:]<distribution>,
+// targets without prefix share storage & prefix with main published repository
+//
+// Prefix is separated with colon, so that value like buster/updates is never split into prefix and distribution
+func getPublishTargets(flags *flag.FlagSet, storage, prefix string) []publishTarget {
+	values := flags.Lookup("target").Value.Get().([]string)
+
+	targets := make([]publishTarget, 0, len(values))
+	for _, value := range values {
+		target := publishTarget{storage: storage, prefix: prefix, distribution: value}
+		if i := strings.LastIndex(value, ":"); i != -1 {
+			target.storage, target.prefix = deb.ParsePrefix(value[:i])
+			target.distribution = value[i+1:]
+		}
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// loadPublishTargets returns published repository followed by existing published repositories
+// given with -target flag
+func loadPublishTargets(flags *flag.FlagSet, published *deb.PublishedRepo) ([]*deb.PublishedRepo, error) {
+	collection := context.CollectionFactory().PublishedRepoCollection()
+
+	repos := []*deb.PublishedRepo{published}
+	for _, target := range getPublishTargets(flags, published.Storage, published.Prefix) {
+		alias, err := collection.ByStoragePrefixDistribution(target.storage, target.prefix, target.distribution)
+		if err != nil {
+			return nil, err
+		}
+
+		if alias.SourceKind != published.SourceKind {
+			return nil, fmt.Errorf("published repository %s/%s has different source kind", alias.StoragePrefix(), alias.Distribution)
+		}
+
+		err = collection.LoadComplete(alias, context.CollectionFactory())
+		if err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, alias)
+	}
+
+	return repos, nil
+}
+
+// cleanupPublishTargets removes files which are no longer referenced from prefixes of published repositories
+func cleanupPublishTargets(repos []*deb.PublishedRepo, components []string) error {
+	cleaned := map[string]bool{}
+	for _, published := range repos {
+		if cleaned[published.StoragePrefix()] {
+			continue
+		}
+		cleaned[published.StoragePrefix()] = true
+
+		err := context.CollectionFactory().PublishedRepoCollection().CleanupPrefixComponentFiles(published.Prefix, components,
+			context.GetPublishedStorage(published.Storage), context.CollectionFactory(), context.Progress())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printPublishedDiffs displays preview of changes to published repositories
+func printPublishedDiffs(diffs []deb.PublishedDiff, cleanup bool) {
+	for _, diff := range diffs {
+		prefix := diff.Prefix
+		if diff.Storage != "" {
+			prefix = diff.Storage + ":" + prefix
+		}
+
+		for _, component := range diff.Components {
+			context.Progress().Printf("Published repository %s/%s, component %s:\n", prefix, diff.Distribution, component.Component)
+
+			if len(component.Added)+len(component.Removed)+len(component.Upgraded)+len(component.Downgraded) == 0 {
+				context.Progress().Printf("  no changes in packages\n")
+			}
+			for _, pdiff := range component.Added {
+				context.Progress().ColoredPrintf("@g+@| %s", pdiff.Right)
+			}
+			for _, pdiff := range component.Removed {
+				context.Progress().ColoredPrintf("@r-@| %s", pdiff.Left)
+			}
+			for _, pdiff := range component.Upgraded {
+				context.Progress().ColoredPrintf("@y^@| %s_%s: %s -> %s", pdiff.Left.Name, pdiff.Left.Architecture, pdiff.Left.Version, pdiff.Right.Version)
+			}
+			for _, pdiff := range component.Downgraded {
+				context.Progress().ColoredPrintf("@yv@| %s_%s: %s -> %s", pdiff.Left.Name, pdiff.Left.Architecture, pdiff.Left.Version, pdiff.Right.Version)
+			}
+
+			if len(component.UploadedFiles) > 0 {
+				context.Progress().Printf("Files to be uploaded to pool:\n")
+				for _, file := range component.UploadedFiles {
+					context.Progress().Printf("  %s\n", file)
+				}
+			}
+
+			if cleanup && len(component.CleanedUpFiles) > 0 {
+				context.Progress().Printf("Files to be cleaned up from pool:\n")
+				for _, file := range component.CleanedUpFiles {
+					context.Progress().Printf("  %s\n", file)
+				}
+			}
+		}
+	}
+}
+
 func makeCmdPublish() *commander.Command {
 	return &commander.Command{
 		UsageLine: "publish",
@@ -33,7 +207,11 @@ func makeCmdPublish() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdPublishDrop(),
 			makeCmdPublishList(),
+			makeCmdPublishOverride(),
+			makeCmdPublishPhase(),
+			makeCmdPublishRefresh(),
 			makeCmdPublishRepo(),
+			makeCmdPublishRollback(),
 			makeCmdPublishSnapshot(),
 			makeCmdPublishSwitch(),
 			makeCmdPublishUpdate(),
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishOverride(cmd *commander.Command, args []string) error {
+	var err error
+
+	remove := context.Flags().Lookup("remove").Value.Get().(bool)
+	source := context.Flags().Lookup("source").Value.Get().(bool)
+
+	minArgs := 3
+	if remove {
+		minArgs = 2
+	}
+	if len(args) < minArgs || len(args) > minArgs+1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == minArgs+1 {
+		param = args[1]
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	var (
+		component string
+		overrides map[string]deb.PackageOverride
+	)
+
+	if remove {
+		component = args[len(args)-1]
+	} else {
+		component = args[len(args)-2]
+
+		var file *os.File
+		file, err = os.Open(args[len(args)-1])
+		if err != nil {
+			return fmt.Errorf("unable to load overrides: %s", err)
+		}
+		defer file.Close()
+
+		overrides, err = deb.ParseOverrideFile(file, source)
+		if err != nil {
+			return fmt.Errorf("unable to load overrides: %s", err)
+		}
+	}
+
+	collection := context.CollectionFactory().PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to override: %s", err)
+	}
+
+	err = collection.LoadComplete(published, context.CollectionFactory())
+	if err != nil {
+		return fmt.Errorf("unable to override: %s", err)
+	}
+
+	err = published.SetOverrides(component, source, overrides)
+	if err != nil {
+		return fmt.Errorf("unable to override: %s", err)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	forceOverwrite := context.Flags().Lookup("force-overwrite").Value.Get().(bool)
+	if forceOverwrite {
+		context.Progress().ColoredPrintf("@rWARNING@|: force overwrite mode enabled, aptly might corrupt other published repositories sharing " +
+			"the same package pool.\n")
+	}
+
+	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = collection.Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	kind := "binary"
+	if source {
+		kind = "source"
+	}
+
+	if remove {
+		context.Progress().Printf("\nOverrides of %s packages of component %s in %s have been removed.\n", kind, component, published.String())
+	} else {
+		context.Progress().Printf("\nOverrides of %s packages of component %s in %s have been set, %d package(s) overridden.\n",
+			kind, component, published.String(), len(overrides))
+	}
+
+	return err
+}
+
+func makeCmdPublishOverride() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishOverride,
+		UsageLine: "override <distribution> [[<endpoint>:]<prefix>] <component> <override-file>",
+		Short:     "set Priority and Section overrides of published packages",
+		Long: `
+Command loads override table of the component of published repository from
+<override-file> and re-publishes it. Priority and Section fields of packages
+listed in override table are replaced in Packages index (or in Sources index
+with -source flag), packages themselves are not changed.
+
+Override file has the format used by Debian archive, for binary packages
+(override.<dist>.<component>) each line is:
+
+    <package> <priority> <section> [<maintainer>]
+
+For source packages (override.<dist>.<component>.src) each line is:
+
+    <package> <section>
+
+Maintainer info is ignored. Binary and source packages have separate override
+tables, override table replaces the one of the same kind loaded previously,
+with -remove flag override table of the component is removed (<override-file>
+should be omitted). Packages without override entry are listed by aptly publish show.
+
+Example:
+
+    $ aptly publish override wheezy ppa main override.wheezy.main
+
+    $ aptly publish override -source wheezy ppa main override.wheezy.main.src
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-override", flag.ExitOnError),
+	}
+	cmd.Flag.Bool("remove", false, "remove override table of the component")
+	cmd.Flag.Bool("source", false, "load (or remove) override table of source packages")
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
+
+	return cmd
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishPhase(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 3 || len(args) > 4 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 4 {
+		param = args[1]
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	q, err := query.Parse(args[len(args)-2])
+	if err != nil {
+		return fmt.Errorf("unable to phase: %s", err)
+	}
+
+	percentage, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return fmt.Errorf("unable to phase: percentage should be a number: %s", err)
+	}
+
+	collection := context.CollectionFactory().PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to phase: %s", err)
+	}
+
+	err = collection.LoadComplete(published, context.CollectionFactory())
+	if err != nil {
+		return fmt.Errorf("unable to phase: %s", err)
+	}
+
+	count, err := published.SetPhasedUpdate(q, percentage, context.CollectionFactory().PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to phase: %s", err)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	forceOverwrite := context.Flags().Lookup("force-overwrite").Value.Get().(bool)
+	if forceOverwrite {
+		context.Progress().ColoredPrintf("@rWARNING@|: force overwrite mode enabled, aptly might corrupt other published repositories sharing " +
+			"the same package pool.\n")
+	}
+
+	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
+	}
+
+	err = collection.Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	if percentage == 100 {
+		context.Progress().Printf("\nPhasing of %d package(s) in %s has been removed.\n", count, published.String())
+	} else {
+		context.Progress().Printf("\nPhased update percentage of %d package(s) in %s has been set to %d.\n",
+			count, published.String(), percentage)
+	}
+
+	return err
+}
+
+// publishPhase is phased update percentage given with -phase flag
+type publishPhase struct {
+	query      deb.PackageQuery
+	percentage int
+}
+
+// getPublishPhases parses -phase flags in form <package-query>:<percent>
+//
+// Percentage is separated with last colon, as package query might contain version with epoch
+func getPublishPhases(flags *flag.FlagSet) ([]publishPhase, error) {
+	values := flags.Lookup("phase").Value.Get().([]string)
+
+	phases := make([]publishPhase, 0, len(values))
+	for _, value := range values {
+		i := strings.LastIndex(value, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("phase should be given as <package-query>:<percent>: %s", value)
+		}
+
+		q, err := query.Parse(value[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		percentage, err := strconv.Atoi(value[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("percentage should be a number: %s", err)
+		}
+
+		phases = append(phases, publishPhase{query: q, percentage: percentage})
+	}
+
+	return phases, nil
+}
+
+// applyPublishPhases sets phased update percentages on published repositories after
+// their sources have been updated, so that new package versions are phased as soon
+// as they are published
+func applyPublishPhases(repos []*deb.PublishedRepo, phases []publishPhase) error {
+	for _, phase := range phases {
+		for _, repo := range repos {
+			_, err := repo.SetPhasedUpdate(phase.query, phase.percentage, context.CollectionFactory().PackageCollection(), context.Progress())
+			if err != nil {
+				return fmt.Errorf("%s: %s", repo.String(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func makeCmdPublishPhase() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishPhase,
+		UsageLine: "phase <distribution> [[<endpoint>:]<prefix>] <package-query> <percent>",
+		Short:     "set phased update percentage of published packages",
+		Long: `
+Command sets Phased-Update-Percentage field of binary packages matching
+<package-query> in published repository and re-publishes it. Clients supporting
+phased updates install new versions of the packages gradually, only <percent>
+of the machines pick up the update. Percentage of 100 removes phasing.
+
+Phasing is kept for the packages as long as they are published. New versions
+could be phased from the moment they are published with -phase flag of
+aptly publish switch and aptly publish update.
+
+Example:
+
+    $ aptly publish phase wheezy ppa 'nginx (= 1.2.1-2)' 10
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-phase", flag.ExitOnError),
+	}
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
+
+	return cmd
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishRefresh(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	published, err := context.CollectionFactory().PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to refresh: %s", err)
+	}
+
+	err = context.CollectionFactory().PublishedRepoCollection().LoadComplete(published, context.CollectionFactory())
+	if err != nil {
+		return fmt.Errorf("unable to refresh: %s", err)
+	}
+
+	err = updateReleaseOptions(context.Flags(), published)
+	if err != nil {
+		return fmt.Errorf("unable to refresh: %s", err)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	err = published.Refresh(context, signer, context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to refresh: %s", err)
+	}
+
+	err = context.CollectionFactory().PublishedRepoCollection().Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	context.Progress().Printf("\nRelease file for %s has been successfully refreshed.\n", published.String())
+
+	return err
+}
+
+func makeCmdPublishRefresh() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishRefresh,
+		UsageLine: "refresh <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "re-sign and re-date Release file of published repository",
+		Long: `
+Command regenerates top-level Release file of published repository with
+new Date (and Valid-Until, if validity period is set) and signs it again.
+Package indexes are not rebuilt, so it is cheap to run it periodically
+(e.g. from cron) to keep repositories with Valid-Until from expiring.
+
+Example:
+
+    $ aptly publish refresh -valid-for=168h wheezy ppa
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-refresh", flag.ExitOnError),
+	}
+	cmd.Flag.String("gpg-key", "", "GPG key ID to use when signing the release")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
+	cmd.Flag.String("valid-for", "", "period of validity of Release file (Valid-Until), e.g. 168h, 0 to disable")
+	cmd.Flag.String("signed-by", "", "fingerprints of keys allowed to sign the repository (Signed-By)")
+
+	return cmd
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -21,6 +22,10 @@ repositories as the arguments:
 
     aptly publish repo -component=main,contrib repo-main repo-contrib
 
+The same local repositories could be published to several distributions and
+prefixes at once with -target flag, index files are generated only once and
+copied to each target.
+
 It is not recommended to publish local repositories directly unless the
 repository is for testing purposes and changes happen frequently. For
 production usage please take snapshot of repository and publish it
@@ -42,6 +47,12 @@ Example:
 	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
+	cmd.Flag.Bool("translations", false, "generate i18n/Translation-en indexes and keep only short descriptions in Packages")
+	cmd.Flag.String("compression", "", "comma-separated list of index compression formats: uncompressed, gz, bz2, xz, zst")
+	cmd.Flag.String("valid-for", "", "period of validity of Release file (Valid-Until), e.g. 168h, 0 to disable")
+	cmd.Flag.String("signed-by", "", "fingerprints of keys allowed to sign the repository (Signed-By)")
+	cmd.Flag.Bool("atomic", false, "stage metadata and switch it in atomically, keeping previous generations for rollback")
+	cmd.Flag.Int("keep-generations", deb.DefaultKeepGenerations, "number of previous generations kept for rollback with -atomic")
 	cmd.Flag.String("origin", "", "origin name to publish")
 	cmd.Flag.String("notautomatic", "", "set value for NotAutomatic field")
 	cmd.Flag.String("butautomaticupgrades", "", "set  value for ButAutomaticUpgrades field")
@@ -49,6 +60,7 @@ Example:
 	cmd.Flag.String("suite", "", "suite to publish (defaults to distribution)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("acquire-by-hash", false, "provide index files by hash")
+	cmd.Flag.Var(&stringsFlag{}, "target", "also publish to [[<endpoint>:]<prefix>:]<distribution> sharing the same index files (could be specified multiple times)")
 
 	return cmd
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishRollback(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	collection := context.CollectionFactory().PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to roll back: %s", err)
+	}
+
+	err = collection.LoadComplete(published, context.CollectionFactory())
+	if err != nil {
+		return fmt.Errorf("unable to roll back: %s", err)
+	}
+
+	// components published by the dropped generation might be gone after rollback
+	components := published.GenerationComponents()
+
+	err = collection.Rollback(context, published, context.CollectionFactory(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to roll back: %s", err)
+	}
+
+	skipCleanup := context.Flags().Lookup("skip-cleanup").Value.Get().(bool)
+	if !skipCleanup {
+		err = cleanupPublishTargets([]*deb.PublishedRepo{published}, components)
+		if err != nil {
+			return fmt.Errorf("unable to roll back: %s", err)
+		}
+	}
+
+	context.Progress().Printf("\nPublished repository %s has been rolled back to generation %d.\n",
+		published.String(), published.ActiveGeneration().ID)
+
+	return err
+}
+
+func makeCmdPublishRollback() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishRollback,
+		UsageLine: "rollback <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "restore previous generation of published repository",
+		Long: `
+Command switches published repository back to the previous generation
+of its metadata. Repository should be published with -atomic flag, which
+keeps previous generations (see -keep-generations) in published storage.
+Current generation is dropped, and published repository sources are
+restored to the state they were when previous generation was published.
+Files in the pool which were used only by the dropped generation are removed
+(unless -skip-cleanup is specified).
+
+Example:
+
+    $ aptly publish rollback wheezy ppa
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-rollback", flag.ExitOnError),
+	}
+	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
+
+	return cmd
+}
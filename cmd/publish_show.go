@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
@@ -72,6 +73,70 @@ func aptlyPublishShowTxt(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	if len(repo.PhasedUpdates) > 0 {
+		phased := []string{}
+		for key, percentage := range repo.PhasedUpdates {
+			pkg, e := context.CollectionFactory().PackageCollection().ByKey([]byte(key))
+			if e != nil {
+				continue
+			}
+			phased = append(phased, fmt.Sprintf("  %s: %d%%", pkg, percentage))
+		}
+		sort.Strings(phased)
+
+		fmt.Printf("Phased updates:\n")
+		for _, line := range phased {
+			fmt.Println(line)
+		}
+	}
+
+	if len(repo.Overrides) > 0 || len(repo.SourceOverrides) > 0 {
+		err = context.CollectionFactory().PublishedRepoCollection().LoadComplete(repo, context.CollectionFactory())
+		if err != nil {
+			return fmt.Errorf("unable to show: %s", err)
+		}
+
+		fmt.Printf("Overrides:\n")
+		for _, source := range []bool{false, true} {
+			var missing map[string][]string
+			missing, err = repo.MissingOverrides(source, context.CollectionFactory().PackageCollection(), nil)
+			if err != nil {
+				return fmt.Errorf("unable to show: %s", err)
+			}
+
+			tables := repo.Overrides
+			suffix := ""
+			if source {
+				tables = repo.SourceOverrides
+				suffix = " (source)"
+			}
+
+			for _, component := range repo.Components() {
+				overrides, ok := tables[component]
+				if !ok {
+					continue
+				}
+
+				fmt.Printf("  %s%s: %d package(s)\n", component, suffix, len(overrides))
+				if len(missing[component]) > 0 {
+					fmt.Printf("    packages without override entry: %s\n", strings.Join(missing[component], ", "))
+				}
+			}
+		}
+	}
+
+	if repo.Atomic {
+		fmt.Printf("Generations (keeping %d previous):\n", repo.KeepGenerations)
+		for i := len(repo.Generations) - 1; i >= 0; i-- {
+			generation := repo.Generations[i]
+			active := ""
+			if i == len(repo.Generations)-1 {
+				active = " [active]"
+			}
+			fmt.Printf("  %d: %s%s\n", generation.ID, generation.CreatedAt.Format("2006-01-02 15:04:05 MST"), active)
+		}
+	}
+
 	return err
 }
 
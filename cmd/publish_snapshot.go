@@ -116,36 +116,74 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 	notAutomatic := context.Flags().Lookup("notautomatic").Value.String()
 	butAutomaticUpgrades := context.Flags().Lookup("butautomaticupgrades").Value.String()
 
-	published, err := deb.NewPublishedRepo(storage, prefix, distribution, context.ArchitecturesList(), components, sources, context.CollectionFactory())
-	if err != nil {
-		return fmt.Errorf("unable to publish: %s", err)
-	}
-	if origin != "" {
-		published.Origin = origin
-	}
-	if notAutomatic != "" {
-		published.NotAutomatic = notAutomatic
-	}
-	if butAutomaticUpgrades != "" {
-		published.ButAutomaticUpgrades = butAutomaticUpgrades
-	}
-	published.Label = context.Flags().Lookup("label").Value.String()
-	published.Suite = context.Flags().Lookup("suite").Value.String()
+	newPublished := func(storage, prefix, distribution string) (*deb.PublishedRepo, error) {
+		published, err := deb.NewPublishedRepo(storage, prefix, distribution, context.ArchitecturesList(), components, sources, context.CollectionFactory())
+		if err != nil {
+			return nil, err
+		}
+		if origin != "" {
+			published.Origin = origin
+		}
+		if notAutomatic != "" {
+			published.NotAutomatic = notAutomatic
+		}
+		if butAutomaticUpgrades != "" {
+			published.ButAutomaticUpgrades = butAutomaticUpgrades
+		}
+		published.Label = context.Flags().Lookup("label").Value.String()
+		published.Suite = context.Flags().Lookup("suite").Value.String()
+
+		published.SkipContents = context.Config().SkipContentsPublishing
+		published.Compression = context.Config().PublishCompression
+
+		if context.Flags().IsSet("skip-contents") {
+			published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("acquire-by-hash") {
+			published.AcquireByHash = context.Flags().Lookup("acquire-by-hash").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("translations") {
+			published.Translations = context.Flags().Lookup("translations").Value.Get().(bool)
+		}
 
-	published.SkipContents = context.Config().SkipContentsPublishing
+		if context.Flags().IsSet("compression") {
+			published.Compression = getCompression(context.Flags())
+		}
+
+		err = updateReleaseOptions(context.Flags(), published)
+		if err != nil {
+			return nil, err
+		}
+
+		err = updateGenerationOptions(context.Flags(), published)
+		if err != nil {
+			return nil, err
+		}
 
-	if context.Flags().IsSet("skip-contents") {
-		published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+		duplicate := context.CollectionFactory().PublishedRepoCollection().CheckDuplicate(published)
+		if duplicate != nil {
+			context.CollectionFactory().PublishedRepoCollection().LoadComplete(duplicate, context.CollectionFactory())
+			return nil, fmt.Errorf("prefix/distribution already used by another published repo: %s", duplicate)
+		}
+
+		return published, nil
 	}
 
-	if context.Flags().IsSet("acquire-by-hash") {
-		published.AcquireByHash = context.Flags().Lookup("acquire-by-hash").Value.Get().(bool)
+	published, err := newPublished(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to publish: %s", err)
 	}
 
-	duplicate := context.CollectionFactory().PublishedRepoCollection().CheckDuplicate(published)
-	if duplicate != nil {
-		context.CollectionFactory().PublishedRepoCollection().LoadComplete(duplicate, context.CollectionFactory())
-		return fmt.Errorf("prefix/distribution already used by another published repo: %s", duplicate)
+	repos := []*deb.PublishedRepo{published}
+	for _, target := range getPublishTargets(context.Flags(), published.Storage, published.Prefix) {
+		var alias *deb.PublishedRepo
+		alias, err = newPublished(target.storage, target.prefix, target.distribution)
+		if err != nil {
+			return fmt.Errorf("unable to publish: %s", err)
+		}
+		repos = append(repos, alias)
 	}
 
 	signer, err := getSigner(context.Flags())
@@ -159,24 +197,16 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 			"the same package pool.\n")
 	}
 
-	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
+	err = deb.PublishBatch(repos, context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
 	if err != nil {
 		return fmt.Errorf("unable to publish: %s", err)
 	}
 
-	err = context.CollectionFactory().PublishedRepoCollection().Add(published)
+	err = context.CollectionFactory().PublishedRepoCollection().UpdateBatch(repos)
 	if err != nil {
 		return fmt.Errorf("unable to save to DB: %s", err)
 	}
 
-	var repoComponents string
-	prefix, repoComponents, distribution = published.Prefix, strings.Join(published.Components(), " "), published.Distribution
-	if prefix == "." {
-		prefix = ""
-	} else if !strings.HasSuffix(prefix, "/") {
-		prefix += "/"
-	}
-
 	context.Progress().Printf("\n%s been successfully published.\n", message)
 
 	if localStorage, ok := context.GetPublishedStorage(storage).(aptly.FileSystemPublishedStorage); ok {
@@ -185,9 +215,19 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 	}
 
 	context.Progress().Printf("Now you can add following line to apt sources:\n")
-	context.Progress().Printf("  deb http://your-server/%s %s %s\n", prefix, distribution, repoComponents)
-	if utils.StrSliceHasItem(published.Architectures, deb.ArchitectureSource) {
-		context.Progress().Printf("  deb-src http://your-server/%s %s %s\n", prefix, distribution, repoComponents)
+	for _, published := range repos {
+		var repoComponents string
+		prefix, repoComponents, distribution = published.Prefix, strings.Join(published.Components(), " "), published.Distribution
+		if prefix == "." {
+			prefix = ""
+		} else if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+
+		context.Progress().Printf("  deb http://your-server/%s %s %s\n", prefix, distribution, repoComponents)
+		if utils.StrSliceHasItem(published.Architectures, deb.ArchitectureSource) {
+			context.Progress().Printf("  deb-src http://your-server/%s %s %s\n", prefix, distribution, repoComponents)
+		}
 	}
 	context.Progress().Printf("Don't forget to add your GPG key to apt with apt-key.\n")
 	context.Progress().Printf("\nYou can also use `aptly serve` to publish your repositories over HTTP quickly.\n")
@@ -211,6 +251,12 @@ as the arguments:
 
     aptly publish snapshot -component=main,contrib snap-main snap-contrib
 
+The same snapshots could be published to several distributions and prefixes
+at once with -target flag, index files are generated only once and copied
+to each target:
+
+    aptly publish snapshot -distribution=stable -target=stable-security -target=s3:mirror:.:stable wheezy-main
+
 Example:
 
     $ aptly publish snapshot wheezy-main
@@ -227,6 +273,12 @@ Example:
 	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
+	cmd.Flag.Bool("translations", false, "generate i18n/Translation-en indexes and keep only short descriptions in Packages")
+	cmd.Flag.String("compression", "", "comma-separated list of index compression formats: uncompressed, gz, bz2, xz, zst")
+	cmd.Flag.String("valid-for", "", "period of validity of Release file (Valid-Until), e.g. 168h, 0 to disable")
+	cmd.Flag.String("signed-by", "", "fingerprints of keys allowed to sign the repository (Signed-By)")
+	cmd.Flag.Bool("atomic", false, "stage metadata and switch it in atomically, keeping previous generations for rollback")
+	cmd.Flag.Int("keep-generations", deb.DefaultKeepGenerations, "number of previous generations kept for rollback with -atomic")
 	cmd.Flag.String("origin", "", "overwrite origin name to publish")
 	cmd.Flag.String("notautomatic", "", "overwrite value for NotAutomatic field")
 	cmd.Flag.String("butautomaticupgrades", "", "overwrite value for ButAutomaticUpgrades field")
@@ -234,6 +286,7 @@ Example:
 	cmd.Flag.String("suite", "", "suite to publish (defaults to distribution)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("acquire-by-hash", false, "provide index files by hash")
+	cmd.Flag.Var(&stringsFlag{}, "target", "also publish to [[<endpoint>:]<prefix>:]<distribution> sharing the same index files (could be specified multiple times)")
 
 	return cmd
 }
@@ -62,6 +62,19 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("mismatch in number of components (%d) and snapshots (%d)", len(components), len(names))
 	}
 
+	repos, err := loadPublishTargets(context.Flags(), published)
+	if err != nil {
+		return fmt.Errorf("unable to switch: %s", err)
+	}
+
+	phases, err := getPublishPhases(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to switch: %s", err)
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+	refLists := map[string]*deb.PackageRefList{}
+
 	for i, component := range components {
 		if !utils.StrSliceHasItem(publishedComponents, component) {
 			return fmt.Errorf("unable to switch: component %s is not in published repository", component)
@@ -77,7 +90,30 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to switch: %s", err)
 		}
 
-		published.UpdateSnapshot(component, snapshot)
+		if dryRun {
+			refLists[component] = snapshot.RefList()
+			continue
+		}
+
+		for _, repo := range repos {
+			repo.UpdateSnapshot(component, snapshot)
+		}
+	}
+
+	if dryRun {
+		var diffs []deb.PublishedDiff
+		diffs, err = context.CollectionFactory().PublishedRepoCollection().PreviewSources(repos, refLists, context, context.CollectionFactory(), nil)
+		if err != nil {
+			return fmt.Errorf("unable to switch: %s", err)
+		}
+
+		printPublishedDiffs(diffs, !context.Flags().Lookup("skip-cleanup").Value.Get().(bool))
+		return nil
+	}
+
+	err = applyPublishPhases(repos, phases)
+	if err != nil {
+		return fmt.Errorf("unable to switch: %s", err)
 	}
 
 	signer, err := getSigner(context.Flags())
@@ -91,30 +127,51 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 			"the same package pool.\n")
 	}
 
-	if context.Flags().IsSet("skip-contents") {
-		published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+	for _, published := range repos {
+		if context.Flags().IsSet("skip-contents") {
+			published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("translations") {
+			published.Translations = context.Flags().Lookup("translations").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("compression") {
+			published.Compression = getCompression(context.Flags())
+		}
+
+		err = updateReleaseOptions(context.Flags(), published)
+		if err != nil {
+			return fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = updateGenerationOptions(context.Flags(), published)
+		if err != nil {
+			return fmt.Errorf("unable to publish: %s", err)
+		}
 	}
 
-	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
+	err = deb.PublishBatch(repos, context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
 	if err != nil {
 		return fmt.Errorf("unable to publish: %s", err)
 	}
 
-	err = context.CollectionFactory().PublishedRepoCollection().Update(published)
+	err = context.CollectionFactory().PublishedRepoCollection().UpdateBatch(repos)
 	if err != nil {
 		return fmt.Errorf("unable to save to DB: %s", err)
 	}
 
 	skipCleanup := context.Flags().Lookup("skip-cleanup").Value.Get().(bool)
 	if !skipCleanup {
-		err = context.CollectionFactory().PublishedRepoCollection().CleanupPrefixComponentFiles(published.Prefix, components,
-			context.GetPublishedStorage(storage), context.CollectionFactory(), context.Progress())
+		err = cleanupPublishTargets(repos, components)
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
 		}
 	}
 
-	context.Progress().Printf("\nPublish for snapshot %s has been successfully switched to new snapshot.\n", published.String())
+	for _, published := range repos {
+		context.Progress().Printf("\nPublish for snapshot %s has been successfully switched to new snapshot.\n", published.String())
+	}
 
 	return err
 }
@@ -141,6 +198,22 @@ Example:
 
 This command would switch published repository (with one component) named ppa/wheezy
 (prefix ppa, dsitribution wheezy to new snapshot wheezy-7.5).
+
+Several published repositories could be switched to the same snapshots at once
+with -target flag, index files are generated only once and all the published
+repositories are updated together:
+
+    $ aptly publish switch -target=wheezy-security -target=s3:mirror:.:wheezy wheezy ppa wheezy-7.5
+
+New package versions could be rolled out gradually with -phase flag, which sets
+Phased-Update-Percentage of packages matching the query before new indexes are
+published:
+
+    $ aptly publish switch -phase='nginx:10' wheezy ppa wheezy-7.5
+
+With -dry-run flag, command displays packages which would be added, removed,
+upgraded or downgraded and package files which would be uploaded to or
+cleaned up from the pool, without changing anything.
 `,
 		Flag: *flag.NewFlagSet("aptly-publish-switch", flag.ExitOnError),
 	}
@@ -152,9 +225,18 @@ This command would switch published repository (with one component) named ppa/wh
 	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
+	cmd.Flag.Bool("translations", false, "generate i18n/Translation-en indexes and keep only short descriptions in Packages")
+	cmd.Flag.String("compression", "", "comma-separated list of index compression formats: uncompressed, gz, bz2, xz, zst")
+	cmd.Flag.String("valid-for", "", "period of validity of Release file (Valid-Until), e.g. 168h, 0 to disable")
+	cmd.Flag.String("signed-by", "", "fingerprints of keys allowed to sign the repository (Signed-By)")
+	cmd.Flag.Bool("atomic", false, "stage metadata and switch it in atomically, keeping previous generations for rollback")
+	cmd.Flag.Int("keep-generations", deb.DefaultKeepGenerations, "number of previous generations kept for rollback with -atomic")
 	cmd.Flag.String("component", "", "component names to update (for multi-component publishing, separate components with commas)")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
+	cmd.Flag.Bool("dry-run", false, "don't switch, just show what would be changed")
+	cmd.Flag.Var(&stringsFlag{}, "phase", "set phased update percentage of published packages <package-query>:<percent> (could be specified multiple times)")
+	cmd.Flag.Var(&stringsFlag{}, "target", "also switch published repository [[<endpoint>:]<prefix>:]<distribution> sharing the same index files (could be specified multiple times)")
 
 	return cmd
 }
@@ -39,9 +39,26 @@ func aptlyPublishUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	repos, err := loadPublishTargets(context.Flags(), published)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	phases, err := getPublishPhases(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	components := published.Components()
-	for _, component := range components {
-		published.UpdateLocalRepo(component)
+	for _, repo := range repos {
+		for _, component := range repo.Components() {
+			repo.UpdateLocalRepo(component)
+		}
+	}
+
+	err = applyPublishPhases(repos, phases)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
 	}
 
 	signer, err := getSigner(context.Flags())
@@ -55,30 +72,51 @@ func aptlyPublishUpdate(cmd *commander.Command, args []string) error {
 			"the same package pool.\n")
 	}
 
-	if context.Flags().IsSet("skip-contents") {
-		published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+	for _, published := range repos {
+		if context.Flags().IsSet("skip-contents") {
+			published.SkipContents = context.Flags().Lookup("skip-contents").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("translations") {
+			published.Translations = context.Flags().Lookup("translations").Value.Get().(bool)
+		}
+
+		if context.Flags().IsSet("compression") {
+			published.Compression = getCompression(context.Flags())
+		}
+
+		err = updateReleaseOptions(context.Flags(), published)
+		if err != nil {
+			return fmt.Errorf("unable to publish: %s", err)
+		}
+
+		err = updateGenerationOptions(context.Flags(), published)
+		if err != nil {
+			return fmt.Errorf("unable to publish: %s", err)
+		}
 	}
 
-	err = published.Publish(context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
+	err = deb.PublishBatch(repos, context.PackagePool(), context, context.CollectionFactory(), signer, context.Progress(), forceOverwrite)
 	if err != nil {
 		return fmt.Errorf("unable to publish: %s", err)
 	}
 
-	err = context.CollectionFactory().PublishedRepoCollection().Update(published)
+	err = context.CollectionFactory().PublishedRepoCollection().UpdateBatch(repos)
 	if err != nil {
 		return fmt.Errorf("unable to save to DB: %s", err)
 	}
 
 	skipCleanup := context.Flags().Lookup("skip-cleanup").Value.Get().(bool)
 	if !skipCleanup {
-		err = context.CollectionFactory().PublishedRepoCollection().CleanupPrefixComponentFiles(published.Prefix, components,
-			context.GetPublishedStorage(storage), context.CollectionFactory(), context.Progress())
+		err = cleanupPublishTargets(repos, components)
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
 		}
 	}
 
-	context.Progress().Printf("\nPublish for local repo %s has been successfully updated.\n", published.String())
+	for _, published := range repos {
+		context.Progress().Printf("\nPublish for local repo %s has been successfully updated.\n", published.String())
+	}
 
 	return err
 }
@@ -97,9 +135,17 @@ minimum possible downtime for published repository.
 For multiple component published repositories, all local repositories
 are updated.
 
+Other published repositories of the same local repositories could be updated
+at once with -target flag, index files are generated only once and all the
+published repositories are updated together.
+
+New package versions could be rolled out gradually with -phase flag, which sets
+Phased-Update-Percentage of packages matching the query before new indexes are
+published.
+
 Example:
 
-    $ aptly publish update wheezy ppa
+    $ aptly publish update -phase='nginx (>= 1.2.1-2):10' wheezy ppa
 `,
 		Flag: *flag.NewFlagSet("aptly-publish-update", flag.ExitOnError),
 	}
@@ -111,8 +157,16 @@ Example:
 	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
 	cmd.Flag.Bool("skip-signing", false, "don't sign Release files with GPG")
 	cmd.Flag.Bool("skip-contents", false, "don't generate Contents indexes")
+	cmd.Flag.Bool("translations", false, "generate i18n/Translation-en indexes and keep only short descriptions in Packages")
+	cmd.Flag.String("compression", "", "comma-separated list of index compression formats: uncompressed, gz, bz2, xz, zst")
+	cmd.Flag.String("valid-for", "", "period of validity of Release file (Valid-Until), e.g. 168h, 0 to disable")
+	cmd.Flag.String("signed-by", "", "fingerprints of keys allowed to sign the repository (Signed-By)")
+	cmd.Flag.Bool("atomic", false, "stage metadata and switch it in atomically, keeping previous generations for rollback")
+	cmd.Flag.Int("keep-generations", deb.DefaultKeepGenerations, "number of previous generations kept for rollback with -atomic")
 	cmd.Flag.Bool("force-overwrite", false, "overwrite files in package pool in case of mismatch")
 	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
+	cmd.Flag.Var(&stringsFlag{}, "phase", "set phased update percentage of published packages <package-query>:<percent> (could be specified multiple times)")
+	cmd.Flag.Var(&stringsFlag{}, "target", "also update published repository [[<endpoint>:]<prefix>:]<distribution> sharing the same index files (could be specified multiple times)")
 
 	return cmd
 }
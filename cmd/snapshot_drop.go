@@ -36,6 +36,20 @@ func aptlySnapshotDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to drop: snapshot is published")
 	}
 
+	published = context.CollectionFactory().PublishedRepoCollection().ByGenerationSource(snapshot.UUID)
+	if len(published) > 0 {
+		fmt.Printf("Snapshot `%s` is required to roll back following published repositories:\n", snapshot.Name)
+		for _, repo := range published {
+			err = context.CollectionFactory().PublishedRepoCollection().LoadComplete(repo, context.CollectionFactory())
+			if err != nil {
+				return fmt.Errorf("unable to load published: %s", err)
+			}
+			fmt.Printf(" * %s\n", repo)
+		}
+
+		return fmt.Errorf("unable to drop: snapshot is referenced by kept publish generation")
+	}
+
 	force := context.Flags().Lookup("force").Value.Get().(bool)
 	if !force {
 		snapshots := context.CollectionFactory().SnapshotCollection().BySnapshotSource(snapshot)
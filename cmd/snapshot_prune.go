@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotPrune(cmd *commander.Command, args []string) error {
+	override, err := retentionPolicyFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	mirrorCollection := context.CollectionFactory().RemoteRepoCollection()
+
+	var mirrors []*deb.RemoteRepo
+	if len(args) > 0 {
+		for _, name := range args {
+			var repo *deb.RemoteRepo
+			repo, err = mirrorCollection.ByName(name)
+			if err != nil {
+				return fmt.Errorf("unable to prune: %s", err)
+			}
+			mirrors = append(mirrors, repo)
+		}
+	} else {
+		err = mirrorCollection.ForEach(func(repo *deb.RemoteRepo) error {
+			mirrors = append(mirrors, repo)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to prune: %s", err)
+		}
+	}
+
+	pruned := 0
+
+	for _, repo := range mirrors {
+		policy := override
+		if policy == nil {
+			policy = repo.Retention
+		}
+
+		if policy.IsEmpty() {
+			if len(args) > 0 {
+				fmt.Printf("Mirror %s has no snapshot retention policy, skipping.\n", repo.Name)
+			}
+			continue
+		}
+
+		prune, protected := context.CollectionFactory().SnapshotsToPrune(repo, policy)
+
+		fmt.Printf("Mirror %s (%s):\n", repo.Name, policy)
+
+		for _, p := range protected {
+			fmt.Printf(" * %s: kept, %s\n", p.Snapshot.Name, p.Reason)
+		}
+
+		for _, snapshot := range prune {
+			if dryRun {
+				fmt.Printf(" * %s: would be dropped\n", snapshot.Name)
+			} else {
+				err = context.CollectionFactory().SnapshotCollection().Drop(snapshot)
+				if err != nil {
+					return fmt.Errorf("unable to prune: %s", err)
+				}
+				fmt.Printf(" * %s: dropped\n", snapshot.Name)
+			}
+		}
+
+		pruned += len(prune)
+	}
+
+	if dryRun {
+		fmt.Printf("\n%d snapshot(s) would be dropped, run without -dry-run to drop them.\n", pruned)
+	} else {
+		fmt.Printf("\n%d snapshot(s) dropped.\n", pruned)
+		if pruned > 0 {
+			fmt.Printf("You can run 'aptly db cleanup' to remove packages no longer referenced.\n")
+		}
+	}
+
+	return err
+}
+
+func makeCmdSnapshotPrune() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotPrune,
+		UsageLine: "prune [<mirror-name> ...]",
+		Short:     "drop old snapshots of mirrors according to retention policy",
+		Long: `
+Command prune drops snapshots created from mirrors according to the snapshot
+retention policy. Policy is configured per mirror (see 'aptly mirror edit'),
+or could be passed with -keep-* flags overriding mirror settings.
+
+Rules are combined: snapshot is kept if it's among N most recent snapshots (-keep-last),
+if it's the most recent snapshot of one of N last days (-keep-daily) or weeks (-keep-weekly)
+which have snapshots. Snapshots which are published (including previous generations
+kept for rollback) or were used as source for other snapshots are never dropped.
+
+If no mirror names are given, all the mirrors are processed: with -keep-* flags
+policy applies to every mirror, otherwise only mirrors with retention policy are pruned.
+
+Example:
+
+    $ aptly snapshot prune -keep-last=5 -keep-weekly=4 -dry-run wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-prune", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't drop snapshots, just show what would be dropped")
+	addRetentionFlags(&cmd.Flag)
+
+	return cmd
+}
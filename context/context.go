@@ -17,6 +17,7 @@ import (
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/console"
 	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/bbolt"
 	"github.com/aptly-dev/aptly/database/goleveldb"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/files"
@@ -55,6 +56,12 @@ type AptlyContext struct {
 // Check interface
 var _ aptly.PublishedStorageProvider = &AptlyContext{}
 
+// Supported database backends
+const (
+	DatabaseBackendGoLevelDB = "goleveldb"
+	DatabaseBackendBolt      = "bbolt"
+)
+
 // FatalError is type for panicking to abort execution with non-zero
 // exit code and print meaningful explanation
 type FatalError struct {
@@ -206,28 +213,55 @@ func (context *AptlyContext) Downloader() aptly.Downloader {
 	defer context.Unlock()
 
 	if context.downloader == nil {
-		var downloadLimit int64
-		limitFlag := context.flags.Lookup("download-limit")
-		if limitFlag != nil {
-			downloadLimit = limitFlag.Value.Get().(int64)
-		}
-		if downloadLimit == 0 {
-			downloadLimit = context.config().DownloadLimit
-		}
-		maxTries := context.config().DownloadRetries + 1
-		maxTriesFlag := context.flags.Lookup("max-tries")
-		if maxTriesFlag != nil {
-			maxTriesFlagValue := maxTriesFlag.Value.Get().(int)
-			if maxTriesFlagValue > maxTries {
-				maxTries = maxTriesFlagValue
-			}
-		}
-		context.downloader = http.NewDownloader(downloadLimit*1024, maxTries, context._progress())
+		context.downloader, _ = context.newDownloader(nil, nil)
 	}
 
 	return context.downloader
 }
 
+// MirrorDownloader creates downloader for the mirror: mirror download settings
+// override global configuration, -download-limit and -max-tries flags override both,
+// mirror connection settings (TLS, proxy, credentials) are applied as well
+func (context *AptlyContext) MirrorDownloader(repo *deb.RemoteRepo) (aptly.Downloader, error) {
+	context.Lock()
+	defer context.Unlock()
+
+	return context.newDownloader(repo.DownloadSettings, repo.Connection)
+}
+
+func (context *AptlyContext) newDownloader(settings *deb.DownloadSettings, connection *deb.ConnectionSettings) (aptly.Downloader, error) {
+	var (
+		options http.DownloaderOptions
+		err     error
+	)
+
+	options.BandwidthWindows, err = settings.Windows()
+	if err != nil {
+		return nil, err
+	}
+	connection.Apply(&options)
+	options.LocalRoots = context.config().LocalArchiveRoots
+
+	downloadLimit := settings.GetSpeedLimit(context.config().DownloadLimit)
+	limitFlag := context.flags.Lookup("download-limit")
+	if limitFlag != nil && limitFlag.Value.Get().(int64) != 0 {
+		// explicit limit overrides bandwidth windows as well
+		downloadLimit = limitFlag.Value.Get().(int64)
+		options.BandwidthWindows = nil
+	}
+
+	maxTries := settings.GetRetries(context.config().DownloadRetries) + 1
+	maxTriesFlag := context.flags.Lookup("max-tries")
+	if maxTriesFlag != nil {
+		maxTriesFlagValue := maxTriesFlag.Value.Get().(int)
+		if maxTriesFlagValue > maxTries {
+			maxTries = maxTriesFlagValue
+		}
+	}
+
+	return http.NewDownloaderWithOptions(downloadLimit*1024, maxTries, context._progress(), options)
+}
+
 // DBPath builds path to database
 func (context *AptlyContext) DBPath() string {
 	context.Lock()
@@ -238,7 +272,57 @@ func (context *AptlyContext) DBPath() string {
 
 // DBPath builds path to database
 func (context *AptlyContext) dbPath() string {
-	return filepath.Join(context.config().RootDir, "db")
+	return context.dbPathForBackend(context.dbBackend())
+}
+
+// DatabaseBackend returns name of configured database backend
+func (context *AptlyContext) DatabaseBackend() string {
+	context.Lock()
+	defer context.Unlock()
+
+	return context.dbBackend()
+}
+
+func (context *AptlyContext) dbBackend() string {
+	if context.config().DatabaseBackend == "" {
+		return DatabaseBackendGoLevelDB
+	}
+
+	return context.config().DatabaseBackend
+}
+
+// dbPathForBackend builds path to database of specific backend
+//
+// goleveldb keeps historical location, other backends get their own directory,
+// so that data could be migrated between backends
+func (context *AptlyContext) dbPathForBackend(backend string) string {
+	if backend == DatabaseBackendGoLevelDB {
+		return filepath.Join(context.config().RootDir, "db")
+	}
+
+	return filepath.Join(context.config().RootDir, "db-"+backend)
+}
+
+// NewDatabase instantiates (but doesn't open) database of specified backend
+// in its default location
+func (context *AptlyContext) NewDatabase(backend string) (database.Storage, error) {
+	context.Lock()
+	defer context.Unlock()
+
+	return context.newDatabase(backend)
+}
+
+func (context *AptlyContext) newDatabase(backend string) (database.Storage, error) {
+	path := context.dbPathForBackend(backend)
+
+	switch backend {
+	case DatabaseBackendGoLevelDB:
+		return goleveldb.NewDB(path)
+	case DatabaseBackendBolt:
+		return bbolt.NewDB(path)
+	}
+
+	return nil, fmt.Errorf("unknown database backend: %q", backend)
 }
 
 // Database opens and returns current instance of database
@@ -253,7 +337,7 @@ func (context *AptlyContext) _database() (database.Storage, error) {
 	if context.database == nil {
 		var err error
 
-		context.database, err = goleveldb.NewDB(context.dbPath())
+		context.database, err = context.newDatabase(context.dbBackend())
 		if err != nil {
 			return nil, fmt.Errorf("can't instantiate database: %s", err)
 		}
@@ -393,6 +477,11 @@ func (context *AptlyContext) UploadPath() string {
 	return filepath.Join(context.Config().RootDir, "upload")
 }
 
+// IndexCachePath builds path to the cache of mirror package indexes
+func (context *AptlyContext) IndexCachePath() string {
+	return filepath.Join(context.Config().RootDir, "indexes")
+}
+
 func (context *AptlyContext) pgpProvider() string {
 	var provider string
 
@@ -483,7 +572,7 @@ func (context *AptlyContext) GoContextHandleSignals() {
 	defer context.Unlock()
 
 	// Catch ^C
-	sigch := make(chan os.Signal)
+	sigch := make(chan os.Signal, 1)
 	signal.Notify(sigch, os.Interrupt)
 
 	var cancel gocontext.CancelFunc
@@ -0,0 +1,64 @@
+package bbolt
+
+import (
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/aptly-dev/aptly/database"
+)
+
+type batchOp struct {
+	key, value []byte
+	delete     bool
+}
+
+type batch struct {
+	db  *bolt.DB
+	ops []batchOp
+}
+
+func (b *batch) Put(key, value []byte) error {
+	b.ops = append(b.ops, batchOp{key: append([]byte(nil), key...), value: append([]byte(nil), value...)})
+
+	return nil
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.ops = append(b.ops, batchOp{key: append([]byte(nil), key...), delete: true})
+
+	return nil
+}
+
+func (b *batch) Write() error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+
+		for _, op := range b.ops {
+			var err error
+
+			if op.delete {
+				err = bucket.Delete(op.key)
+			} else {
+				err = bucket.Put(op.key, op.value)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	b.ops = nil
+
+	return err
+}
+
+// batch should implement database.Batch
+var (
+	_ database.Batch = &batch{}
+)
@@ -0,0 +1,59 @@
+package bbolt
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/aptly-dev/aptly/database"
+)
+
+// dbFile is the name of bbolt data file inside DB directory
+const dbFile = "aptly.db"
+
+// bucketName is the name of the single bucket holding all the keys
+var bucketName = []byte("aptly")
+
+func internalOpen(path string, noSync bool) (*bolt.DB, error) {
+	db, err := bolt.Open(filepath.Join(path, dbFile), 0644, &bolt.Options{
+		Timeout:      time.Second,
+		NoSync:       noSync,
+		FreelistType: bolt.FreelistMapType,
+	})
+	if err != nil {
+		if err == bolt.ErrTimeout {
+			// report the same error as goleveldb does, so that callers could retry
+			return nil, fmt.Errorf("unable to lock %s: %s", path, syscall.EAGAIN)
+		}
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, e := tx.CreateBucketIfNotExists(bucketName)
+		return e
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// NewDB creates new instance of DB, but doesn't open it (yet)
+func NewDB(path string) (database.Storage, error) {
+	return &storage{path: path}, nil
+}
+
+// NewOpenDB creates new instance of DB and opens it
+func NewOpenDB(path string) (database.Storage, error) {
+	db, err := NewDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, db.Open()
+}
@@ -0,0 +1,272 @@
+package bbolt
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/aptly-dev/aptly/database"
+)
+
+type storage struct {
+	path   string
+	noSync bool
+	db     *bolt.DB
+}
+
+// CreateTemporary creates new DB of the same type in temp dir
+func (s *storage) CreateTemporary() (database.Storage, error) {
+	tempdir, err := ioutil.TempDir("", "aptly")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := internalOpen(tempdir, true)
+	if err != nil {
+		return nil, err
+	}
+	return &storage{db: db, path: tempdir, noSync: true}, nil
+}
+
+// Get key value from database
+func (s *storage) Get(key []byte) (value []byte, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value, err = get(tx, key)
+		return err
+	})
+
+	return
+}
+
+// Put saves key to database, if key has the same value in DB already, it is not saved
+func (s *storage) Put(key []byte, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, key, value)
+	})
+}
+
+// Delete removes key from DB
+func (s *storage) Delete(key []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete(key)
+	})
+}
+
+// KeysByPrefix returns all keys that start with prefix
+func (s *storage) KeysByPrefix(prefix []byte) [][]byte {
+	result := make([][]byte, 0, 20)
+
+	s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketName).Cursor()
+
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			result = append(result, append([]byte(nil), key...))
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+// FetchByPrefix returns all values with keys that start with prefix
+func (s *storage) FetchByPrefix(prefix []byte) [][]byte {
+	result := make([][]byte, 0, 20)
+
+	s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketName).Cursor()
+
+		for key, val := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, val = cursor.Next() {
+			result = append(result, append([]byte(nil), val...))
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+// HasPrefix checks whether it can find any key with given prefix and returns true if one exists
+func (s *storage) HasPrefix(prefix []byte) (found bool) {
+	s.db.View(func(tx *bolt.Tx) error {
+		key, _ := tx.Bucket(bucketName).Cursor().Seek(prefix)
+		found = key != nil && bytes.HasPrefix(key, prefix)
+
+		return nil
+	})
+
+	return
+}
+
+// ProcessByPrefix iterates through all entries where key starts with prefix and calls
+// StorageProcessor on key value pair
+//
+// bbolt doesn't allow writes while read transaction is open in the same goroutine, so
+// entries are loaded in chunks and StorageProcessor is called outside of the transaction
+func (s *storage) ProcessByPrefix(prefix []byte, proc database.StorageProcessor) error {
+	const chunkSize = 1024
+
+	var (
+		keys, values [][]byte
+		seek         = prefix
+		skipFirst    bool
+	)
+
+	for {
+		keys, values = keys[:0], values[:0]
+
+		err := s.db.View(func(tx *bolt.Tx) error {
+			cursor := tx.Bucket(bucketName).Cursor()
+
+			key, val := cursor.Seek(seek)
+			if skipFirst && key != nil && bytes.Equal(key, seek) {
+				key, val = cursor.Next()
+			}
+
+			for ; key != nil && bytes.HasPrefix(key, prefix) && len(keys) < chunkSize; key, val = cursor.Next() {
+				keys = append(keys, append([]byte(nil), key...))
+				values = append(values, append([]byte(nil), val...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range keys {
+			err = proc(keys[i], values[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < chunkSize {
+			return nil
+		}
+
+		seek, skipFirst = keys[len(keys)-1], true
+	}
+}
+
+// Close finishes DB work
+func (s *storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Reopen tries to open (re-open) the database
+func (s *storage) Open() error {
+	if s.db != nil {
+		return nil
+	}
+
+	err := os.MkdirAll(s.path, 0777)
+	if err != nil {
+		return err
+	}
+
+	s.db, err = internalOpen(s.path, s.noSync)
+	return err
+}
+
+// CreateBatch creates a Batch object
+func (s *storage) CreateBatch() database.Batch {
+	return &batch{
+		db: s.db,
+	}
+}
+
+// OpenTransaction creates new transaction.
+//
+// Transaction holds bbolt write lock, so other write operations
+// will be blocked until transaction is committed or discarded
+func (s *storage) OpenTransaction() (database.Transaction, error) {
+	t, err := s.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction{t: t}, nil
+}
+
+// CompactDB compacts database by copying all the data into new file
+//
+// bbolt never shrinks data file, pages freed by deletes are reused
+// for new data, so compaction is the only way to return space to the OS
+func (s *storage) CompactDB() error {
+	compactPath := filepath.Join(s.path, dbFile+".compact")
+
+	dst, err := bolt.Open(compactPath, 0644, &bolt.Options{NoSync: true})
+	if err != nil {
+		return err
+	}
+
+	err = bolt.Compact(dst, s.db, 64*1024*1024)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err1 := dst.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(compactPath)
+		return err
+	}
+
+	err = s.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(compactPath, filepath.Join(s.path, dbFile))
+	if err != nil {
+		return err
+	}
+
+	return s.Open()
+}
+
+// Drop removes all the DB files (DANGEROUS!)
+func (s *storage) Drop() error {
+	if s.db != nil {
+		return errors.New("DB is still open")
+	}
+
+	return os.RemoveAll(s.path)
+}
+
+func get(tx *bolt.Tx, key []byte) ([]byte, error) {
+	value := tx.Bucket(bucketName).Get(key)
+	if value == nil {
+		return nil, database.ErrNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+func put(tx *bolt.Tx, key, value []byte) error {
+	bucket := tx.Bucket(bucketName)
+
+	old := bucket.Get(key)
+	if old != nil && bytes.Equal(old, value) {
+		return nil
+	}
+
+	// bbolt requires key & value to stay valid until transaction is committed,
+	// while callers are free to reuse buffers
+	return bucket.Put(append([]byte(nil), key...), append([]byte(nil), value...))
+}
+
+// Check interface
+var (
+	_ database.Storage = &storage{}
+)
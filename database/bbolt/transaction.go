@@ -0,0 +1,48 @@
+package bbolt
+
+import (
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/aptly-dev/aptly/database"
+)
+
+type transaction struct {
+	t    *bolt.Tx
+	done bool
+}
+
+// Get implements database.Reader interface.
+func (t *transaction) Get(key []byte) ([]byte, error) {
+	return get(t.t, key)
+}
+
+// Put implements database.Writer interface.
+func (t *transaction) Put(key, value []byte) error {
+	return put(t.t, key, value)
+}
+
+// Delete implements database.Writer interface.
+func (t *transaction) Delete(key []byte) error {
+	return t.t.Bucket(bucketName).Delete(key)
+}
+
+// Commit finalizes transaction and commits changes to the stable storage.
+func (t *transaction) Commit() error {
+	t.done = true
+	return t.t.Commit()
+}
+
+// Discard any transaction changes.
+//
+// Discard is safe to call after Commit(), it would be no-op
+func (t *transaction) Discard() {
+	if t.done {
+		return
+	}
+
+	t.done = true
+	t.t.Rollback()
+}
+
+// transaction should implement database.Transaction
+var _ database.Transaction = &transaction{}
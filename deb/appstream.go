@@ -0,0 +1,367 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	gocontext "context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// AppStreamFile is DEP-11 AppStream metadata file mirrored from the remote repository
+type AppStreamFile struct {
+	// Component file belongs to
+	Component string
+	// Name of the file under <component>/dep11/, e.g. Components-amd64.yml or icons-64x64.tar.gz
+	Name string
+	// Path of the file in the package pool
+	PoolPath string
+	// Checksums of the file in the pool
+	Checksums utils.ChecksumInfo
+}
+
+// Architecture returns architecture of the component metadata file, or "" for icon tarballs
+func (f *AppStreamFile) Architecture() string {
+	if strings.HasPrefix(f.Name, "Components-") && strings.HasSuffix(f.Name, ".yml") {
+		return strings.TrimSuffix(strings.TrimPrefix(f.Name, "Components-"), ".yml")
+	}
+
+	return ""
+}
+
+// appStreamPath returns path of DEP-11 file relative to dists/<distribution>/
+func appStreamPath(component, name string) string {
+	return filepath.Join(component, "dep11", name)
+}
+
+// isListedInRelease checks whether file is listed in Release file in any compression
+func (repo *RemoteRepo) isListedInRelease(path string) bool {
+	for _, ext := range []string{"", ".gz", ".bz2", ".xz"} {
+		if _, ok := repo.ReleaseFiles[path+ext]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DownloadAppStreamFiles downloads DEP-11 AppStream metadata and icons listed in Release file
+// and stores them in the package pool
+//
+// If mirror is filtered, metadata for packages not included into the mirror is dropped.
+// Should be called after ApplyFilter, but before FinalizeDownload
+func (repo *RemoteRepo) DownloadAppStreamFiles(d aptly.Downloader, packagePool aptly.PackagePool, checksumStorage aptly.ChecksumStorage,
+	ignoreMismatch bool) error {
+	if !repo.DownloadAppStream || repo.IsFlat() {
+		repo.AppStreamFiles = nil
+		return nil
+	}
+
+	var packages map[string]bool
+	if repo.Filter != "" && repo.packageList != nil {
+		packages = map[string]bool{}
+		repo.packageList.ForEach(func(p *Package) error {
+			packages[p.Name] = true
+			return nil
+		})
+	}
+
+	tempDir, err := ioutil.TempDir(os.TempDir(), "aptly")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	result := []AppStreamFile{}
+
+	importFile := func(component, name, tempPath string) error {
+		checksums, err := utils.ChecksumsForFile(tempPath)
+		if err != nil {
+			return err
+		}
+
+		poolPath, err := packagePool.Import(tempPath, name, &checksums, true, checksumStorage)
+		if err != nil {
+			return fmt.Errorf("unable to import %s: %s", appStreamPath(component, name), err)
+		}
+
+		result = append(result, AppStreamFile{Component: component, Name: name, PoolPath: poolPath, Checksums: checksums})
+		return nil
+	}
+
+	for _, component := range repo.Components {
+		for _, arch := range repo.Architectures {
+			name := fmt.Sprintf("Components-%s.yml", arch)
+			path := appStreamPath(component, name)
+			if !repo.isListedInRelease(path) {
+				continue
+			}
+
+			reader, file, err := repo.downloadIndex(d, path, ignoreMismatch)
+			if err != nil {
+				if _, ok := err.(*http.NoCandidateFoundError); ok {
+					continue
+				}
+				return err
+			}
+
+			tempPath := filepath.Join(tempDir, name)
+			err = writeAppStreamComponents(tempPath, reader, packages)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("unable to process %s: %s", path, err)
+			}
+
+			if err = importFile(component, name, tempPath); err != nil {
+				return err
+			}
+		}
+
+		var icons []string
+		prefix := appStreamPath(component, "icons-")
+		for path := range repo.ReleaseFiles {
+			if strings.HasPrefix(path, prefix) && strings.HasSuffix(path, ".tar.gz") && filepath.Dir(path) == filepath.Dir(prefix) {
+				icons = append(icons, filepath.Base(path))
+			}
+		}
+		sort.Strings(icons)
+
+		for _, name := range icons {
+			path := appStreamPath(component, name)
+			expected := repo.ReleaseFiles[path]
+			tempPath := filepath.Join(tempDir, name)
+
+			for _, root := range repo.healthyRoots() {
+				iconsURL := repo.indexesRootURL(root).ResolveReference(&url.URL{Path: path}).String()
+				err = d.DownloadWithChecksum(gocontext.TODO(), iconsURL, tempPath, &expected, ignoreMismatch)
+				if err == nil {
+					break
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			if err = importFile(component, name, tempPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	repo.AppStreamFiles = result
+	return nil
+}
+
+// writeAppStreamComponents stores (optionally filtered) DEP-11 components metadata to the file
+func writeAppStreamComponents(path string, r io.Reader, packages map[string]bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	err = filterAppStreamComponents(w, r, packages, false)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// filterAppStreamComponents copies DEP-11 YAML documents from r to w
+//
+// If packages is not nil, components of packages not in the set are skipped.
+// Documents which don't refer to any package (DEP-11 header) are copied,
+// unless skipHeader is set
+func filterAppStreamComponents(w io.Writer, r io.Reader, packages map[string]bool, skipHeader bool) error {
+	reader := bufio.NewReader(r)
+
+	var (
+		document []string
+		pkgName  string
+	)
+
+	flush := func() error {
+		var keep bool
+		if pkgName == "" {
+			keep = !skipHeader
+		} else {
+			keep = packages == nil || packages[pkgName]
+		}
+
+		if keep {
+			for _, line := range document {
+				if _, err := io.WriteString(w, line); err != nil {
+					return err
+				}
+			}
+		}
+
+		document = document[:0]
+		pkgName = ""
+		return nil
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if line != "" {
+			if strings.TrimRight(line, " \r\n") == "---" && len(document) > 0 {
+				if err2 := flush(); err2 != nil {
+					return err2
+				}
+			}
+
+			if pkgName == "" && strings.HasPrefix(line, "Package:") {
+				pkgName = strings.TrimSpace(strings.TrimPrefix(line, "Package:"))
+			}
+
+			document = append(document, line)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(document) == 0 {
+		return nil
+	}
+
+	return flush()
+}
+
+// publishAppStream writes DEP-11 files of the snapshot into published component
+//
+// Components metadata is published only for published architectures. When several
+// files map to the same published file (snapshot contains several components of
+// the mirror), they're merged
+func publishAppStream(indexes *indexFiles, packagePool aptly.PackagePool, component string, files []AppStreamFile, architectures []string) error {
+	byName := map[string][]AppStreamFile{}
+	names := []string{}
+
+	for _, f := range files {
+		arch := f.Architecture()
+		if arch != "" && !utils.StrSliceHasItem(architectures, arch) {
+			continue
+		}
+
+		if _, ok := byName[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+		byName[f.Name] = append(byName[f.Name], f)
+	}
+
+	for _, name := range names {
+		bufWriter, err := indexes.AppStreamIndex(component, name).BufWriter()
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(name, ".tar.gz") && len(byName[name]) > 1 {
+			err = mergeAppStreamIcons(bufWriter, packagePool, byName[name])
+		} else {
+			for i, f := range byName[name] {
+				err = copyAppStreamFile(bufWriter, packagePool, f, i > 0)
+				if err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to publish %s: %s", appStreamPath(component, name), err)
+		}
+	}
+
+	return nil
+}
+
+// copyAppStreamFile copies file from the pool, optionally skipping DEP-11 header
+func copyAppStreamFile(w io.Writer, packagePool aptly.PackagePool, f AppStreamFile, skipHeader bool) error {
+	r, err := packagePool.Open(f.PoolPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if skipHeader {
+		return filterAppStreamComponents(w, r, nil, true)
+	}
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// mergeAppStreamIcons merges several icon tarballs into one, first entry with the same name wins
+func mergeAppStreamIcons(w io.Writer, packagePool aptly.PackagePool, files []AppStreamFile) error {
+	gzWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzWriter)
+	seen := map[string]bool{}
+
+	for _, f := range files {
+		err := func() error {
+			r, err := packagePool.Open(f.PoolPath)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			gzReader, err := gzip.NewReader(r)
+			if err != nil {
+				return err
+			}
+			defer gzReader.Close()
+
+			tarReader := tar.NewReader(gzReader)
+			for {
+				header, err := tarReader.Next()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				if seen[header.Name] {
+					continue
+				}
+				seen[header.Name] = true
+
+				if err = tarWriter.WriteHeader(header); err != nil {
+					return err
+				}
+				if _, err = io.Copy(tarWriter, tarReader); err != nil {
+					return err
+				}
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzWriter.Close()
+}
@@ -0,0 +1,202 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+const exampleAppStreamComponents = `---
+File: DEP-11
+Version: '0.12'
+Origin: debian-squeeze-main
+---
+Type: desktop-application
+ID: org.example.invaders
+Package: mars-invaders
+Name:
+  C: Mars Invaders
+---
+Type: desktop-application
+ID: org.example.editor
+Package: editor
+Name:
+  C: Editor
+`
+
+type AppStreamSuite struct {
+	packagePool aptly.PackagePool
+	cs          aptly.ChecksumStorage
+}
+
+var _ = Suite(&AppStreamSuite{})
+
+func (s *AppStreamSuite) SetUpTest(c *C) {
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+	s.cs = files.NewMockChecksumStorage()
+}
+
+func checksumsFor(content string) utils.ChecksumInfo {
+	w := utils.NewChecksumWriter()
+	w.Write([]byte(content))
+	return w.Sum()
+}
+
+func iconsTarball(c *C, names ...string) string {
+	var buf bytes.Buffer
+
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+	for _, name := range names {
+		c.Assert(tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name))}), IsNil)
+		_, err := tarWriter.Write([]byte(name))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tarWriter.Close(), IsNil)
+	c.Assert(gzWriter.Close(), IsNil)
+
+	return buf.String()
+}
+
+func (s *AppStreamSuite) importFile(c *C, component, name, content string) AppStreamFile {
+	path := filepath.Join(c.MkDir(), name)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+	checksums := checksumsFor(content)
+	poolPath, err := s.packagePool.Import(path, name, &checksums, false, s.cs)
+	c.Assert(err, IsNil)
+
+	return AppStreamFile{Component: component, Name: name, PoolPath: poolPath, Checksums: checksums}
+}
+
+func (s *AppStreamSuite) TestArchitecture(c *C) {
+	c.Check((&AppStreamFile{Name: "Components-amd64.yml"}).Architecture(), Equals, "amd64")
+	c.Check((&AppStreamFile{Name: "icons-64x64.tar.gz"}).Architecture(), Equals, "")
+}
+
+func (s *AppStreamSuite) TestFilterComponents(c *C) {
+	var buf bytes.Buffer
+
+	c.Assert(filterAppStreamComponents(&buf, bytes.NewBufferString(exampleAppStreamComponents), nil, false), IsNil)
+	c.Check(buf.String(), Equals, exampleAppStreamComponents)
+
+	buf.Reset()
+	c.Assert(filterAppStreamComponents(&buf, bytes.NewBufferString(exampleAppStreamComponents), map[string]bool{"mars-invaders": true}, false), IsNil)
+	c.Check(buf.String(), Equals, "---\nFile: DEP-11\nVersion: '0.12'\nOrigin: debian-squeeze-main\n"+
+		"---\nType: desktop-application\nID: org.example.invaders\nPackage: mars-invaders\nName:\n  C: Mars Invaders\n")
+
+	buf.Reset()
+	c.Assert(filterAppStreamComponents(&buf, bytes.NewBufferString(exampleAppStreamComponents), map[string]bool{}, true), IsNil)
+	c.Check(buf.String(), Equals, "")
+}
+
+func (s *AppStreamSuite) TestDownload(c *C) {
+	repo, _ := NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian", "squeeze", []string{"main"}, []string{"i386", "amd64"}, false, false, false)
+	icons := iconsTarball(c, "64x64/invaders.png")
+	repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		"main/dep11/Components-i386.yml":  checksumsFor(exampleAppStreamComponents),
+		"main/dep11/icons-64x64.tar.gz":   checksumsFor(icons),
+		"main/dep11/icons-64x64@2.tar.gz": checksumsFor(icons),
+		"main/binary-i386/Packages":       checksumsFor(""),
+	}
+	repo.Filter = "mars-invaders"
+	repo.packageList = NewPackageList()
+	stanza := packageStanza.Copy()
+	stanza["Package"] = "mars-invaders"
+	repo.packageList.Add(NewPackageFromControlFile(stanza))
+
+	// disabled
+	d := http.NewFakeDownloader()
+	c.Assert(repo.DownloadAppStreamFiles(d, s.packagePool, s.cs, false), IsNil)
+	c.Check(repo.AppStreamFiles, IsNil)
+
+	repo.DownloadAppStream = true
+	d.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/dep11/Components-i386.yml", exampleAppStreamComponents)
+	d.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/dep11/icons-64x64.tar.gz", icons)
+	d.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/dep11/icons-64x64@2.tar.gz", icons)
+
+	c.Assert(repo.DownloadAppStreamFiles(d, s.packagePool, s.cs, false), IsNil)
+	c.Check(d.Empty(), Equals, true)
+
+	var names []string
+	for _, f := range repo.AppStreamFiles {
+		c.Check(f.Component, Equals, "main")
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	c.Check(names, DeepEquals, []string{"Components-i386.yml", "icons-64x64.tar.gz", "icons-64x64@2.tar.gz"})
+
+	r, err := s.packagePool.Open(repo.AppStreamFiles[0].PoolPath)
+	c.Assert(err, IsNil)
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	c.Check(string(content), Not(Matches), "(?s).*Package: editor.*")
+	c.Check(string(content), Matches, "(?s).*File: DEP-11.*Package: mars-invaders.*")
+
+	// checksum mismatch
+	repo.ReleaseFiles["main/dep11/icons-64x64.tar.gz"] = checksumsFor("other")
+	d.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/dep11/Components-i386.yml", exampleAppStreamComponents)
+	d.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/dep11/icons-64x64.tar.gz", icons)
+	c.Check(repo.DownloadAppStreamFiles(d, s.packagePool, s.cs, false), ErrorMatches, ".*checksums don't match.*")
+}
+
+func (s *AppStreamSuite) TestPublish(c *C) {
+	root := c.MkDir()
+	storage := files.NewPublishedStorage(root, "", "")
+	indexes := newIndexFiles(storage, "dists/squeeze", c.MkDir(), "", false, []string{utils.CompressionGzip})
+
+	appStreamFiles := []AppStreamFile{
+		s.importFile(c, "main", "Components-i386.yml", exampleAppStreamComponents),
+		s.importFile(c, "main", "Components-amd64.yml", exampleAppStreamComponents),
+		s.importFile(c, "main", "icons-64x64.tar.gz", iconsTarball(c, "64x64/invaders.png", "64x64/editor.png")),
+		s.importFile(c, "contrib", "Components-i386.yml", "---\nFile: DEP-11\n---\nPackage: game\n"),
+		s.importFile(c, "contrib", "icons-64x64.tar.gz", iconsTarball(c, "64x64/game.png", "64x64/editor.png")),
+	}
+
+	c.Assert(publishAppStream(indexes, s.packagePool, "main", appStreamFiles, []string{"i386", "source"}), IsNil)
+	c.Assert(indexes.FinalizeAll(nil, nil), IsNil)
+
+	c.Check(indexes.generatedFiles["main/dep11/Components-i386.yml"], NotNil)
+	c.Check(indexes.generatedFiles["main/dep11/Components-i386.yml.gz"], NotNil)
+	_, ok := indexes.generatedFiles["main/dep11/Components-amd64.yml"]
+	c.Check(ok, Equals, false)
+	c.Check(indexes.generatedFiles["main/dep11/icons-64x64.tar.gz"], NotNil)
+
+	_, err := os.Stat(filepath.Join(root, "dists/squeeze/main/dep11/Components-i386.yml"))
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	file, err := ioutil.ReadFile(filepath.Join(root, "dists/squeeze/main/dep11/Components-i386.yml.gz"))
+	c.Assert(err, IsNil)
+	gzReader, err := gzip.NewReader(bytes.NewReader(file))
+	c.Assert(err, IsNil)
+	content, _ := ioutil.ReadAll(gzReader)
+	c.Check(string(content), Equals, exampleAppStreamComponents+"---\nPackage: game\n")
+
+	file, err = ioutil.ReadFile(filepath.Join(root, "dists/squeeze/main/dep11/icons-64x64.tar.gz"))
+	c.Assert(err, IsNil)
+	gzReader, err = gzip.NewReader(bytes.NewReader(file))
+	c.Assert(err, IsNil)
+	tarReader := tar.NewReader(gzReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		names = append(names, header.Name)
+	}
+	c.Check(names, DeepEquals, []string{"64x64/invaders.png", "64x64/editor.png", "64x64/game.png"})
+}
@@ -0,0 +1,177 @@
+package deb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aptly-dev/aptly/http"
+)
+
+// ConnectionSettings configures TLS, proxy and authentication used to access the mirror
+//
+// Credentials are stored in the database, but they're never included
+// into JSON output or into mirror description
+type ConnectionSettings struct {
+	// Path to PEM-encoded CA certificates trusted in addition to system ones
+	CAFile string `json:",omitempty"`
+	// Paths to PEM-encoded client certificate and key for mutual TLS
+	ClientCertFile string `json:",omitempty"`
+	ClientKeyFile  string `json:",omitempty"`
+	// Skip verification of server certificate (insecure)
+	InsecureSkipVerify bool `json:",omitempty"`
+	// Proxy URL, overrides proxy configured via environment
+	ProxyURL string `json:",omitempty"`
+	// HTTP basic authentication credentials
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	// HTTP bearer token
+	BearerToken string `json:",omitempty"`
+}
+
+// IsEmpty checks whether settings configure anything
+func (settings *ConnectionSettings) IsEmpty() bool {
+	return settings == nil || *settings == ConnectionSettings{}
+}
+
+// Validate checks settings for consistency
+func (settings *ConnectionSettings) Validate() error {
+	if (settings.ClientCertFile == "") != (settings.ClientKeyFile == "") {
+		return fmt.Errorf("both client certificate and key should be specified")
+	}
+
+	if settings.BearerToken != "" && (settings.Username != "" || settings.Password != "") {
+		return fmt.Errorf("bearer token and basic authentication credentials can't be used together")
+	}
+
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("unable to parse proxy URL: %s", err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("proxy URL should be absolute: %s", redactURL(settings.ProxyURL))
+		}
+	}
+
+	return nil
+}
+
+// Update returns settings with changes received from the client applied on top of them
+//
+// Client sees settings as JSON output, so credentials which are hidden there are kept
+// when incoming value is empty, and proxy URL with redacted password is kept as stored
+func (settings *ConnectionSettings) Update(incoming *ConnectionSettings) (*ConnectionSettings, error) {
+	stored := ConnectionSettings{}
+	if settings != nil {
+		stored = *settings
+	}
+
+	result := *incoming
+
+	if result.ProxyURL != "" && result.ProxyURL == redactURL(stored.ProxyURL) {
+		result.ProxyURL = stored.ProxyURL
+	} else if isRedactedURL(result.ProxyURL) {
+		return nil, fmt.Errorf("proxy URL should contain actual password instead of %s", redactedPassword)
+	}
+
+	switch {
+	case incoming.BearerToken != "":
+		// switching to bearer token drops stored basic authentication credentials
+	case incoming.Username != "" || incoming.Password != "":
+		if result.Username == "" {
+			result.Username = stored.Username
+		}
+		if result.Password == "" {
+			result.Password = stored.Password
+		}
+	default:
+		result.Username, result.Password, result.BearerToken = stored.Username, stored.Password, stored.BearerToken
+	}
+
+	return &result, nil
+}
+
+// Apply copies settings to downloader options
+func (settings *ConnectionSettings) Apply(options *http.DownloaderOptions) {
+	if settings == nil {
+		return
+	}
+
+	options.CAFile = settings.CAFile
+	options.ClientCertFile = settings.ClientCertFile
+	options.ClientKeyFile = settings.ClientKeyFile
+	options.InsecureSkipVerify = settings.InsecureSkipVerify
+	options.ProxyURL = settings.ProxyURL
+	options.Username = settings.Username
+	options.Password = settings.Password
+	options.BearerToken = settings.BearerToken
+}
+
+// MarshalJSON hides credentials from JSON output
+func (settings ConnectionSettings) MarshalJSON() ([]byte, error) {
+	type plain ConnectionSettings
+
+	result := plain(settings)
+	result.Username, result.Password, result.BearerToken = "", "", ""
+	result.ProxyURL = redactURL(result.ProxyURL)
+
+	return json.Marshal(result)
+}
+
+// String returns human-readable description of the settings, without credentials
+func (settings *ConnectionSettings) String() string {
+	if settings.IsEmpty() {
+		return "defaults"
+	}
+
+	var parts []string
+	if settings.CAFile != "" {
+		parts = append(parts, fmt.Sprintf("CA file %s", settings.CAFile))
+	}
+	if settings.ClientCertFile != "" {
+		parts = append(parts, fmt.Sprintf("client certificate %s", settings.ClientCertFile))
+	}
+	if settings.InsecureSkipVerify {
+		parts = append(parts, "insecure TLS")
+	}
+	if settings.ProxyURL != "" {
+		parts = append(parts, fmt.Sprintf("proxy %s", redactURL(settings.ProxyURL)))
+	}
+	if settings.BearerToken != "" {
+		parts = append(parts, "bearer token authentication")
+	} else if settings.Username != "" || settings.Password != "" {
+		parts = append(parts, "basic authentication")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// redactedPassword replaces passwords in URLs shown to the user
+const redactedPassword = "xxxxx"
+
+// redactURL replaces password in URL with redactedPassword
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedPassword)
+	}
+
+	return u.String()
+}
+
+// isRedactedURL checks if URL carries redactedPassword instead of actual one
+func isRedactedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return false
+	}
+
+	password, _ := u.User.Password()
+	return password == redactedPassword
+}
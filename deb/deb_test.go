@@ -11,7 +11,7 @@ import (
 )
 
 type DebSuite struct {
-	debFile, debFile2, debFileWithXzControl, dscFile, dscFileNoSign string
+	debFile, debFile2, debFileWithXzControl, debFileWithZstd, dscFile, dscFileNoSign string
 }
 
 var _ = Suite(&DebSuite{})
@@ -21,6 +21,7 @@ func (s *DebSuite) SetUpSuite(c *C) {
 	s.debFile = filepath.Join(filepath.Dir(_File), "../system/files/libboost-program-options-dev_1.49.0.1_i386.deb")
 	s.debFile2 = filepath.Join(filepath.Dir(_File), "../system/changes/hardlink_0.2.1_amd64.deb")
 	s.debFileWithXzControl = filepath.Join(filepath.Dir(_File), "../system/changes/libqt5concurrent5-dbgsym_5.9.1+dfsg-2+18.04+bionic+build4_amd64.ddeb")
+	s.debFileWithZstd = filepath.Join(filepath.Dir(_File), "testdata/zstdpkg_1.0-1_all.deb")
 	s.dscFile = filepath.Join(filepath.Dir(_File), "../system/files/pyspi_0.6.1-1.3.dsc")
 	s.dscFileNoSign = filepath.Join(filepath.Dir(_File), "../system/files/pyspi-0.6.1-1.3.stripped.dsc")
 }
@@ -47,6 +48,14 @@ func (s *DebSuite) TestGetControlFileFromDebWithXzControl(c *C) {
 	c.Check(st["Package"], Equals, "libqt5concurrent5-dbgsym")
 }
 
+func (s *DebSuite) TestGetControlFileFromDebWithZstd(c *C) {
+	// Has control.tar.zst archive inside.
+	st, err := GetControlFileFromDeb(s.debFileWithZstd)
+	c.Check(err, IsNil)
+	c.Check(st["Version"], Equals, "1.0-1")
+	c.Check(st["Package"], Equals, "zstdpkg")
+}
+
 func (s *DebSuite) TestGetControlFileFromDsc(c *C) {
 	verifier := &pgp.GoVerifier{}
 
@@ -84,4 +93,11 @@ func (s *DebSuite) TestGetContentsFromDeb(c *C) {
 	c.Check(contents, DeepEquals, []string{"usr/bin/hardlink", "usr/share/man/man1/hardlink.1.gz",
 		"usr/share/doc/hardlink/changelog.gz", "usr/share/doc/hardlink/copyright", "usr/share/doc/hardlink/NEWS.Debian.gz"})
 	c.Assert(f.Close(), IsNil)
+
+	f, err = os.Open(s.debFileWithZstd)
+	c.Assert(err, IsNil)
+	contents, err = GetContentsFromDeb(f, s.debFileWithZstd)
+	c.Check(err, IsNil)
+	c.Check(contents, DeepEquals, []string{"usr/share/doc/zstdpkg/copyright"})
+	c.Assert(f.Close(), IsNil)
 }
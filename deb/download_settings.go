@@ -0,0 +1,101 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/http"
+)
+
+// DownloadSettings overrides global download settings for the mirror,
+// zero values mean that global settings are used
+type DownloadSettings struct {
+	// Number of parallel downloads
+	Concurrency int `json:",omitempty"`
+	// Download speed limit, kbytes/sec
+	SpeedLimit int64 `json:",omitempty"`
+	// Number of retries for failed downloads
+	Retries int `json:",omitempty"`
+	// Speed limits for parts of the day in format HH:MM-HH:MM=<kbytes/sec>
+	BandwidthWindows []string `json:",omitempty"`
+}
+
+// IsEmpty checks whether settings override anything
+func (settings *DownloadSettings) IsEmpty() bool {
+	return settings == nil || (settings.Concurrency <= 0 && settings.SpeedLimit <= 0 && settings.Retries <= 0 && len(settings.BandwidthWindows) == 0)
+}
+
+// Validate checks settings for consistency
+func (settings *DownloadSettings) Validate() error {
+	if settings.Concurrency < 0 || settings.SpeedLimit < 0 || settings.Retries < 0 {
+		return fmt.Errorf("download settings values should be non-negative")
+	}
+
+	_, err := settings.Windows()
+	return err
+}
+
+// Windows parses bandwidth windows
+func (settings *DownloadSettings) Windows() ([]http.BandwidthWindow, error) {
+	if settings == nil {
+		return nil, nil
+	}
+
+	result := make([]http.BandwidthWindow, len(settings.BandwidthWindows))
+	for i := range settings.BandwidthWindows {
+		var err error
+		result[i], err = http.ParseBandwidthWindow(settings.BandwidthWindows[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// GetConcurrency returns number of parallel downloads, falling back to global setting
+func (settings *DownloadSettings) GetConcurrency(global int) int {
+	if settings == nil || settings.Concurrency <= 0 {
+		return global
+	}
+	return settings.Concurrency
+}
+
+// GetSpeedLimit returns download speed limit, falling back to global setting
+func (settings *DownloadSettings) GetSpeedLimit(global int64) int64 {
+	if settings == nil || settings.SpeedLimit <= 0 {
+		return global
+	}
+	return settings.SpeedLimit
+}
+
+// GetRetries returns number of retries, falling back to global setting
+func (settings *DownloadSettings) GetRetries(global int) int {
+	if settings == nil || settings.Retries <= 0 {
+		return global
+	}
+	return settings.Retries
+}
+
+// String returns human-readable description of the settings
+func (settings *DownloadSettings) String() string {
+	if settings.IsEmpty() {
+		return "defaults"
+	}
+
+	var parts []string
+	if settings.Concurrency > 0 {
+		parts = append(parts, fmt.Sprintf("concurrency %d", settings.Concurrency))
+	}
+	if settings.SpeedLimit > 0 {
+		parts = append(parts, fmt.Sprintf("speed limit %d kB/s", settings.SpeedLimit))
+	}
+	if settings.Retries > 0 {
+		parts = append(parts, fmt.Sprintf("retries %d", settings.Retries))
+	}
+	if len(settings.BandwidthWindows) > 0 {
+		parts = append(parts, fmt.Sprintf("bandwidth windows %s", strings.Join(settings.BandwidthWindows, ", ")))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,51 @@
+package deb
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type DownloadSettingsSuite struct{}
+
+var _ = Suite(&DownloadSettingsSuite{})
+
+func (s *DownloadSettingsSuite) TestIsEmpty(c *C) {
+	c.Check((*DownloadSettings)(nil).IsEmpty(), Equals, true)
+	c.Check((&DownloadSettings{}).IsEmpty(), Equals, true)
+	c.Check((&DownloadSettings{Retries: 2}).IsEmpty(), Equals, false)
+	c.Check((&DownloadSettings{BandwidthWindows: []string{"09:00-18:00=100"}}).IsEmpty(), Equals, false)
+
+	c.Check((*DownloadSettings)(nil).String(), Equals, "defaults")
+	c.Check((&DownloadSettings{Concurrency: 2, SpeedLimit: 512, BandwidthWindows: []string{"09:00-18:00=100", "22:00-06:00=0"}}).String(), Equals,
+		"concurrency 2, speed limit 512 kB/s, bandwidth windows 09:00-18:00=100, 22:00-06:00=0")
+}
+
+func (s *DownloadSettingsSuite) TestValidate(c *C) {
+	c.Check((&DownloadSettings{Concurrency: 2}).Validate(), IsNil)
+	c.Check((&DownloadSettings{Retries: -1}).Validate(), ErrorMatches, "download settings values should be non-negative")
+	c.Check((&DownloadSettings{BandwidthWindows: []string{"09:00"}}).Validate(), ErrorMatches, "wrong bandwidth window .*")
+}
+
+func (s *DownloadSettingsSuite) TestGetters(c *C) {
+	var settings *DownloadSettings
+
+	c.Check(settings.GetConcurrency(4), Equals, 4)
+	c.Check(settings.GetSpeedLimit(100), Equals, int64(100))
+	c.Check(settings.GetRetries(1), Equals, 1)
+
+	windows, err := settings.Windows()
+	c.Check(err, IsNil)
+	c.Check(windows, HasLen, 0)
+
+	settings = &DownloadSettings{Concurrency: 1, SpeedLimit: 50, BandwidthWindows: []string{"22:00-06:00=10"}}
+	c.Check(settings.GetConcurrency(4), Equals, 1)
+	c.Check(settings.GetSpeedLimit(100), Equals, int64(50))
+	c.Check(settings.GetRetries(1), Equals, 1)
+
+	windows, err = settings.Windows()
+	c.Check(err, IsNil)
+	c.Assert(windows, HasLen, 1)
+	c.Check(windows[0].Start, Equals, 22*time.Hour)
+	c.Check(windows[0].Limit, Equals, int64(10*1024))
+}
@@ -0,0 +1,320 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// Database dump format
+//
+// Dump is a gzip-compressed stream of JSON objects, one per line (JSON Lines).
+// First line is a header with format name and version, followed by records
+// (raw key/value pairs grouped by collection), last line is a trailer with number
+// of records and SHA256 checksum of all the preceding lines (uncompressed).
+const (
+	DumpFormat        = "aptly-db-dump"
+	DumpFormatVersion = 1
+)
+
+const (
+	dumpHeader  = "header"
+	dumpRecord  = "record"
+	dumpTrailer = "trailer"
+)
+
+// dumpCollections lists collections in the order they're exported,
+// with key prefixes owned by each collection
+var dumpCollections = []struct {
+	name     string
+	prefixes []string
+}{
+	{"mirrors", []string{"R"}},
+	{"repos", []string{"L"}},
+	{"snapshots", []string{"S"}},
+	{"published", []string{"U"}},
+	{"reflists", []string{"E"}},
+	{"packages", []string{"P", "xF", "xD", "xE", "xC"}},
+	{"checksums", []string{"C"}},
+}
+
+// ErrDatabaseNotEmpty is returned when importing dump into database which already has some data
+var ErrDatabaseNotEmpty = errors.New("database is not empty")
+
+// DumpStats is number of records per collection in the dump
+type DumpStats map[string]int
+
+// Total returns total number of records
+func (stats DumpStats) Total() (total int) {
+	for _, count := range stats {
+		total += count
+	}
+
+	return
+}
+
+type dumpEntry struct {
+	Type string `json:"type"`
+
+	// header
+	Format  string `json:"format,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Created string `json:"created,omitempty"`
+
+	// record
+	Collection string `json:"collection,omitempty"`
+	Key        []byte `json:"key,omitempty"`
+	Value      []byte `json:"value,omitempty"`
+
+	// trailer
+	Records     int       `json:"records,omitempty"`
+	Collections DumpStats `json:"collections,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+}
+
+// dumpWriter writes dump entries, calculating checksum on the fly
+type dumpWriter struct {
+	w    io.Writer
+	hash hash.Hash
+}
+
+func (d *dumpWriter) write(entry *dumpEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if d.hash != nil {
+		d.hash.Write(line)
+	}
+
+	_, err = d.w.Write(line)
+	return err
+}
+
+// Export writes contents of all the collections as database dump
+func (factory *CollectionFactory) Export(w io.Writer) (DumpStats, error) {
+	gz := gzip.NewWriter(w)
+
+	dump := &dumpWriter{w: gz, hash: sha256.New()}
+	stats := DumpStats{}
+
+	err := dump.write(&dumpEntry{
+		Type:    dumpHeader,
+		Format:  DumpFormat,
+		Version: DumpFormatVersion,
+		Created: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, collection := range dumpCollections {
+		stats[collection.name] = 0
+
+		for _, prefix := range collection.prefixes {
+			err = factory.db.ProcessByPrefix([]byte(prefix), func(key, value []byte) error {
+				stats[collection.name]++
+
+				return dump.write(&dumpEntry{
+					Type:       dumpRecord,
+					Collection: collection.name,
+					Key:        key,
+					Value:      value,
+				})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to export %s: %s", collection.name, err)
+			}
+		}
+	}
+
+	checksum := fmt.Sprintf("%x", dump.hash.Sum(nil))
+	dump.hash = nil
+
+	err = dump.write(&dumpEntry{
+		Type:        dumpTrailer,
+		Records:     stats.Total(),
+		Collections: stats,
+		SHA256:      checksum,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, gz.Close()
+}
+
+// Import loads database dump into the database
+//
+// Database should be empty, if import fails for any reason (e.g. dump is
+// truncated or corrupted), everything imported so far is removed
+func (factory *CollectionFactory) Import(r io.Reader) (DumpStats, error) {
+	if factory.db.HasPrefix(nil) {
+		return nil, ErrDatabaseNotEmpty
+	}
+
+	stats, err := factory.importDump(r)
+	if err != nil {
+		if cleanupErr := factory.dropAll(); cleanupErr != nil {
+			return nil, fmt.Errorf("%s (cleanup failed: %s)", err, cleanupErr)
+		}
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (factory *CollectionFactory) importDump(r io.Reader) (DumpStats, error) {
+	const batchSize = 1000
+
+	reader := bufio.NewReader(r)
+
+	// accept uncompressed dumps as well
+	magic, err := reader.Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		reader = bufio.NewReader(gz)
+	}
+
+	prefixes := map[string][]string{}
+	for _, collection := range dumpCollections {
+		prefixes[collection.name] = collection.prefixes
+	}
+
+	var (
+		checksum = sha256.New()
+		stats    = DumpStats{}
+		batch    = factory.db.CreateBatch()
+		pending  int
+		lineNo   int
+		trailer  *dumpEntry
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		lineNo++
+
+		if trailer != nil {
+			return nil, fmt.Errorf("line %d: unexpected data after trailer", lineNo)
+		}
+
+		var entry dumpEntry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNo, err)
+		}
+
+		if lineNo == 1 {
+			if entry.Type != dumpHeader || entry.Format != DumpFormat {
+				return nil, fmt.Errorf("not an aptly database dump")
+			}
+			if entry.Version < 1 || entry.Version > DumpFormatVersion {
+				return nil, fmt.Errorf("unsupported dump format version %d", entry.Version)
+			}
+
+			checksum.Write(line)
+			continue
+		}
+
+		switch entry.Type {
+		case dumpRecord:
+			collectionPrefixes, ok := prefixes[entry.Collection]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown collection %q", lineNo, entry.Collection)
+			}
+
+			if !hasAnyPrefix(entry.Key, collectionPrefixes) {
+				return nil, fmt.Errorf("line %d: key doesn't belong to collection %q", lineNo, entry.Collection)
+			}
+
+			if err = batch.Put(entry.Key, entry.Value); err != nil {
+				return nil, err
+			}
+
+			stats[entry.Collection]++
+			pending++
+
+			if pending == batchSize {
+				if err = batch.Write(); err != nil {
+					return nil, err
+				}
+				batch = factory.db.CreateBatch()
+				pending = 0
+			}
+
+			checksum.Write(line)
+		case dumpTrailer:
+			trailer = &entry
+		default:
+			return nil, fmt.Errorf("line %d: unexpected entry %q", lineNo, entry.Type)
+		}
+	}
+
+	if trailer == nil {
+		return nil, fmt.Errorf("dump is truncated: trailer is missing")
+	}
+
+	if trailer.SHA256 != fmt.Sprintf("%x", checksum.Sum(nil)) {
+		return nil, fmt.Errorf("dump is corrupted: checksum mismatch")
+	}
+
+	if trailer.Records != stats.Total() {
+		return nil, fmt.Errorf("dump is corrupted: expected %d records, got %d", trailer.Records, stats.Total())
+	}
+
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+
+	for _, collection := range dumpCollections {
+		if _, ok := stats[collection.name]; !ok {
+			stats[collection.name] = 0
+		}
+	}
+
+	return stats, nil
+}
+
+// dropAll removes all the keys from the database
+func (factory *CollectionFactory) dropAll() error {
+	batch := factory.db.CreateBatch()
+
+	for _, key := range factory.db.KeysByPrefix(nil) {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return batch.Write()
+}
+
+func hasAnyPrefix(key []byte, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type DumpSuite struct {
+	db, db2           database.Storage
+	factory, factory2 *CollectionFactory
+	localRepo         *LocalRepo
+}
+
+var _ = Suite(&DumpSuite{})
+
+func (s *DumpSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.db2, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+	s.factory2 = NewCollectionFactory(s.db2)
+
+	list := NewPackageList()
+	for _, p := range []*Package{
+		{Name: "lib", Version: "1.7", Architecture: "i386"},
+		{Name: "app", Version: "1.9", Architecture: "amd64"},
+	} {
+		c.Assert(s.factory.PackageCollection().Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	s.localRepo = NewLocalRepo("local1", "Comment 1")
+	s.localRepo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.factory.LocalRepoCollection().Add(s.localRepo), IsNil)
+
+	snapshot, err := NewSnapshotFromLocalRepo("snap1", s.localRepo)
+	c.Assert(err, IsNil)
+	c.Assert(s.factory.SnapshotCollection().Add(snapshot), IsNil)
+}
+
+func (s *DumpSuite) TearDownTest(c *C) {
+	s.db.Close()
+	s.db2.Close()
+}
+
+func (s *DumpSuite) export(c *C) []byte {
+	var buf bytes.Buffer
+
+	stats, err := s.factory.Export(&buf)
+	c.Assert(err, IsNil)
+	c.Check(stats["repos"], Equals, 1)
+	c.Check(stats["snapshots"], Equals, 1)
+	c.Check(stats["reflists"], Equals, 2)
+	c.Check(stats["mirrors"], Equals, 0)
+
+	return buf.Bytes()
+}
+
+func (s *DumpSuite) TestExportImport(c *C) {
+	dump := s.export(c)
+
+	stats, err := s.factory2.Import(bytes.NewReader(dump))
+	c.Assert(err, IsNil)
+	c.Check(stats["repos"], Equals, 1)
+	c.Check(stats["snapshots"], Equals, 1)
+	c.Check(stats.Total(), Equals, len(s.db.KeysByPrefix(nil)))
+
+	c.Check(s.db2.KeysByPrefix(nil), DeepEquals, s.db.KeysByPrefix(nil))
+	c.Check(s.db2.FetchByPrefix(nil), DeepEquals, s.db.FetchByPrefix(nil))
+
+	repo, err := s.factory2.LocalRepoCollection().ByName("local1")
+	c.Assert(err, IsNil)
+	c.Assert(s.factory2.LocalRepoCollection().LoadComplete(repo), IsNil)
+	c.Check(repo.NumPackages(), Equals, 2)
+
+	snapshot, err := s.factory2.SnapshotCollection().ByName("snap1")
+	c.Assert(err, IsNil)
+	c.Assert(s.factory2.SnapshotCollection().LoadComplete(snapshot), IsNil)
+	c.Check(snapshot.NumPackages(), Equals, 2)
+}
+
+func (s *DumpSuite) TestImportUncompressed(c *C) {
+	gz, err := gzip.NewReader(bytes.NewReader(s.export(c)))
+	c.Assert(err, IsNil)
+	plain, err := ioutil.ReadAll(gz)
+	c.Assert(err, IsNil)
+
+	_, err = s.factory2.Import(bytes.NewReader(plain))
+	c.Assert(err, IsNil)
+	c.Check(s.db2.KeysByPrefix(nil), DeepEquals, s.db.KeysByPrefix(nil))
+}
+
+func (s *DumpSuite) TestImportNotEmpty(c *C) {
+	_, err := s.factory.Import(bytes.NewReader(s.export(c)))
+	c.Check(err, ErrorMatches, "database is not empty")
+}
+
+func (s *DumpSuite) TestImportBroken(c *C) {
+	gz, _ := gzip.NewReader(bytes.NewReader(s.export(c)))
+	plain, _ := ioutil.ReadAll(gz)
+	lines := strings.SplitAfter(string(plain), "\n")
+
+	_, err := s.factory2.Import(strings.NewReader("{\"type\":\"record\"}\n"))
+	c.Check(err, ErrorMatches, "not an aptly database dump")
+
+	_, err = s.factory2.Import(strings.NewReader(strings.Replace(lines[0], "\"version\":1", "\"version\":1000", 1)))
+	c.Check(err, ErrorMatches, "unsupported dump format version 1000")
+
+	// truncated: no trailer
+	_, err = s.factory2.Import(strings.NewReader(strings.Join(lines[:len(lines)-2], "")))
+	c.Check(err, ErrorMatches, "dump is truncated: trailer is missing")
+	c.Check(s.db2.HasPrefix(nil), Equals, false)
+
+	// record dropped
+	_, err = s.factory2.Import(strings.NewReader(lines[0] + strings.Join(lines[2:], "")))
+	c.Check(err, ErrorMatches, "dump is corrupted: checksum mismatch")
+	c.Check(s.db2.HasPrefix(nil), Equals, false)
+
+	// record moved to another collection
+	_, err = s.factory2.Import(strings.NewReader(strings.Replace(string(plain), "\"collection\":\"repos\"", "\"collection\":\"mirrors\"", 1)))
+	c.Check(err, ErrorMatches, "line \\d+: key doesn't belong to collection \"mirrors\"")
+	c.Check(s.db2.HasPrefix(nil), Equals, false)
+}
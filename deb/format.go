@@ -26,6 +26,7 @@ var (
 		"Version",
 		"Codename",
 		"Date",
+		"Valid-Until",
 		"NotAutomatic",
 		"ButAutomaticUpgrades",
 		"Architectures",
@@ -110,6 +111,8 @@ func isMultilineField(field string, isRelease bool) bool {
 		return true
 	case "Description":
 		return true
+	case "Description-en":
+		return true
 	case "Files":
 		return true
 	case "Changes":
@@ -145,7 +148,7 @@ func writeField(w *bufio.Writer, field, value string, isRelease bool) (err error
 			value = value + "\n"
 		}
 
-		if field != "Description" && field != "" {
+		if field != "Description" && field != "Description-en" && field != "" {
 			value = "\n" + value
 		}
 
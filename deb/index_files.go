@@ -21,21 +21,24 @@ type indexFiles struct {
 	tempDir          string
 	suffix           string
 	indexes          map[string]*indexFile
+	finalized        []*indexFile
 	acquireByHash    bool
+	compression      []string
 }
 
 type indexFile struct {
-	parent        *indexFiles
-	discardable   bool
-	compressable  bool
-	onlyGzip      bool
-	clearSign     bool
-	detachedSign  bool
-	acquireByHash bool
-	relativePath  string
-	tempFilename  string
-	tempFile      *os.File
-	w             *bufio.Writer
+	parent         *indexFiles
+	discardable    bool
+	compressable   bool
+	onlyCompressed bool
+	releaseIndex   bool
+	clearSign      bool
+	detachedSign   bool
+	acquireByHash  bool
+	relativePath   string
+	tempFilename   string
+	tempFile       *os.File
+	w              *bufio.Writer
 }
 
 func (file *indexFile) BufWriter() (*bufio.Writer, error) {
@@ -53,6 +56,33 @@ func (file *indexFile) BufWriter() (*bufio.Writer, error) {
 	return file.w, nil
 }
 
+// compressionFormats returns list of formats index file should be published in
+//
+// Files marked as onlyCompressed (Contents) are never published uncompressed
+// and skip bzip2, as it is too slow for such large files
+func (file *indexFile) compressionFormats() []string {
+	if !file.compressable {
+		return nil
+	}
+
+	if !file.onlyCompressed {
+		return file.parent.compression
+	}
+
+	var formats []string
+	for _, format := range file.parent.compression {
+		if format != utils.CompressionUncompressed && format != utils.CompressionBzip2 {
+			formats = append(formats, format)
+		}
+	}
+
+	if len(formats) == 0 {
+		formats = []string{utils.CompressionGzip}
+	}
+
+	return formats
+}
+
 func (file *indexFile) Finalize(signer pgp.Signer) error {
 	if file.w == nil {
 		if file.discardable {
@@ -67,8 +97,10 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 		return fmt.Errorf("unable to write to index file: %s", err)
 	}
 
+	formats := file.compressionFormats()
+
 	if file.compressable {
-		err = utils.CompressFile(file.tempFile, file.onlyGzip)
+		err = utils.CompressFile(file.tempFile, formats)
 		if err != nil {
 			file.tempFile.Close()
 			return fmt.Errorf("unable to compress index file: %s", err)
@@ -77,17 +109,7 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 
 	file.tempFile.Close()
 
-	exts := []string{""}
-	cksumExts := exts
-	if file.compressable {
-		exts = append(exts, ".gz", ".bz2")
-		cksumExts = exts
-		if file.onlyGzip {
-			exts = []string{".gz"}
-			cksumExts = []string{"", ".gz"}
-		}
-	}
-
+	_, cksumExts := file.extensions()
 	for _, ext := range cksumExts {
 		var checksumInfo utils.ChecksumInfo
 
@@ -98,9 +120,38 @@ func (file *indexFile) Finalize(signer pgp.Signer) error {
 		file.parent.generatedFiles[file.relativePath+ext] = checksumInfo
 	}
 
+	return file.publish(signer)
+}
+
+// extensions returns extensions index file is published with and extensions
+// of the files listed in Release
+func (file *indexFile) extensions() (exts, cksumExts []string) {
+	exts = []string{""}
+	cksumExts = exts
+	if file.compressable {
+		// uncompressed file is always listed in Release, even if not published
+		exts = nil
+		cksumExts = []string{""}
+		for _, format := range file.compressionFormats() {
+			ext := utils.CompressionExtension(format)
+			exts = append(exts, ext)
+			if ext != "" {
+				cksumExts = append(cksumExts, ext)
+			}
+		}
+	}
+
+	return
+}
+
+// publish puts finalized index file into published storage along with by-hash
+// links and signatures
+func (file *indexFile) publish(signer pgp.Signer) error {
+	exts, _ := file.extensions()
+
 	filedir := filepath.Dir(filepath.Join(file.parent.basePath, file.relativePath))
 
-	err = file.parent.publishedStorage.MkDir(filedir)
+	err := file.parent.publishedStorage.MkDir(filedir)
 	if err != nil {
 		return fmt.Errorf("unable to create dir: %s", err)
 	}
@@ -229,7 +280,11 @@ func packageIndexByHash(file *indexFile, ext string, hash string, sum string) er
 	return nil
 }
 
-func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, suffix string, acquireByHash bool) *indexFiles {
+func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, suffix string, acquireByHash bool, compression []string) *indexFiles {
+	if len(compression) == 0 {
+		compression = utils.DefaultCompression
+	}
+
 	return &indexFiles{
 		publishedStorage: publishedStorage,
 		basePath:         basePath,
@@ -239,6 +294,7 @@ func newIndexFiles(publishedStorage aptly.PublishedStorage, basePath, tempDir, s
 		suffix:           suffix,
 		indexes:          make(map[string]*indexFile),
 		acquireByHash:    acquireByHash,
+		compression:      compression,
 	}
 }
 
@@ -302,6 +358,7 @@ func (files *indexFiles) ReleaseIndex(component, arch string, udeb bool) *indexF
 			parent:        files,
 			discardable:   udeb,
 			compressable:  false,
+			releaseIndex:  true,
 			detachedSign:  false,
 			clearSign:     false,
 			acquireByHash: files.acquireByHash,
@@ -330,14 +387,14 @@ func (files *indexFiles) ContentsIndex(component, arch string, udeb bool) *index
 		}
 
 		file = &indexFile{
-			parent:        files,
-			discardable:   true,
-			compressable:  true,
-			onlyGzip:      true,
-			detachedSign:  false,
-			clearSign:     false,
-			acquireByHash: files.acquireByHash,
-			relativePath:  relativePath,
+			parent:         files,
+			discardable:    true,
+			compressable:   true,
+			onlyCompressed: true,
+			detachedSign:   false,
+			clearSign:      false,
+			acquireByHash:  files.acquireByHash,
+			relativePath:   relativePath,
 		}
 
 		files.indexes[key] = file
@@ -361,15 +418,59 @@ func (files *indexFiles) LegacyContentsIndex(arch string, udeb bool) *indexFile
 			relativePath = fmt.Sprintf("Contents-%s", arch)
 		}
 
+		file = &indexFile{
+			parent:         files,
+			discardable:    true,
+			compressable:   true,
+			onlyCompressed: true,
+			detachedSign:   false,
+			clearSign:      false,
+			acquireByHash:  files.acquireByHash,
+			relativePath:   relativePath,
+		}
+
+		files.indexes[key] = file
+	}
+
+	return file
+}
+
+func (files *indexFiles) TranslationIndex(component, lang string) *indexFile {
+	key := fmt.Sprintf("ti-%s-%s", component, lang)
+	file, ok := files.indexes[key]
+	if !ok {
 		file = &indexFile{
 			parent:        files,
 			discardable:   true,
 			compressable:  true,
-			onlyGzip:      true,
 			detachedSign:  false,
 			clearSign:     false,
 			acquireByHash: files.acquireByHash,
-			relativePath:  relativePath,
+			relativePath:  filepath.Join(component, "i18n", fmt.Sprintf("Translation-%s", lang)),
+		}
+
+		files.indexes[key] = file
+	}
+
+	return file
+}
+
+func (files *indexFiles) AppStreamIndex(component, name string) *indexFile {
+	key := fmt.Sprintf("as-%s-%s", component, name)
+	file, ok := files.indexes[key]
+	if !ok {
+		// components metadata is published compressed only, icon tarballs are published as is
+		compressable := strings.HasSuffix(name, ".yml")
+
+		file = &indexFile{
+			parent:         files,
+			discardable:    true,
+			compressable:   compressable,
+			onlyCompressed: compressable,
+			detachedSign:   false,
+			clearSign:      false,
+			acquireByHash:  files.acquireByHash,
+			relativePath:   appStreamPath(component, name),
 		}
 
 		files.indexes[key] = file
@@ -400,6 +501,9 @@ func (files *indexFiles) FinalizeAll(progress aptly.Progress, signer pgp.Signer)
 		if err != nil {
 			return
 		}
+		if file.tempFilename != "" {
+			files.finalized = append(files.finalized, file)
+		}
 		if progress != nil {
 			progress.AddBar(1)
 		}
@@ -410,6 +514,32 @@ func (files *indexFiles) FinalizeAll(progress aptly.Progress, signer pgp.Signer)
 	return
 }
 
+// CopyFrom publishes index files finalized by source without generating them again,
+// Release files of components are skipped as they are specific to published repository
+func (files *indexFiles) CopyFrom(source *indexFiles, signer pgp.Signer) error {
+	for _, file := range source.finalized {
+		if file.releaseIndex {
+			continue
+		}
+
+		copied := *file
+		copied.parent = files
+		copied.acquireByHash = files.acquireByHash
+
+		_, cksumExts := file.extensions()
+		for _, ext := range cksumExts {
+			files.generatedFiles[file.relativePath+ext] = source.generatedFiles[file.relativePath+ext]
+		}
+
+		err := copied.publish(signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (files *indexFiles) RenameFiles() error {
 	var err error
 
@@ -0,0 +1,129 @@
+package deb
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// MatchInstallerFlavour checks whether installer file (path relative to images/)
+// belongs to one of the flavours
+//
+// Flavour is a glob matching directory under images/, e.g. "netboot" or "cdrom/*";
+// file matches if any of its parent directories matches. Files directly under
+// images/ (MANIFEST, udeb.list, ...) are always included
+func MatchInstallerFlavour(filename string, flavours []string) bool {
+	if len(flavours) == 0 {
+		return true
+	}
+
+	dir := path.Dir(strings.TrimPrefix(path.Clean(filename), "/"))
+	if dir == "." {
+		return true
+	}
+
+	for ; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		for _, flavour := range flavours {
+			if matched, _ := path.Match(flavour, dir); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ValidateInstallerFlavours checks flavour globs for syntax errors
+func ValidateInstallerFlavours(flavours []string) error {
+	for _, flavour := range flavours {
+		if flavour == "" {
+			return fmt.Errorf("installer flavour can't be empty")
+		}
+		if _, err := path.Match(flavour, ""); err != nil {
+			return fmt.Errorf("wrong installer flavour %q: %s", flavour, err)
+		}
+	}
+
+	return nil
+}
+
+// installerDir returns path of installer-<arch> directory relative to the prefix
+//
+// distDir is directory metadata is published to, e.g. dists/<distribution>
+func installerDir(distDir, component string, p *Package, arch string) string {
+	return filepath.Join(distDir, component, fmt.Sprintf("%s-%s", p.Name, arch))
+}
+
+// installerTreeVersion returns name of the directory installer files are published to
+//
+// It is derived from the list of files, so that the same installer tree is always
+// published to the same directory
+func installerTreeVersion(p *Package) string {
+	return fmt.Sprintf("%016x", p.FilesHash)
+}
+
+// installerPublishPath returns path of installer images relative to the prefix
+//
+// On filesystem storage installer is published Debian-style: files are placed
+// under installer-<arch>/<version>/images and installer-<arch>/current is a symlink
+// to the version directory. Other storages don't support symlinks, so files are
+// placed under installer-<arch>/current/images directly
+func installerPublishPath(publishedStorage aptly.PublishedStorage, installerDir string, p *Package) string {
+	if _, ok := publishedStorage.(aptly.FileSystemPublishedStorage); ok {
+		return filepath.Join(installerDir, installerTreeVersion(p), "images")
+	}
+
+	return filepath.Join(installerDir, "current", "images")
+}
+
+// linkInstallerCurrent points installer-<arch>/current symlink to the version directory
+// of the installer package, previous version directory is removed
+func linkInstallerCurrent(publishedStorage aptly.PublishedStorage, prefix, installerDir string, p *Package) error {
+	localStorage, ok := publishedStorage.(aptly.FileSystemPublishedStorage)
+	if !ok {
+		return nil
+	}
+
+	dir := filepath.Join(localStorage.PublicPath(), prefix, installerDir)
+	current := filepath.Join(dir, "current")
+	version := installerTreeVersion(p)
+
+	var previous string
+
+	stat, err := os.Lstat(current)
+	if err == nil {
+		if stat.Mode()&os.ModeSymlink != 0 {
+			previous, err = os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			if previous == version {
+				return nil
+			}
+			err = os.Remove(current)
+		} else {
+			// installer published by previous versions of aptly directly to current/
+			err = os.RemoveAll(current)
+		}
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Symlink(version, current)
+	if err != nil {
+		return err
+	}
+
+	if previous != "" && !filepath.IsAbs(previous) && !strings.Contains(previous, "..") {
+		return os.RemoveAll(filepath.Join(dir, previous))
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package deb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/files"
+
+	. "gopkg.in/check.v1"
+)
+
+type InstallerSuite struct{}
+
+var _ = Suite(&InstallerSuite{})
+
+func (s *InstallerSuite) TestMatchInstallerFlavour(c *C) {
+	c.Check(MatchInstallerFlavour("./netboot/mini.iso", nil), Equals, true)
+
+	flavours := []string{"netboot", "cdrom/gtk*"}
+
+	c.Check(MatchInstallerFlavour("./MANIFEST", flavours), Equals, true)
+	c.Check(MatchInstallerFlavour("udeb.list", flavours), Equals, true)
+	c.Check(MatchInstallerFlavour("./netboot/mini.iso", flavours), Equals, true)
+	c.Check(MatchInstallerFlavour("./netboot/debian-installer/amd64/linux", flavours), Equals, true)
+	c.Check(MatchInstallerFlavour("./netboot-gtk/mini.iso", flavours), Equals, false)
+	c.Check(MatchInstallerFlavour("./cdrom/vmlinuz", flavours), Equals, false)
+	c.Check(MatchInstallerFlavour("./cdrom/gtk/vmlinuz", flavours), Equals, true)
+	c.Check(MatchInstallerFlavour("./hd-media/boot.img.gz", flavours), Equals, false)
+
+	c.Check(ValidateInstallerFlavours(flavours), IsNil)
+	c.Check(ValidateInstallerFlavours([]string{"cdrom/["}), ErrorMatches, "wrong installer flavour \"cdrom/\\[\": syntax error in pattern")
+	c.Check(ValidateInstallerFlavours([]string{""}), ErrorMatches, "installer flavour can't be empty")
+}
+
+func (s *InstallerSuite) TestLinkInstallerCurrent(c *C) {
+	root := c.MkDir()
+	storage := files.NewPublishedStorage(root, "", "")
+	dir := filepath.Join(root, "ppa", "dists", "stretch", "main", "installer-amd64")
+
+	p1 := &Package{Name: "installer", Architecture: "amd64", IsInstaller: true, FilesHash: 0x1234}
+	p2 := &Package{Name: "installer", Architecture: "amd64", IsInstaller: true, FilesHash: 0xabcd}
+
+	c.Check(installerDir("dists/stretch", "main", p1, "amd64"), Equals, "dists/stretch/main/installer-amd64")
+	c.Check(installerPublishPath(storage, "dists/stretch/main/installer-amd64", p1), Equals, "dists/stretch/main/installer-amd64/0000000000001234/images")
+	c.Check(installerPublishPath(nil, "dists/stretch/main/installer-amd64", p1), Equals, "dists/stretch/main/installer-amd64/current/images")
+
+	// installer published by older aptly directly to current/
+	c.Assert(os.MkdirAll(filepath.Join(dir, "current", "images"), 0777), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dir, "0000000000001234", "images"), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "0000000000001234", "images", "MANIFEST"), []byte("manifest"), 0644), IsNil)
+
+	c.Assert(linkInstallerCurrent(storage, "ppa", "dists/stretch/main/installer-amd64", p1), IsNil)
+	target, err := os.Readlink(filepath.Join(dir, "current"))
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "0000000000001234")
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "current", "images", "MANIFEST"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "manifest")
+
+	// same version: nothing changes
+	c.Assert(linkInstallerCurrent(storage, "ppa", "dists/stretch/main/installer-amd64", p1), IsNil)
+	target, _ = os.Readlink(filepath.Join(dir, "current"))
+	c.Check(target, Equals, "0000000000001234")
+
+	// new version: symlink is switched, previous version is removed
+	c.Assert(os.MkdirAll(filepath.Join(dir, "000000000000abcd", "images"), 0777), IsNil)
+	c.Assert(linkInstallerCurrent(storage, "ppa", "dists/stretch/main/installer-amd64", p2), IsNil)
+	target, _ = os.Readlink(filepath.Join(dir, "current"))
+	c.Check(target, Equals, "000000000000abcd")
+
+	_, err = os.Stat(filepath.Join(dir, "0000000000001234"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
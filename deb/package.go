@@ -173,6 +173,9 @@ func NewUdebPackageFromControlFile(input Stanza) *Package {
 }
 
 // NewInstallerPackageFromControlFile creates a dummy installer Package from parsed hash sum file
+//
+// Only files matching installer flavours of the mirror are included, if there are
+// no such files, nil package is returned
 func NewInstallerPackageFromControlFile(input Stanza, repo *RemoteRepo, component, architecture string, d aptly.Downloader) (*Package, error) {
 	p := &Package{
 		Name:         "installer",
@@ -189,6 +192,20 @@ func NewInstallerPackageFromControlFile(input Stanza, repo *RemoteRepo, componen
 		return nil, err
 	}
 
+	if len(repo.InstallerFlavours) > 0 {
+		filtered := make(PackageFiles, 0, len(files))
+		for _, f := range files {
+			if MatchInstallerFlavour(f.Filename, repo.InstallerFlavours) {
+				filtered = append(filtered, f)
+			}
+		}
+		files = filtered
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
 	relPath := filepath.Join("dists", repo.Distribution, component, fmt.Sprintf("%s-%s", p.Name, architecture), "current", "images")
 	for i := range files {
 		files[i].downloadPath = relPath
@@ -667,7 +684,11 @@ type PackageDownloadTask struct {
 	File         *PackageFile
 	Additional   []PackageDownloadTask
 	TempDownPath string
-	Done         bool
+	// LocalPath is set if file comes from local archive (file://), file is imported from there
+	LocalPath string
+	Done      bool
+	// URLs to download file from, in order of preference
+	URLs []string
 }
 
 // DownloadList returns list of missing package files for download in format
@@ -18,7 +18,8 @@ type PackageCollection struct {
 
 // Verify interface
 var (
-	_ PackageCatalog = &PackageCollection{}
+	_ PackageCatalog   = &PackageCollection{}
+	_ PackageKeyLookup = &PackageCollection{}
 )
 
 // NewPackageCollection creates new PackageCollection and binds it to database
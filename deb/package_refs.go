@@ -0,0 +1,141 @@
+package deb
+
+import (
+	"fmt"
+)
+
+// Kinds of objects referencing packages
+const (
+	RefSourceMirror    = "mirror"
+	RefSourceLocalRepo = "local repo"
+	RefSourceSnapshot  = "snapshot"
+	RefSourcePublished = "published repository"
+)
+
+// RefSourceKinds lists kinds of objects referencing packages in the order they're walked
+var RefSourceKinds = []string{RefSourceMirror, RefSourceLocalRepo, RefSourceSnapshot, RefSourcePublished}
+
+// PackageRefSource is a list of packages referenced by mirror, local repo, snapshot
+// or (component of) published repository
+type PackageRefSource struct {
+	// Human-readable description, e.g. "mirror wheezy-main"
+	Description string
+	// Referenced packages
+	RefList *PackageRefList
+}
+
+// PackageRefs describes packages referenced by single mirror, local repo, snapshot or
+// published repository
+type PackageRefs struct {
+	// Kind of the object, one of RefSourceKinds
+	Kind string
+	// Name of the object, storage:prefix/distribution for published repositories
+	Name string
+	// Lists of referenced packages, might be empty
+	Sources []PackageRefSource
+	// AppStream files referenced by mirror or snapshot
+	AppStreamFiles []AppStreamFile
+}
+
+// ForEachPackageRefs calls handler for every object of the kind, which might reference packages:
+// mirrors, local repos, snapshots and published repositories (including kept generations)
+func (factory *CollectionFactory) ForEachPackageRefs(kind string, handler func(refs *PackageRefs) error) error {
+	switch kind {
+	case RefSourceMirror:
+		collection := factory.RemoteRepoCollection()
+		return collection.ForEach(func(repo *RemoteRepo) error {
+			if e := collection.LoadComplete(repo); e != nil {
+				return e
+			}
+
+			refs := &PackageRefs{Kind: kind, Name: repo.Name, AppStreamFiles: repo.AppStreamFiles}
+			if repo.RefList() != nil {
+				refs.Sources = append(refs.Sources, PackageRefSource{
+					Description: fmt.Sprintf("mirror %s", repo.Name), RefList: repo.RefList()})
+			}
+			return handler(refs)
+		})
+	case RefSourceLocalRepo:
+		collection := factory.LocalRepoCollection()
+		return collection.ForEach(func(repo *LocalRepo) error {
+			if e := collection.LoadComplete(repo); e != nil {
+				return e
+			}
+
+			refs := &PackageRefs{Kind: kind, Name: repo.Name}
+			if repo.RefList() != nil {
+				refs.Sources = append(refs.Sources, PackageRefSource{
+					Description: fmt.Sprintf("local repo %s", repo.Name), RefList: repo.RefList()})
+			}
+			return handler(refs)
+		})
+	case RefSourceSnapshot:
+		collection := factory.SnapshotCollection()
+		return collection.ForEach(func(snapshot *Snapshot) error {
+			if e := collection.LoadComplete(snapshot); e != nil {
+				return e
+			}
+
+			return handler(&PackageRefs{
+				Kind:           kind,
+				Name:           snapshot.Name,
+				AppStreamFiles: snapshot.AppStreamFiles,
+				Sources: []PackageRefSource{{
+					Description: fmt.Sprintf("snapshot %s", snapshot.Name), RefList: snapshot.RefList()}},
+			})
+		})
+	case RefSourcePublished:
+		collection := factory.PublishedRepoCollection()
+		return collection.ForEach(func(published *PublishedRepo) error {
+			name := fmt.Sprintf("%s:%s/%s", published.Storage, published.Prefix, published.Distribution)
+			refs := &PackageRefs{Kind: kind, Name: name}
+
+			// packages published by previous generations are kept for rollback
+			for _, component := range published.GenerationComponents() {
+				refList, e := collection.GenerationsRefList(published, component)
+				if e != nil {
+					return e
+				}
+				refs.Sources = append(refs.Sources, PackageRefSource{
+					Description: fmt.Sprintf("published repository %s component %s (kept generations)", name, component),
+					RefList:     refList})
+			}
+
+			// packages published from snapshots are referenced by snapshots
+			if published.SourceKind == SourceLocalRepo {
+				if e := collection.LoadComplete(published, factory); e != nil {
+					return e
+				}
+				for _, component := range published.Components() {
+					refs.Sources = append(refs.Sources, PackageRefSource{
+						Description: fmt.Sprintf("published repository %s component %s", name, component),
+						RefList:     published.RefList(component)})
+				}
+			}
+
+			return handler(refs)
+		})
+	}
+
+	return fmt.Errorf("unknown kind of package references: %s", kind)
+}
+
+// ReferencedPackages returns list of packages referenced by mirrors, local repos,
+// snapshots and published repositories (including kept generations)
+func (factory *CollectionFactory) ReferencedPackages() (*PackageRefList, error) {
+	result := NewPackageRefList()
+
+	for _, kind := range RefSourceKinds {
+		err := factory.ForEachPackageRefs(kind, func(refs *PackageRefs) error {
+			for _, source := range refs.Sources {
+				result = result.Merge(source.RefList, false, true)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,539 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// PDiff support
+//
+// Debian archives might publish Packages.diff/Index next to the package index,
+// which lists ed-style patches that bring older versions of the index
+// up to date. Uncompressed indexes downloaded during previous mirror updates are kept
+// in the index cache, so that on next update only small patches are downloaded.
+
+// errPDiffNotAvailable is returned when PDiff can't be used (no cached index,
+// no Packages.diff/Index in the Release file), it's not worth reporting
+var errPDiffNotAvailable = errors.New("pdiff is not available")
+
+// pdiffEntry is a single line of Packages.diff/Index
+type pdiffEntry struct {
+	hash string
+	size int64
+	name string
+}
+
+// pdiffIndex is parsed Packages.diff/Index
+type pdiffIndex struct {
+	// hash algorithm used in the index: SHA256 or SHA1
+	algorithm string
+	current   pdiffEntry
+	history   []pdiffEntry
+	patches   map[string]pdiffEntry
+	download  map[string]pdiffEntry
+	// merged patches bring any history entry straight to current version
+	merged bool
+}
+
+// parsePDiffIndex parses Packages.diff/Index file
+func parsePDiffIndex(r io.Reader) (*pdiffIndex, error) {
+	stanza, err := NewControlFileReader(r, false, false).ReadStanza()
+	if err != nil {
+		return nil, err
+	}
+	if stanza == nil {
+		return nil, fmt.Errorf("empty pdiff index")
+	}
+
+	index := &pdiffIndex{
+		patches:  map[string]pdiffEntry{},
+		download: map[string]pdiffEntry{},
+		merged:   stanza["X-Patch-Precedence"] == "merged",
+	}
+
+	for _, algorithm := range []string{"SHA256", "SHA1"} {
+		if _, ok := stanza[canonicalCase(algorithm+"-Current")]; ok {
+			index.algorithm = algorithm
+			break
+		}
+	}
+
+	if index.algorithm == "" {
+		return nil, fmt.Errorf("pdiff index doesn't have supported checksums")
+	}
+
+	current := stanza[canonicalCase(index.algorithm+"-Current")]
+	parts := strings.Fields(current)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unparseable pdiff current line: %#v", current)
+	}
+	index.current.hash = parts[0]
+	index.current.size, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse size: %s", err)
+	}
+
+	parseEntries := func(name string) ([]pdiffEntry, error) {
+		field := index.algorithm + "-" + name
+		parts := strings.Fields(stanza[canonicalCase(field)])
+		if len(parts)%3 != 0 {
+			return nil, fmt.Errorf("unparseable pdiff %s field", field)
+		}
+
+		result := make([]pdiffEntry, 0, len(parts)/3)
+		for i := 0; i < len(parts); i += 3 {
+			size, err := strconv.ParseInt(parts[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse size: %s", err)
+			}
+
+			result = append(result, pdiffEntry{hash: parts[i], size: size, name: parts[i+2]})
+		}
+
+		return result, nil
+	}
+
+	index.history, err = parseEntries("History")
+	if err != nil {
+		return nil, err
+	}
+
+	patches, err := parseEntries("Patches")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range patches {
+		index.patches[entry.name] = entry
+	}
+
+	downloads, err := parseEntries("Download")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range downloads {
+		index.download[strings.TrimSuffix(entry.name, ".gz")] = entry
+	}
+
+	return index, nil
+}
+
+// patchesFor returns list of patches to be applied to the index with specified hash
+// to get current version, ok is false if hash is unknown
+func (index *pdiffIndex) patchesFor(hash string) (names []string, ok bool) {
+	if hash == index.current.hash {
+		return nil, true
+	}
+
+	for i, entry := range index.history {
+		if entry.hash != hash {
+			continue
+		}
+
+		if index.merged {
+			return []string{entry.name}, true
+		}
+
+		for _, next := range index.history[i:] {
+			names = append(names, next.name)
+		}
+
+		return names, true
+	}
+
+	return nil, false
+}
+
+// checksum builds ChecksumInfo for the entry
+func (index *pdiffIndex) checksum(entry pdiffEntry) *utils.ChecksumInfo {
+	result := &utils.ChecksumInfo{Size: entry.size}
+	if index.algorithm == "SHA256" {
+		result.SHA256 = entry.hash
+	} else {
+		result.SHA1 = entry.hash
+	}
+
+	return result
+}
+
+// edCommand is a single command of ed script (as produced by diff --ed)
+type edCommand struct {
+	op         byte
+	start, end int
+	lines      []string
+}
+
+// parseEdScript parses ed script, returning commands in ascending order of line numbers
+func parseEdScript(r io.Reader) ([]edCommand, error) {
+	reader := bufio.NewReader(r)
+
+	var commands []edCommand
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return nil, fmt.Errorf("malformed ed script: empty command")
+		}
+
+		cmd := edCommand{op: line[len(line)-1]}
+
+		lineRange := strings.SplitN(line[:len(line)-1], ",", 2)
+		cmd.start, err = strconv.Atoi(lineRange[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed ed script: %#v", line)
+		}
+		cmd.end = cmd.start
+		if len(lineRange) == 2 {
+			cmd.end, err = strconv.Atoi(lineRange[1])
+			if err != nil || cmd.end < cmd.start {
+				return nil, fmt.Errorf("malformed ed script: %#v", line)
+			}
+		}
+
+		switch cmd.op {
+		case 'a', 'c':
+			for {
+				text, err := reader.ReadString('\n')
+				if err == io.EOF && text == "" {
+					return nil, fmt.Errorf("malformed ed script: unterminated text for %#v", line)
+				}
+				if err != nil && err != io.EOF {
+					return nil, err
+				}
+				if text == ".\n" || text == "." {
+					break
+				}
+				cmd.lines = append(cmd.lines, text)
+			}
+		case 'd':
+		default:
+			return nil, fmt.Errorf("malformed ed script: unsupported command %#v", line)
+		}
+
+		if cmd.op != 'a' && cmd.start < 1 {
+			return nil, fmt.Errorf("malformed ed script: %#v", line)
+		}
+
+		// diff --ed lists commands from the end of file to the beginning
+		if len(commands) > 0 {
+			prev := commands[len(commands)-1]
+			if cmd.end >= prev.start {
+				return nil, fmt.Errorf("malformed ed script: commands are not in descending order")
+			}
+		}
+
+		commands = append(commands, cmd)
+	}
+
+	for i, j := 0, len(commands)-1; i < j; i, j = i+1, j-1 {
+		commands[i], commands[j] = commands[j], commands[i]
+	}
+
+	return commands, nil
+}
+
+// applyEdPatch applies ed script patch to src, writing result to dst
+func applyEdPatch(dst io.Writer, src io.Reader, patch io.Reader) error {
+	commands, err := parseEdScript(patch)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(src)
+	writer := bufio.NewWriter(dst)
+	lineNo := 0
+
+	// copyUntil copies source lines till line number n (inclusive)
+	copyUntil := func(n int, keep bool) error {
+		for lineNo < n {
+			line, err := reader.ReadString('\n')
+			if err == io.EOF && line == "" {
+				return io.ErrUnexpectedEOF
+			}
+			if err != nil && err != io.EOF {
+				return err
+			}
+			lineNo++
+
+			if keep {
+				if _, err = writer.WriteString(line); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for _, cmd := range commands {
+		switch cmd.op {
+		case 'a':
+			err = copyUntil(cmd.start, true)
+		case 'c', 'd':
+			err = copyUntil(cmd.start-1, true)
+			if err == nil {
+				err = copyUntil(cmd.end, false)
+			}
+		}
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("patch refers to line %d, but file has only %d lines", cmd.end, lineNo)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, line := range cmd.lines {
+			if _, err = writer.WriteString(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err = io.Copy(writer, reader); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+// IndexCacheDir returns path to the cache of package indexes of this mirror
+func (repo *RemoteRepo) IndexCacheDir(indexCachePath string) string {
+	return filepath.Join(indexCachePath, repo.UUID)
+}
+
+// downloadIndexPDiff tries to build current version of the index by patching
+// cached copy from previous update
+//
+// Result is verified against checksums from the Release file
+func (repo *RemoteRepo) downloadIndexPDiff(d aptly.Downloader, path string, cacheDir string) (*os.File, error) {
+	expected, ok := repo.ReleaseFiles[path]
+	if !ok || (expected.SHA256 == "" && expected.SHA1 == "") {
+		return nil, errPDiffNotAvailable
+	}
+
+	indexChecksum, ok := repo.ReleaseFiles[path+".diff/Index"]
+	if !ok {
+		return nil, errPDiffNotAvailable
+	}
+
+	cached, err := os.Open(filepath.Join(cacheDir, path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errPDiffNotAvailable
+		}
+		return nil, err
+	}
+
+	indexFile, err := http.DownloadTempWithChecksum(gocontext.TODO(), d,
+		repo.IndexesRootURL().ResolveReference(&url.URL{Path: path + ".diff/Index"}).String(), &indexChecksum, false)
+	if err != nil {
+		cached.Close()
+		return nil, err
+	}
+	index, err := parsePDiffIndex(indexFile)
+	indexFile.Close()
+	if err != nil {
+		cached.Close()
+		return nil, err
+	}
+
+	cachedChecksum, err := checksumForReader(cached)
+	if err != nil {
+		cached.Close()
+		return nil, err
+	}
+
+	cachedHash := cachedChecksum.SHA1
+	if index.algorithm == "SHA256" {
+		cachedHash = cachedChecksum.SHA256
+	}
+
+	names, ok := index.patchesFor(cachedHash)
+	if !ok {
+		cached.Close()
+		return nil, fmt.Errorf("cached index is too old")
+	}
+
+	var downloadSize int64
+	for _, name := range names {
+		downloadSize += index.download[name].size
+	}
+	for _, ext := range []string{".gz", ".xz", ".bz2"} {
+		if full, ok := repo.ReleaseFiles[path+ext]; ok && downloadSize >= full.Size {
+			cached.Close()
+			return nil, fmt.Errorf("patches are larger than full index")
+		}
+	}
+
+	current := cached
+	for _, name := range names {
+		var patched *os.File
+
+		patched, err = repo.applyPDiffPatch(d, path, index, name, current)
+		current.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		current = patched
+	}
+
+	actual, err := checksumForReader(current)
+	if err != nil {
+		current.Close()
+		return nil, err
+	}
+
+	if actual.Size != expected.Size ||
+		(expected.SHA256 != "" && actual.SHA256 != expected.SHA256) ||
+		(expected.SHA256 == "" && actual.SHA1 != expected.SHA1) {
+		current.Close()
+		return nil, fmt.Errorf("checksum mismatch after applying patches")
+	}
+
+	return current, nil
+}
+
+// applyPDiffPatch downloads single patch and applies it to src, returning new temporary file
+func (repo *RemoteRepo) applyPDiffPatch(d aptly.Downloader, path string, index *pdiffIndex, name string, src *os.File) (*os.File, error) {
+	patchURL := repo.IndexesRootURL().ResolveReference(&url.URL{Path: path + ".diff/" + name + ".gz"}).String()
+
+	var expected *utils.ChecksumInfo
+	if entry, ok := index.download[name]; ok {
+		expected = index.checksum(entry)
+	}
+
+	patchFile, err := http.DownloadTempWithChecksum(gocontext.TODO(), d, patchURL, expected, false)
+	if err != nil {
+		return nil, err
+	}
+	defer patchFile.Close()
+
+	gz, err := gzip.NewReader(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	patch, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry, ok := index.patches[name]; ok {
+		w := utils.NewChecksumWriter()
+		w.Write(patch)
+		actual, want := w.Sum(), index.checksum(entry)
+		if actual.Size != want.Size || (want.SHA256 != "" && actual.SHA256 != want.SHA256) ||
+			(want.SHA1 != "" && actual.SHA1 != want.SHA1) {
+			return nil, fmt.Errorf("%s: checksum mismatch", patchURL)
+		}
+	}
+
+	patched, err := ioutil.TempFile("", "aptly-pdiff")
+	if err != nil {
+		return nil, err
+	}
+	// temporary file is removed right away, it stays available until closed
+	os.Remove(patched.Name())
+
+	if err = applyEdPatch(patched, src, bytes.NewReader(patch)); err != nil {
+		patched.Close()
+		return nil, fmt.Errorf("unable to apply %s: %s", patchURL, err)
+	}
+
+	if _, err = patched.Seek(0, 0); err != nil {
+		patched.Close()
+		return nil, err
+	}
+
+	return patched, nil
+}
+
+// checksumForReader calculates checksums of the file contents, rewinding it to the beginning
+func checksumForReader(f *os.File) (utils.ChecksumInfo, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return utils.ChecksumInfo{}, err
+	}
+
+	w := utils.NewChecksumWriter()
+	if _, err := io.Copy(w, f); err != nil {
+		return utils.ChecksumInfo{}, err
+	}
+
+	_, err := f.Seek(0, 0)
+
+	return w.Sum(), err
+}
+
+// indexCacheWriter stores copy of the index in the cache, replacing
+// previous version only when the whole index has been written
+type indexCacheWriter struct {
+	path string
+	file *os.File
+}
+
+func newIndexCacheWriter(path string) (*indexCacheWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path + ".new")
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexCacheWriter{path: path, file: file}, nil
+}
+
+func (w *indexCacheWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// Commit replaces cached index with new version
+func (w *indexCacheWriter) Commit() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(w.path+".new", w.path)
+}
+
+// Abort removes incomplete copy, it's no-op after Commit
+func (w *indexCacheWriter) Abort() {
+	if w.file == nil {
+		return
+	}
+
+	w.file.Close()
+	w.file = nil
+	os.Remove(w.path + ".new")
+}
@@ -0,0 +1,218 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type PDiffSuite struct {
+	repo       *RemoteRepo
+	cachePath  string
+	downloader *http.FakeDownloader
+	factory    *CollectionFactory
+}
+
+var _ = Suite(&PDiffSuite{})
+
+func (s *PDiffSuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian", "squeeze", []string{"main"}, []string{"i386"}, false, false, false)
+	s.cachePath = c.MkDir()
+	s.downloader = http.NewFakeDownloader()
+	s.factory = NewCollectionFactory(nil)
+}
+
+func pdiffChecksum(data string) utils.ChecksumInfo {
+	w := utils.NewChecksumWriter()
+	w.Write([]byte(data))
+	return w.Sum()
+}
+
+func pdiffGzip(data string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(data))
+	gz.Close()
+	return buf.String()
+}
+
+func (s *PDiffSuite) TestApplyEdPatch(c *C) {
+	src := "1\n2\n3\n4\n5\n"
+
+	for _, t := range []struct {
+		patch, result string
+	}{
+		{"", src},
+		{"0a\nzero\n.\n", "zero\n1\n2\n3\n4\n5\n"},
+		{"5a\nsix\nseven\n.\n", "1\n2\n3\n4\n5\nsix\nseven\n"},
+		{"2,3d\n", "1\n4\n5\n"},
+		{"4c\nfour\n.\n1d\n", "2\n3\nfour\n5\n"},
+		{"5d\n3a\nthree+\n.\n1,2c\none\n.\n", "one\n3\nthree+\n4\n"},
+	} {
+		var out bytes.Buffer
+		c.Check(applyEdPatch(&out, strings.NewReader(src), strings.NewReader(t.patch)), IsNil, Commentf("%q", t.patch))
+		c.Check(out.String(), Equals, t.result, Commentf("%q", t.patch))
+	}
+
+	for _, t := range []struct {
+		patch, err string
+	}{
+		{"1,2x\n", "malformed ed script: unsupported command \"1,2x\""},
+		{"a\n", "malformed ed script: \"a\""},
+		{"3,1d\n", "malformed ed script: \"3,1d\""},
+		{"1a\nfoo\n", "malformed ed script: unterminated text for \"1a\""},
+		{"1d\n3d\n", "malformed ed script: commands are not in descending order"},
+		{"7d\n", "patch refers to line 7, but file has only 5 lines"},
+	} {
+		var out bytes.Buffer
+		c.Check(applyEdPatch(&out, strings.NewReader(src), strings.NewReader(t.patch)), ErrorMatches, t.err)
+	}
+}
+
+func (s *PDiffSuite) TestParsePDiffIndex(c *C) {
+	index, err := parsePDiffIndex(strings.NewReader(examplePDiffIndex))
+	c.Assert(err, IsNil)
+	c.Check(index.algorithm, Equals, "SHA256")
+	c.Check(index.merged, Equals, false)
+	c.Check(index.current, Equals, pdiffEntry{hash: "9c3b1fc1b1b8d8cc7a9e4bc1e8e6c0b4d9c0e58b6c0b7ae5a0a4c7f0bfb3e7a1", size: 33713580})
+	c.Check(index.history, HasLen, 3)
+	c.Check(index.history[1], Equals, pdiffEntry{hash: "bbbb", size: 33713090, name: "2021-06-01-0812.34"})
+	c.Check(index.download["2021-06-01-1421.16"], Equals, pdiffEntry{hash: "ffff", size: 431, name: "2021-06-01-1421.16.gz"})
+	c.Check(index.patches["2021-06-01-1421.16"], Equals, pdiffEntry{hash: "cccc", size: 1242, name: "2021-06-01-1421.16"})
+
+	names, ok := index.patchesFor("bbbb")
+	c.Check(ok, Equals, true)
+	c.Check(names, DeepEquals, []string{"2021-06-01-0812.34", "2021-06-01-1421.16"})
+
+	names, ok = index.patchesFor("9c3b1fc1b1b8d8cc7a9e4bc1e8e6c0b4d9c0e58b6c0b7ae5a0a4c7f0bfb3e7a1")
+	c.Check(ok, Equals, true)
+	c.Check(names, HasLen, 0)
+
+	_, ok = index.patchesFor("0000")
+	c.Check(ok, Equals, false)
+
+	index, err = parsePDiffIndex(strings.NewReader(examplePDiffIndex + "X-Patch-Precedence: merged\n"))
+	c.Assert(err, IsNil)
+	names, _ = index.patchesFor("bbbb")
+	c.Check(names, DeepEquals, []string{"2021-06-01-0812.34"})
+
+	index, err = parsePDiffIndex(strings.NewReader("SHA1-Current: 1111 10\nSHA1-History:\n 2222 9 p1\n"))
+	c.Assert(err, IsNil)
+	c.Check(index.algorithm, Equals, "SHA1")
+	c.Check(index.checksum(index.history[0]), DeepEquals, &utils.ChecksumInfo{Size: 9, SHA1: "2222"})
+
+	_, err = parsePDiffIndex(strings.NewReader("MD5Sum-Current: 1111 10\n"))
+	c.Check(err, ErrorMatches, "pdiff index doesn't have supported checksums")
+
+	_, err = parsePDiffIndex(strings.NewReader("SHA256-Current: 1111 10\nSHA256-History:\n 2222 9\n"))
+	c.Check(err, ErrorMatches, "unparseable pdiff SHA256-History field")
+}
+
+func (s *PDiffSuite) TestDownloadPackageIndexes(c *C) {
+	const path = "main/binary-i386/Packages"
+
+	oldIndex := examplePackagesFile
+	newIndex := strings.Replace(oldIndex, "Installed-Size: 880\n", "Installed-Size: 881\n", 1)
+	lineNo := strings.Count(oldIndex[:strings.Index(oldIndex, "Installed-Size: 880\n")], "\n") + 1
+	patch := pdiffGzip(fmt.Sprintf("%dc\nInstalled-Size: 881\n.\n", lineNo))
+
+	// first update downloads full index and puts it into cache
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{path: pdiffChecksum(oldIndex)}
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", oldIndex)
+
+	err := s.repo.DownloadPackageIndexes(nil, s.downloader, nil, s.factory, false, s.cachePath)
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+
+	cached, err := ioutil.ReadFile(filepath.Join(s.cachePath, s.repo.UUID, path))
+	c.Assert(err, IsNil)
+	c.Check(string(cached), Equals, oldIndex)
+
+	// second update applies patch to cached index
+	oldSum, newSum, patchSum := pdiffChecksum(oldIndex), pdiffChecksum(newIndex), pdiffChecksum(patch)
+	index := fmt.Sprintf("SHA256-Current: %s %d\nSHA256-History:\n %s %d 2013-12-05-0814.32\nSHA256-Download:\n %s %d 2013-12-05-0814.32.gz\n",
+		newSum.SHA256, newSum.Size, oldSum.SHA256, oldSum.Size, patchSum.SHA256, patchSum.Size)
+
+	s.repo.packageList = nil
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		path:                 newSum,
+		path + ".diff/Index": pdiffChecksum(index),
+		path + ".gz":         {Size: 100000},
+	}
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/Index", index)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/2013-12-05-0814.32.gz", patch)
+
+	err = s.repo.DownloadPackageIndexes(nil, s.downloader, nil, s.factory, false, s.cachePath)
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.packageList.Len(), Equals, 1)
+
+	cached, err = ioutil.ReadFile(filepath.Join(s.cachePath, s.repo.UUID, path))
+	c.Assert(err, IsNil)
+	c.Check(string(cached), Equals, newIndex)
+
+	// cached index is current, nothing to download except for the index
+	s.repo.packageList = nil
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/Index", index)
+
+	err = s.repo.DownloadPackageIndexes(nil, s.downloader, nil, s.factory, false, s.cachePath)
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.packageList.Len(), Equals, 1)
+}
+
+func (s *PDiffSuite) TestDownloadPackageIndexesFallback(c *C) {
+	const path = "main/binary-i386/Packages"
+
+	oldIndex := examplePackagesFile
+	newIndex := strings.Replace(oldIndex, "Installed-Size: 880\n", "Installed-Size: 881\n", 1)
+	// patch is broken: it doesn't bring index to the version listed in Release file
+	patch := pdiffGzip("1d\n")
+
+	c.Assert(os.MkdirAll(filepath.Join(s.cachePath, s.repo.UUID, filepath.Dir(path)), 0777), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.cachePath, s.repo.UUID, path), []byte(oldIndex), 0644), IsNil)
+
+	oldSum, newSum, patchSum := pdiffChecksum(oldIndex), pdiffChecksum(newIndex), pdiffChecksum(patch)
+	index := fmt.Sprintf("SHA256-Current: %s %d\nSHA256-History:\n %s %d 2013-12-05-0814.32\nSHA256-Download:\n %s %d 2013-12-05-0814.32.gz\n",
+		newSum.SHA256, newSum.Size, oldSum.SHA256, oldSum.Size, patchSum.SHA256, patchSum.Size)
+
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		path:                 newSum,
+		path + ".diff/Index": pdiffChecksum(index),
+	}
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/Index", index)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.diff/2013-12-05-0814.32.gz", patch)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", newIndex)
+
+	err := s.repo.DownloadPackageIndexes(nil, s.downloader, nil, s.factory, false, s.cachePath)
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+
+	cached, err := ioutil.ReadFile(filepath.Join(s.cachePath, s.repo.UUID, path))
+	c.Assert(err, IsNil)
+	c.Check(string(cached), Equals, newIndex)
+}
+
+const examplePDiffIndex = `SHA256-Current: 9c3b1fc1b1b8d8cc7a9e4bc1e8e6c0b4d9c0e58b6c0b7ae5a0a4c7f0bfb3e7a1 33713580
+SHA256-History:
+ aaaa 33712012 2021-05-31-2011.04
+ bbbb 33713090 2021-06-01-0812.34
+ dddd 33713311 2021-06-01-1421.16
+SHA256-Patches:
+ eeee 1010 2021-05-31-2011.04
+ 9999 812 2021-06-01-0812.34
+ cccc 1242 2021-06-01-1421.16
+SHA256-Download:
+ 1234 320 2021-05-31-2011.04.gz
+ 5678 301 2021-06-01-0812.34.gz
+ ffff 431 2021-06-01-1421.16.gz
+`
@@ -0,0 +1,433 @@
+package deb
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Problems with package files found by pool verification
+const (
+	PoolFileMissing = "missing"
+	PoolFileCorrupt = "corrupt"
+)
+
+// PoolVerifyOptions controls pool verification
+type PoolVerifyOptions struct {
+	// Trust checksums cached in the database, only missing and truncated files are detected
+	Quick bool
+	// Re-download missing and corrupt files of packages coming from mirrors
+	Repair bool
+	// LockCollections, if set, is called to lock collections: shared lock is taken while
+	// packages are verified, exclusive one while repaired files are imported into the pool,
+	// it returns function to unlock collections
+	LockCollections func(exclusive bool) (unlock func())
+}
+
+// lock locks collections if locking is requested
+func (options PoolVerifyOptions) lock(exclusive bool) func() {
+	if options.LockCollections == nil {
+		return func() {}
+	}
+
+	return options.LockCollections(exclusive)
+}
+
+// PoolFileProblem describes package file which is missing or corrupt in the package pool
+type PoolFileProblem struct {
+	// Package key
+	Key string
+	// Package name, version and architecture
+	Package string
+	// File name and path in the pool
+	Filename string
+	PoolPath string
+	// Problem: missing or corrupt
+	Problem string
+	// Repaired is set if file has been re-downloaded from the mirror
+	Repaired bool
+	// RepairError is set if repair has been attempted, but failed
+	RepairError string `json:",omitempty"`
+}
+
+// String returns human-readable description of the problem
+func (problem *PoolFileProblem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", problem.Package, problem.Filename, problem.PoolPath)
+}
+
+// PoolVerifyReport is a result of package pool verification
+type PoolVerifyReport struct {
+	// Number of verified packages and files
+	Packages int
+	Files    int
+	// Missing and corrupt files
+	Problems []PoolFileProblem
+}
+
+// Unrepaired returns number of problems which haven't been repaired
+func (report *PoolVerifyReport) Unrepaired() (count int) {
+	for _, problem := range report.Problems {
+		if !problem.Repaired {
+			count++
+		}
+	}
+
+	return
+}
+
+// RecalculatingChecksumStorage wraps ChecksumStorage ignoring checksums stored in the database,
+// so that checksums of pool files are always calculated from file contents
+type RecalculatingChecksumStorage struct {
+	aptly.ChecksumStorage
+}
+
+// Get always reports checksums as unknown
+func (RecalculatingChecksumStorage) Get(path string) (*utils.ChecksumInfo, error) {
+	return nil, nil
+}
+
+// VerifyPool checks that files of all the packages referenced by mirrors, local repos,
+// snapshots and published repositories are present in the package pool and match checksums
+//
+// If repair is requested, missing and corrupt files are re-downloaded from the mirrors containing
+// the package (or mirrors snapshots with the package were created from), mirrorDownloader
+// returns downloader configured for the mirror
+func VerifyPool(factory *CollectionFactory, packagePool aptly.PackagePool, options PoolVerifyOptions,
+	mirrorDownloader func(*RemoteRepo) (aptly.Downloader, error), progress aptly.Progress) (*PoolVerifyReport, error) {
+	unlock := options.lock(false)
+	report, mirrors, err := verifyPool(factory, packagePool, options, progress)
+	unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Repair {
+		for i := range report.Problems {
+			err = repairPoolFile(factory, packagePool, options, mirrorDownloader, mirrors[report.Problems[i].Key], &report.Problems[i])
+			if err != nil {
+				report.Problems[i].RepairError = err.Error()
+			} else {
+				report.Problems[i].Repaired = true
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// checksumStorage returns checksum storage used for verification
+func (options PoolVerifyOptions) checksumStorage(factory *CollectionFactory) aptly.ChecksumStorage {
+	checksumStorage := factory.ChecksumCollection(nil)
+	if !options.Quick {
+		return RecalculatingChecksumStorage{ChecksumStorage: checksumStorage}
+	}
+
+	return checksumStorage
+}
+
+// verifyPool verifies files of referenced packages, if repair is requested it also
+// returns mirrors packages with problems could be downloaded from
+func verifyPool(factory *CollectionFactory, packagePool aptly.PackagePool, options PoolVerifyOptions,
+	progress aptly.Progress) (*PoolVerifyReport, map[string][]*RemoteRepo, error) {
+	refList, err := factory.ReferencedPackages()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load references: %s", err)
+	}
+
+	checksumStorage := options.checksumStorage(factory)
+
+	report := &PoolVerifyReport{Problems: []PoolFileProblem{}}
+
+	if progress != nil {
+		progress.InitBar(int64(refList.Len()), false)
+	}
+
+	err = refList.ForEach(func(key []byte) error {
+		if progress != nil {
+			progress.AddBar(1)
+		}
+
+		p, err := factory.PackageCollection().ByKey(key)
+		if err != nil {
+			return fmt.Errorf("unable to load package %s: %s", string(key), err)
+		}
+
+		problems, err := verifyPackageFiles(p, packagePool, checksumStorage)
+		if err != nil {
+			return fmt.Errorf("unable to verify package %s: %s", p, err)
+		}
+
+		report.Packages++
+		report.Files += len(p.Files())
+		report.Problems = append(report.Problems, problems...)
+
+		return nil
+	})
+
+	if progress != nil {
+		progress.ShutdownBar()
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mirrors map[string][]*RemoteRepo
+
+	if options.Repair && len(report.Problems) > 0 {
+		keys := map[string]bool{}
+		for _, problem := range report.Problems {
+			keys[problem.Key] = true
+		}
+
+		mirrors, err = packageMirrors(factory, keys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load mirrors: %s", err)
+		}
+	}
+
+	return report, mirrors, nil
+}
+
+// verifyPackageFiles returns list of package files which are missing or corrupt in the pool
+func verifyPackageFiles(p *Package, packagePool aptly.PackagePool, checksumStorage aptly.ChecksumStorage) ([]PoolFileProblem, error) {
+	ok, err := p.VerifyFiles(packagePool, checksumStorage)
+	if err != nil || ok {
+		return nil, err
+	}
+
+	var problems []PoolFileProblem
+
+	files := p.Files()
+	for i := range files {
+		f := &files[i]
+
+		ok, err = f.Verify(packagePool, checksumStorage)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+
+		poolPath, err := f.GetPoolPath(packagePool)
+		if err != nil {
+			return nil, err
+		}
+
+		problem := PoolFileMissing
+		if _, err = packagePool.Stat(poolPath); err == nil {
+			problem = PoolFileCorrupt
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		problems = append(problems, PoolFileProblem{
+			Key:      string(p.Key("")),
+			Package:  p.String(),
+			Filename: f.Filename,
+			PoolPath: poolPath,
+			Problem:  problem,
+		})
+	}
+
+	return problems, nil
+}
+
+// packageMirrors returns mirrors packages with the keys could be downloaded from: mirrors containing
+// the package and mirrors snapshots containing the package were created from
+func packageMirrors(factory *CollectionFactory, keys map[string]bool) (map[string][]*RemoteRepo, error) {
+	result := map[string][]*RemoteRepo{}
+	seen := map[string]bool{}
+
+	add := func(key string, repo *RemoteRepo) {
+		if !seen[key+" "+repo.UUID] {
+			result[key] = append(result[key], repo)
+			seen[key+" "+repo.UUID] = true
+		}
+	}
+
+	err := factory.RemoteRepoCollection().ForEach(func(repo *RemoteRepo) error {
+		if e := factory.RemoteRepoCollection().LoadComplete(repo); e != nil {
+			return e
+		}
+		if repo.RefList() == nil {
+			return nil
+		}
+		return repo.RefList().ForEach(func(key []byte) error {
+			if keys[string(key)] {
+				add(string(key), repo)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = factory.SnapshotCollection().ForEach(func(snapshot *Snapshot) error {
+		if snapshot.SourceKind != SourceRemoteRepo {
+			return nil
+		}
+		if e := factory.SnapshotCollection().LoadComplete(snapshot); e != nil {
+			return e
+		}
+
+		var sources []*RemoteRepo
+		for _, sourceID := range snapshot.SourceIDs {
+			repo, e := factory.RemoteRepoCollection().ByUUID(sourceID)
+			if e != nil {
+				// mirror has been dropped
+				continue
+			}
+			sources = append(sources, repo)
+		}
+		if len(sources) == 0 {
+			return nil
+		}
+
+		return snapshot.RefList().ForEach(func(key []byte) error {
+			if keys[string(key)] {
+				for _, repo := range sources {
+					add(string(key), repo)
+				}
+			}
+			return nil
+		})
+	})
+
+	return result, err
+}
+
+// packageFileURLs returns possible locations of the package file on the mirror
+//
+// Location of binary package files is not stored, so it's guessed from pool
+// layout of Debian archive
+func (repo *RemoteRepo) packageFileURLs(p *Package, f *PackageFile) ([]string, error) {
+	var paths []string
+
+	switch {
+	case p.IsInstaller:
+		return nil, fmt.Errorf("installer files can't be repaired, please update the mirror")
+	case p.IsSource:
+		paths = append(paths, filepath.Join(p.Extra()["Directory"], f.Filename))
+	case repo.IsFlat():
+		paths = append(paths, f.Filename)
+	default:
+		poolDir, err := p.PoolDirectory()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, component := range repo.Components {
+			path := filepath.Join("pool", strings.Split(component, "/")[0], poolDir, f.Filename)
+			if !utils.StrSliceHasItem(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	var urls []string
+	for _, path := range paths {
+		urls = append(urls, repo.packageURLs(path, 0)...)
+	}
+
+	return urls, nil
+}
+
+// repairPoolFile re-downloads package file from the mirror and imports it into the pool
+//
+// mirrors are the mirrors package could be downloaded from, collections are locked only
+// while downloaded file is imported
+func repairPoolFile(factory *CollectionFactory, packagePool aptly.PackagePool, options PoolVerifyOptions,
+	mirrorDownloader func(*RemoteRepo) (aptly.Downloader, error), mirrors []*RemoteRepo, problem *PoolFileProblem) error {
+	localPool, ok := packagePool.(aptly.LocalPackagePool)
+	if !ok {
+		return fmt.Errorf("repair is supported only for local package pool")
+	}
+
+	p, err := factory.PackageCollection().ByKey([]byte(problem.Key))
+	if err != nil {
+		return err
+	}
+
+	files := p.Files()
+	var f *PackageFile
+	for i := range files {
+		if files[i].Filename == problem.Filename {
+			f = &files[i]
+			break
+		}
+	}
+	if f == nil {
+		return fmt.Errorf("file %s not found in package %s", problem.Filename, p)
+	}
+
+	if len(mirrors) == 0 {
+		return fmt.Errorf("package doesn't come from any mirror")
+	}
+
+	tempPath, err := localPool.GenerateTempPath(f.Filename)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	checksums := f.Checksums
+	err = fmt.Errorf("no download location found")
+
+	for _, repo := range mirrors {
+		var (
+			d    aptly.Downloader
+			urls []string
+		)
+
+		urls, err = repo.packageFileURLs(p, f)
+		if err != nil {
+			continue
+		}
+
+		d, err = mirrorDownloader(repo)
+		if err != nil {
+			continue
+		}
+
+		for _, url := range urls {
+			err = d.DownloadWithChecksum(gocontext.TODO(), url, tempPath, &checksums, false)
+			if err == nil {
+				break
+			}
+		}
+
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	unlock := options.lock(true)
+	defer unlock()
+
+	if problem.Problem == PoolFileCorrupt {
+		if _, err = packagePool.Remove(problem.PoolPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// checksums are not updated, as they're part of package key
+	f.PoolPath, err = packagePool.Import(tempPath, f.Filename, &checksums, true, options.checksumStorage(factory))
+	if err != nil {
+		return err
+	}
+
+	return factory.PackageCollection().Update(p)
+}
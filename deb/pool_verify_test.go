@@ -0,0 +1,171 @@
+package deb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/http"
+
+	. "gopkg.in/check.v1"
+)
+
+const examplePoolFileContent = "alien-arena-common package contents"
+
+type PoolVerifySuite struct {
+	db          database.Storage
+	factory     *CollectionFactory
+	packagePool *files.PackagePool
+	pkg         *Package
+	repo        *RemoteRepo
+	downloader  *http.FakeDownloader
+}
+
+var _ = Suite(&PoolVerifySuite{})
+
+func (s *PoolVerifySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+	s.downloader = http.NewFakeDownloader()
+
+	s.pkg = NewPackageFromControlFile(packageStanza.Copy())
+
+	tmpPath := filepath.Join(c.MkDir(), "alien-arena-common_7.40-2_i386.deb")
+	c.Assert(ioutil.WriteFile(tmpPath, []byte(examplePoolFileContent), 0644), IsNil)
+
+	checksums := checksumsFor(examplePoolFileContent)
+	poolPath, err := s.packagePool.Import(tmpPath, "alien-arena-common_7.40-2_i386.deb", &checksums, false, s.factory.ChecksumCollection(nil))
+	c.Assert(err, IsNil)
+
+	s.pkg.UpdateFiles(PackageFiles{{Filename: "alien-arena-common_7.40-2_i386.deb", Checksums: checksums, PoolPath: poolPath}})
+	c.Assert(s.factory.PackageCollection().Update(s.pkg), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(s.pkg), IsNil)
+
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"contrib"}, []string{}, false, false, false)
+	s.repo.packageRefs = NewPackageRefListFromPackageList(list)
+	c.Assert(s.factory.RemoteRepoCollection().Add(s.repo), IsNil)
+}
+
+func (s *PoolVerifySuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *PoolVerifySuite) mirrorDownloader(repo *RemoteRepo) (aptly.Downloader, error) {
+	return s.downloader, nil
+}
+
+func (s *PoolVerifySuite) verify(c *C, options PoolVerifyOptions) *PoolVerifyReport {
+	report, err := VerifyPool(s.factory, s.packagePool, options, s.mirrorDownloader, nil)
+	c.Assert(err, IsNil)
+	return report
+}
+
+func (s *PoolVerifySuite) poolFile() string {
+	return s.packagePool.FullPath(s.pkg.Files()[0].PoolPath)
+}
+
+func (s *PoolVerifySuite) TestVerifyOK(c *C) {
+	report := s.verify(c, PoolVerifyOptions{})
+	c.Check(report.Packages, Equals, 1)
+	c.Check(report.Files, Equals, 1)
+	c.Check(report.Problems, HasLen, 0)
+	c.Check(report.Unrepaired(), Equals, 0)
+}
+
+func (s *PoolVerifySuite) TestVerifyCorrupt(c *C) {
+	c.Assert(os.Chmod(s.poolFile(), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(s.poolFile(), []byte("alien-arena-common package CONTENTS"), 0644), IsNil)
+
+	// cached checksums are trusted in quick mode
+	c.Check(s.verify(c, PoolVerifyOptions{Quick: true}).Problems, HasLen, 0)
+
+	report := s.verify(c, PoolVerifyOptions{})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Problem, Equals, PoolFileCorrupt)
+	c.Check(report.Problems[0].Package, Equals, "alien-arena-common_7.40-2_i386")
+	c.Check(report.Problems[0].Filename, Equals, "alien-arena-common_7.40-2_i386.deb")
+	c.Check(report.Problems[0].PoolPath, Equals, s.pkg.Files()[0].PoolPath)
+	c.Check(report.Unrepaired(), Equals, 1)
+
+	// truncated file
+	c.Assert(ioutil.WriteFile(s.poolFile(), []byte("alien"), 0644), IsNil)
+	report = s.verify(c, PoolVerifyOptions{Quick: true})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Problem, Equals, PoolFileCorrupt)
+}
+
+func (s *PoolVerifySuite) TestVerifyMissing(c *C) {
+	c.Assert(os.Remove(s.poolFile()), IsNil)
+
+	report := s.verify(c, PoolVerifyOptions{Quick: true})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Problem, Equals, PoolFileMissing)
+	c.Check(report.Problems[0].String(), Equals,
+		"alien-arena-common_7.40-2_i386: alien-arena-common_7.40-2_i386.deb ("+s.pkg.Files()[0].PoolPath+")")
+}
+
+func (s *PoolVerifySuite) TestRepair(c *C) {
+	c.Assert(os.Chmod(s.poolFile(), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(s.poolFile(), []byte("alien-arena-common package CONTENTS"), 0644), IsNil)
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/pool/contrib/a/alien-arena/alien-arena-common_7.40-2_i386.deb", examplePoolFileContent)
+
+	report := s.verify(c, PoolVerifyOptions{Repair: true})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].RepairError, Equals, "")
+	c.Check(report.Problems[0].Repaired, Equals, true)
+	c.Check(report.Unrepaired(), Equals, 0)
+	c.Check(s.downloader.Empty(), Equals, true)
+
+	content, err := ioutil.ReadFile(s.poolFile())
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, examplePoolFileContent)
+
+	c.Check(s.verify(c, PoolVerifyOptions{}).Problems, HasLen, 0)
+}
+
+func (s *PoolVerifySuite) TestRepairLocking(c *C) {
+	c.Assert(os.Remove(s.poolFile()), IsNil)
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/pool/contrib/a/alien-arena/alien-arena-common_7.40-2_i386.deb", examplePoolFileContent)
+
+	var locks []string
+	report := s.verify(c, PoolVerifyOptions{Repair: true, LockCollections: func(exclusive bool) func() {
+		kind := "shared"
+		if exclusive {
+			kind = "exclusive"
+		}
+		locks = append(locks, kind)
+		return func() { locks = append(locks, "un"+kind) }
+	}})
+	c.Check(report.Unrepaired(), Equals, 0)
+	c.Check(locks, DeepEquals, []string{"shared", "unshared", "exclusive", "unexclusive"})
+}
+
+func (s *PoolVerifySuite) TestRepairFailed(c *C) {
+	c.Assert(os.Remove(s.poolFile()), IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/pool/contrib/a/alien-arena/alien-arena-common_7.40-2_i386.deb", &http.Error{Code: 404})
+
+	report := s.verify(c, PoolVerifyOptions{Repair: true})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Repaired, Equals, false)
+	c.Check(report.Problems[0].RepairError, Matches, ".*404.*")
+
+	// package not coming from any mirror
+	c.Assert(s.factory.RemoteRepoCollection().Drop(s.repo), IsNil)
+	localRepo := NewLocalRepo("local", "")
+	localRepo.UpdateRefList(NewPackageRefListFromPackageList(NewPackageList()).Merge(s.repo.RefList(), false, true))
+	c.Assert(s.factory.LocalRepoCollection().Add(localRepo), IsNil)
+
+	report = s.verify(c, PoolVerifyOptions{Repair: true})
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].RepairError, Equals, "package doesn't come from any mirror")
+}
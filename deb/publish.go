@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,6 +33,9 @@ type repoSourceItem struct {
 	packageRefs *PackageRefList
 }
 
+// Format of Date & Valid-Until fields in Release file
+const releaseDateFormat = "Mon, 2 Jan 2006 15:04:05 MST"
+
 // PublishedRepo is a published for http/ftp representation of snapshot as Debian repository
 type PublishedRepo struct {
 	// Internal unique ID
@@ -63,11 +67,50 @@ type PublishedRepo struct {
 	// Skip contents generation
 	SkipContents bool
 
+	// Generate i18n/Translation-en indexes, keeping only short descriptions in Packages
+	Translations bool
+
 	// True if repo is being re-published
 	rePublishing bool
 
 	// Provide index files per hash also
 	AcquireByHash bool
+
+	// List of compression formats for indexes, empty means utils.DefaultCompression
+	Compression []string
+
+	// Validity period of Release file (Valid-Until), zero means no expiration
+	ValidFor time.Duration
+
+	// Fingerprints of keys allowed to sign the repository (Signed-By)
+	SignedBy string
+
+	// Phased-Update-Percentage overrides of binary packages: package key -> percentage
+	PhasedUpdates map[string]int
+
+	// Override tables of binary packages by each component: component name -> package name -> override
+	Overrides map[string]map[string]PackageOverride
+
+	// Override tables of source packages by each component
+	SourceOverrides map[string]map[string]PackageOverride
+
+	// Checksums of generated index files, used to regenerate Release file on refresh
+	IndexChecksums map[string]utils.ChecksumInfo
+
+	// Stage metadata as a new generation and switch it in atomically
+	Atomic bool
+
+	// Number of previous generations kept for rollback
+	KeepGenerations int
+
+	// Generations of published metadata kept in the storage, the last one is active
+	Generations []PublishedGeneration
+
+	// Package refs of generations published, but not saved yet
+	generationRefs map[int]map[string]*PackageRefList
+
+	// Generations dropped, but not removed from the database yet
+	droppedGenerations []PublishedGeneration
 }
 
 // ParsePrefix splits [storage:]prefix into components
@@ -304,6 +347,31 @@ func (p *PublishedRepo) MarshalJSON() ([]byte, error) {
 		})
 	}
 
+	type generationInfo struct {
+		ID        int
+		CreatedAt time.Time
+	}
+
+	generations := []generationInfo{}
+	for _, generation := range p.Generations {
+		generations = append(generations, generationInfo{ID: generation.ID, CreatedAt: generation.CreatedAt})
+	}
+
+	phasedUpdates := p.PhasedUpdates
+	if phasedUpdates == nil {
+		phasedUpdates = map[string]int{}
+	}
+
+	overrides := p.Overrides
+	if overrides == nil {
+		overrides = map[string]map[string]PackageOverride{}
+	}
+
+	sourceOverrides := p.SourceOverrides
+	if sourceOverrides == nil {
+		sourceOverrides = map[string]map[string]PackageOverride{}
+	}
+
 	return json.Marshal(map[string]interface{}{
 		"Architectures":        p.Architectures,
 		"Distribution":         p.Distribution,
@@ -318,7 +386,17 @@ func (p *PublishedRepo) MarshalJSON() ([]byte, error) {
 		"Sources":              sources,
 		"Storage":              p.Storage,
 		"SkipContents":         p.SkipContents,
+		"Translations":         p.Translations,
 		"AcquireByHash":        p.AcquireByHash,
+		"Compression":          p.Compression,
+		"ValidFor":             p.ValidFor.String(),
+		"SignedBy":             p.SignedBy,
+		"PhasedUpdates":        phasedUpdates,
+		"Overrides":            overrides,
+		"SourceOverrides":      sourceOverrides,
+		"Atomic":               p.Atomic,
+		"KeepGenerations":      p.KeepGenerations,
+		"Generations":          generations,
 	})
 }
 
@@ -514,16 +592,42 @@ func (p *PublishedRepo) GetSuite() string {
 // Publish publishes snapshot (repository) contents, links package files, generates Packages & Release files, signs them
 func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
 	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite bool) error {
-	publishedStorage := publishedStorageProvider.GetPublishedStorage(p.Storage)
+	return p.publish(nil, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite)
+}
 
-	err := publishedStorage.MkDir(filepath.Join(p.Prefix, "pool"))
-	if err != nil {
-		return err
+// publish publishes repository along with aliases sharing the same contents, index files
+// are generated once and copied to each alias
+func (p *PublishedRepo) publish(aliases []*PublishedRepo, packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
+	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite bool) (err error) {
+	if len(p.Compression) > 0 {
+		err = utils.ValidateCompression(p.Compression)
+		if err != nil {
+			return err
+		}
 	}
-	basePath := filepath.Join(p.Prefix, "dists", p.Distribution)
-	err = publishedStorage.MkDir(basePath)
-	if err != nil {
-		return err
+
+	targets := make([]*publishTarget, 0, len(aliases)+1)
+	defer func() {
+		if err != nil {
+			for _, target := range targets {
+				target.discard()
+			}
+		}
+	}()
+
+	linkedPools := map[string]bool{}
+	for _, repo := range append([]*PublishedRepo{p}, aliases...) {
+		var target *publishTarget
+		target, err = repo.newPublishTarget(publishedStorageProvider)
+		if err != nil {
+			return err
+		}
+
+		// package files are linked once for each pool
+		target.linkPool = !linkedPools[repo.StoragePrefix()]
+		linkedPools[repo.StoragePrefix()] = true
+
+		targets = append(targets, target)
 	}
 
 	tempDB, err := collectionFactory.TemporaryDB()
@@ -570,9 +674,8 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		p.Architectures = utils.StrSliceDeduplicate(p.Architectures)
 	}
 
-	var suffix string
-	if p.rePublishing {
-		suffix = ".tmp"
+	for _, alias := range aliases {
+		alias.Architectures = p.Architectures
 	}
 
 	if progress != nil {
@@ -586,9 +689,25 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 	}
 	defer os.RemoveAll(tempDir)
 
-	indexes := newIndexFiles(publishedStorage, basePath, tempDir, suffix, p.AcquireByHash)
+	for i, target := range targets {
+		// Release files of aliases are generated in separate directories
+		targetTempDir := tempDir
+		if i > 0 {
+			targetTempDir = filepath.Join(tempDir, strconv.Itoa(i))
+			err = os.Mkdir(targetTempDir, 0777)
+			if err != nil {
+				return err
+			}
+		}
+
+		target.indexes = newIndexFiles(target.publishedStorage, target.basePath(), targetTempDir, target.suffix(),
+			p.AcquireByHash, p.Compression)
+	}
+
+	indexes := targets[0].indexes
 
 	legacyContentIndexes := map[string]*ContentsIndex{}
+	phasedKeys := map[string]bool{}
 
 	for component, list := range lists {
 		hadUdebs := false
@@ -605,6 +724,7 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		list.PrepareIndex()
 
 		contentIndexes := map[string]*ContentsIndex{}
+		translations := map[string]bool{}
 
 		err = list.ForEachIndexed(func(pkg *Package) error {
 			if progress != nil {
@@ -615,20 +735,11 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 				if pkg.MatchesArchitecture(arch) {
 					hadUdebs = hadUdebs || pkg.IsUdeb
 
-					var relPath string
-					if !pkg.IsInstaller {
-						poolDir, err2 := pkg.PoolDirectory()
-						if err2 != nil {
-							return err2
+					for _, target := range targets {
+						err = target.linkPackage(pkg, packagePool, component, arch, forceOverwrite)
+						if err != nil {
+							return err
 						}
-						relPath = filepath.Join("pool", component, poolDir)
-					} else {
-						relPath = filepath.Join("dists", p.Distribution, component, fmt.Sprintf("%s-%s", pkg.Name, arch), "current", "images")
-					}
-
-					err = pkg.LinkFromPool(publishedStorage, packagePool, p.Prefix, relPath, forceOverwrite)
-					if err != nil {
-						return err
 					}
 					break
 				}
@@ -667,7 +778,26 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 						return err
 					}
 
-					err = pkg.Stanza().WriteTo(bufWriter, pkg.IsSource, false, pkg.IsInstaller)
+					stanza := pkg.Stanza()
+					p.applyOverride(component, pkg, stanza)
+					p.applyPhasedUpdate(pkg, stanza, phasedKeys)
+
+					if p.Translations && !pkg.IsSource && !pkg.IsInstaller && !pkg.IsUdeb {
+						translation := splitTranslation(stanza)
+						if translation != nil {
+							key := translation["Package"] + " " + translation["Description-md5"]
+							if !translations[key] {
+								translations[key] = true
+
+								err = writeTranslation(indexes.TranslationIndex(component, "en"), translation)
+								if err != nil {
+									return err
+								}
+							}
+						}
+					}
+
+					err = stanza.WriteTo(bufWriter, pkg.IsSource, false, pkg.IsInstaller)
 					if err != nil {
 						return err
 					}
@@ -710,6 +840,13 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 			}
 		}
 
+		if item := p.sourceItems[component]; item.snapshot != nil && len(item.snapshot.AppStreamFiles) > 0 {
+			err = publishAppStream(indexes, packagePool, component, item.snapshot.AppStreamFiles, p.Architectures)
+			if err != nil {
+				return err
+			}
+		}
+
 		if progress != nil {
 			progress.ShutdownBar()
 		}
@@ -727,26 +864,11 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		// For all architectures, generate Release files
 		for _, arch := range p.Architectures {
 			for _, udeb := range udebs {
-				release := make(Stanza)
-				release["Archive"] = p.Distribution
-				release["Architecture"] = arch
-				release["Component"] = component
-				release["Origin"] = p.GetOrigin()
-				release["Label"] = p.GetLabel()
-				release["Suite"] = p.GetSuite()
-				if p.AcquireByHash {
-					release["Acquire-By-Hash"] = "yes"
-				}
-
-				var bufWriter *bufio.Writer
-				bufWriter, err = indexes.ReleaseIndex(component, arch, udeb).BufWriter()
-				if err != nil {
-					return fmt.Errorf("unable to get ReleaseIndex writer: %s", err)
-				}
-
-				err = release.WriteTo(bufWriter, false, true, false)
-				if err != nil {
-					return fmt.Errorf("unable to create Release file: %s", err)
+				for _, target := range targets {
+					err = target.repo.writeComponentRelease(target.indexes, component, arch, udeb)
+					if err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -781,6 +903,76 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		return err
 	}
 
+	for _, target := range targets {
+		if target.repo != p {
+			if progress != nil {
+				progress.Printf("Copying metadata files to %s/%s...\n", target.repo.StoragePrefix(), target.repo.Distribution)
+			}
+
+			err = target.indexes.FinalizeAll(nil, signer)
+			if err != nil {
+				return err
+			}
+
+			err = target.indexes.CopyFrom(indexes, signer)
+			if err != nil {
+				return err
+			}
+		}
+
+		target.repo.IndexChecksums = make(map[string]utils.ChecksumInfo, len(target.indexes.generatedFiles))
+		for path, info := range target.indexes.generatedFiles {
+			target.repo.IndexChecksums[path] = info
+		}
+
+		err = target.repo.writeRelease(target.indexes, signer, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	// all the files are in place, switch published repositories to new metadata
+	for _, target := range targets {
+		err = target.activate(progress)
+		if err != nil {
+			return err
+		}
+
+		target.repo.prunePhasedUpdates(phasedKeys)
+	}
+
+	return nil
+}
+
+// writeComponentRelease generates Release file of the component for the architecture
+func (p *PublishedRepo) writeComponentRelease(indexes *indexFiles, component, arch string, udeb bool) error {
+	release := make(Stanza)
+	release["Archive"] = p.Distribution
+	release["Architecture"] = arch
+	release["Component"] = component
+	release["Origin"] = p.GetOrigin()
+	release["Label"] = p.GetLabel()
+	release["Suite"] = p.GetSuite()
+	if p.AcquireByHash {
+		release["Acquire-By-Hash"] = "yes"
+	}
+
+	bufWriter, err := indexes.ReleaseIndex(component, arch, udeb).BufWriter()
+	if err != nil {
+		return fmt.Errorf("unable to get ReleaseIndex writer: %s", err)
+	}
+
+	err = release.WriteTo(bufWriter, false, true, false)
+	if err != nil {
+		return fmt.Errorf("unable to create Release file: %s", err)
+	}
+
+	return nil
+}
+
+// writeRelease generates top-level Release file out of checksums of index files,
+// signs and publishes it
+func (p *PublishedRepo) writeRelease(indexes *indexFiles, signer pgp.Signer, progress aptly.Progress) error {
 	release := make(Stanza)
 	release["Origin"] = p.GetOrigin()
 	if p.NotAutomatic != "" {
@@ -792,11 +984,18 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 	release["Label"] = p.GetLabel()
 	release["Suite"] = p.GetSuite()
 	release["Codename"] = p.Distribution
-	release["Date"] = time.Now().UTC().Format("Mon, 2 Jan 2006 15:04:05 MST")
+	now := time.Now().UTC()
+	release["Date"] = now.Format(releaseDateFormat)
+	if p.ValidFor > 0 {
+		release["Valid-Until"] = now.Add(p.ValidFor).Format(releaseDateFormat)
+	}
 	release["Architectures"] = strings.Join(utils.StrSlicesSubstract(p.Architectures, []string{ArchitectureSource}), " ")
 	if p.AcquireByHash {
 		release["Acquire-By-Hash"] = "yes"
 	}
+	if p.SignedBy != "" {
+		release["Signed-By"] = p.SignedBy
+	}
 	release["Description"] = " Generated by aptly\n"
 	release["MD5Sum"] = ""
 	release["SHA1"] = ""
@@ -835,12 +1034,58 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 		progress.Flush()
 	}
 
-	err = releaseFile.Finalize(signer)
+	return releaseFile.Finalize(signer)
+}
+
+// Refresh regenerates top-level Release file with new Date & Valid-Until
+// and signs it again, package indexes are not rebuilt
+func (p *PublishedRepo) Refresh(publishedStorageProvider aptly.PublishedStorageProvider, signer pgp.Signer, progress aptly.Progress) error {
+	if len(p.IndexChecksums) == 0 {
+		return fmt.Errorf("checksums of index files are not known, published repository should be updated first")
+	}
+
+	publishedStorage := publishedStorageProvider.GetPublishedStorage(p.Storage)
+	basePath := p.basePath()
+
+	// atomically published repository gets Release file of active generation refreshed
+	active := p.ActiveGeneration()
+	if p.Atomic && active != nil {
+		basePath = p.generationPath(active.ID)
+	}
+
+	tempDir, err := ioutil.TempDir(os.TempDir(), "aptly")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tempDir)
 
-	return indexes.RenameFiles()
+	indexes := newIndexFiles(publishedStorage, basePath, tempDir, ".tmp", p.AcquireByHash, p.Compression)
+	for path, info := range p.IndexChecksums {
+		indexes.generatedFiles[path] = info
+	}
+
+	if progress != nil {
+		progress.Printf("Refreshing Release file...\n")
+	}
+
+	err = p.writeRelease(indexes, signer, progress)
+	if err != nil {
+		return err
+	}
+
+	err = indexes.RenameFiles()
+	if err != nil {
+		return err
+	}
+
+	if p.Atomic && active != nil {
+		err = p.activateGeneration(publishedStorage, active.ID)
+		if err != nil {
+			return fmt.Errorf("unable to switch to generation %d: %s", active.ID, err)
+		}
+	}
+
+	return nil
 }
 
 // RemoveFiles removes files that were created by Publish
@@ -866,6 +1111,13 @@ func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStor
 		return err
 	}
 
+	if len(p.Generations) > 0 {
+		err = publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "dists", generationsDir, p.Distribution), progress)
+		if err != nil {
+			return err
+		}
+	}
+
 	// III. Complex: there are no other publishes with the same prefix + component
 	for _, component := range removePoolComponents {
 		err = publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "pool", component), progress)
@@ -942,13 +1194,63 @@ func (collection *PublishedRepoCollection) CheckDuplicate(repo *PublishedRepo) *
 
 // Update stores updated information about repo in DB
 func (collection *PublishedRepoCollection) Update(repo *PublishedRepo) error {
+	return collection.updateRepos([]*PublishedRepo{repo})
+}
+
+// UpdateBatch stores updated information about several repos in DB in a single transaction,
+// repos which are not in the collection yet are added to it
+func (collection *PublishedRepoCollection) UpdateBatch(repos []*PublishedRepo) error {
+	collection.loadList()
+
+	var added []*PublishedRepo
+	for _, repo := range repos {
+		existing := collection.CheckDuplicate(repo)
+		if existing == nil {
+			added = append(added, repo)
+		} else if existing != repo {
+			return fmt.Errorf("published repo with storage/prefix/distribution %s/%s/%s already exists", repo.Storage, repo.Prefix, repo.Distribution)
+		}
+	}
+
+	err := collection.updateRepos(repos)
+	if err != nil {
+		return err
+	}
+
+	collection.list = append(collection.list, added...)
+	return nil
+}
+
+// updateRepos saves repos to DB in a single transaction
+func (collection *PublishedRepoCollection) updateRepos(repos []*PublishedRepo) error {
 	transaction, err := collection.db.OpenTransaction()
 	if err != nil {
 		return err
 	}
 	defer transaction.Discard()
 
-	err = transaction.Put(repo.Key(), repo.Encode())
+	for _, repo := range repos {
+		err = collection.putRepo(transaction, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, repo := range repos {
+		repo.generationRefs = nil
+		repo.droppedGenerations = nil
+	}
+	return nil
+}
+
+// putRepo writes repo along with its package references into transaction
+func (collection *PublishedRepoCollection) putRepo(transaction database.Transaction, repo *PublishedRepo) error {
+	err := transaction.Put(repo.Key(), repo.Encode())
 	if err != nil {
 		return err
 	}
@@ -961,7 +1263,26 @@ func (collection *PublishedRepoCollection) Update(repo *PublishedRepo) error {
 			}
 		}
 	}
-	return transaction.Commit()
+
+	for id, refLists := range repo.generationRefs {
+		for component, refList := range refLists {
+			err = transaction.Put(repo.GenerationRefKey(id, component), refList.Encode())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, generation := range repo.droppedGenerations {
+		for component := range generation.Sources {
+			err = transaction.Delete(repo.GenerationRefKey(generation.ID, component))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 // LoadComplete loads additional information for remote repo
@@ -1119,20 +1440,52 @@ func (collection *PublishedRepoCollection) Len() int {
 func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(prefix string, components []string,
 	publishedStorage aptly.PublishedStorage, collectionFactory *CollectionFactory, progress aptly.Progress) error {
 
+	if progress != nil {
+		progress.Printf("Cleaning up prefix %#v components %s...\n", prefix, strings.Join(components, ", "))
+	}
+
+	referencedFiles, err := collection.referencedPoolFiles(prefix, components, collectionFactory, progress, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, component := range components {
+		rootPath := filepath.Join(prefix, "pool", component)
+		existingFiles, err := publishedStorage.Filelist(rootPath)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(existingFiles)
+
+		filesToDelete := utils.StrSlicesSubstract(existingFiles, referencedFiles[component])
+
+		for _, file := range filesToDelete {
+			err = publishedStorage.Remove(filepath.Join(rootPath, file))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// referencedPoolFiles returns sorted lists of files in pool of the prefix referenced by published repositories
+// for each of the components, overrides replace package references of some of published repositories
+func (collection *PublishedRepoCollection) referencedPoolFiles(prefix string, components []string, collectionFactory *CollectionFactory,
+	progress aptly.Progress, overrides map[*PublishedRepo]map[string]*PackageRefList) (map[string][]string, error) {
 	collection.loadList()
 
 	var err error
 	referencedFiles := map[string][]string{}
 
-	if progress != nil {
-		progress.Printf("Cleaning up prefix %#v components %s...\n", prefix, strings.Join(components, ", "))
-	}
-
 	for _, r := range collection.list {
 		if r.Prefix == prefix {
 			matches := false
 
-			repoComponents := r.Components()
+			// files published by kept generations are needed for rollback
+			repoComponents := utils.StrSliceDeduplicate(append(r.Components(), r.GenerationComponents()...))
 
 			for _, component := range components {
 				if utils.StrSliceHasItem(repoComponents, component) {
@@ -1147,14 +1500,25 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(prefix st
 
 			err = collection.LoadComplete(r, collectionFactory)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			for _, component := range components {
 				if utils.StrSliceHasItem(repoComponents, component) {
-					packageList, err := NewPackageListFromRefList(r.RefList(component), collectionFactory.PackageCollection(), progress)
+					refList, err := collection.GenerationsRefList(r, component)
 					if err != nil {
-						return err
+						return nil, err
+					}
+
+					if refs, ok := overrides[r][component]; ok {
+						refList = refList.Merge(refs, false, true)
+					} else if _, ok := r.Sources[component]; ok {
+						refList = refList.Merge(r.RefList(component), false, true)
+					}
+
+					packageList, err := NewPackageListFromRefList(refList, collectionFactory.PackageCollection(), progress)
+					if err != nil {
+						return nil, err
 					}
 
 					packageList.ForEach(func(p *Package) error {
@@ -1176,26 +1540,9 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(prefix st
 
 	for _, component := range components {
 		sort.Strings(referencedFiles[component])
-
-		rootPath := filepath.Join(prefix, "pool", component)
-		existingFiles, err := publishedStorage.Filelist(rootPath)
-		if err != nil {
-			return err
-		}
-
-		sort.Strings(existingFiles)
-
-		filesToDelete := utils.StrSlicesSubstract(existingFiles, referencedFiles[component])
-
-		for _, file := range filesToDelete {
-			err = publishedStorage.Remove(filepath.Join(rootPath, file))
-			if err != nil {
-				return err
-			}
-		}
 	}
 
-	return nil
+	return referencedFiles, nil
 }
 
 // Remove removes published repository, cleaning up directories, files
@@ -1273,5 +1620,14 @@ func (collection *PublishedRepoCollection) Remove(publishedStorageProvider aptly
 		}
 	}
 
+	for _, generation := range repo.Generations {
+		for component := range generation.Sources {
+			err = transaction.Delete(repo.GenerationRefKey(generation.ID, component))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return transaction.Commit()
 }
@@ -0,0 +1,190 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// publishTarget is a published repository being written by single publishing run
+type publishTarget struct {
+	repo             *PublishedRepo
+	publishedStorage aptly.PublishedStorage
+	// directory metadata is written to, relative to the prefix
+	distDir string
+	// generation being staged with atomic publishing
+	generation *PublishedGeneration
+	indexes    *indexFiles
+	// whether package files should be linked to the pool of this target
+	linkPool  bool
+	activated bool
+}
+
+// newPublishTarget prepares directories of published repository for publishing
+func (p *PublishedRepo) newPublishTarget(publishedStorageProvider aptly.PublishedStorageProvider) (*publishTarget, error) {
+	target := &publishTarget{
+		repo:             p,
+		publishedStorage: publishedStorageProvider.GetPublishedStorage(p.Storage),
+		distDir:          filepath.Join("dists", p.Distribution),
+	}
+
+	err := target.publishedStorage.MkDir(filepath.Join(p.Prefix, "pool"))
+	if err != nil {
+		return nil, err
+	}
+
+	// with atomic publishing, metadata is staged as new generation and
+	// switched in after everything has been published
+	if p.Atomic {
+		target.generation = p.newGeneration()
+		target.distDir = p.generationDir(target.generation.ID)
+	}
+
+	err = target.publishedStorage.MkDir(target.basePath())
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// basePath returns path metadata is written to
+func (target *publishTarget) basePath() string {
+	return filepath.Join(target.repo.Prefix, target.distDir)
+}
+
+// suffix returns suffix metadata files are written with before being renamed in place
+func (target *publishTarget) suffix() string {
+	if target.repo.rePublishing && target.generation == nil {
+		return ".tmp"
+	}
+
+	return ""
+}
+
+// linkPackage links package files into published repository
+func (target *publishTarget) linkPackage(pkg *Package, packagePool aptly.PackagePool, component, arch string, forceOverwrite bool) error {
+	var relPath string
+	if !pkg.IsInstaller {
+		if !target.linkPool {
+			return nil
+		}
+
+		poolDir, err := pkg.PoolDirectory()
+		if err != nil {
+			return err
+		}
+		relPath = filepath.Join("pool", component, poolDir)
+	} else {
+		relPath = installerPublishPath(target.publishedStorage, installerDir(target.distDir, component, pkg, arch), pkg)
+	}
+
+	err := pkg.LinkFromPool(target.publishedStorage, packagePool, target.repo.Prefix, relPath, forceOverwrite)
+	if err != nil {
+		return err
+	}
+
+	if pkg.IsInstaller {
+		return linkInstallerCurrent(target.publishedStorage, target.repo.Prefix, installerDir(target.distDir, component, pkg, arch), pkg)
+	}
+
+	return nil
+}
+
+// activate makes metadata written by publishing visible to the clients
+func (target *publishTarget) activate(progress aptly.Progress) error {
+	target.activated = true
+
+	if target.generation != nil {
+		return target.repo.publishGeneration(target.publishedStorage, target.generation, progress)
+	}
+
+	return target.indexes.RenameFiles()
+}
+
+// discard removes staged generation after failed publishing
+func (target *publishTarget) discard() {
+	if target.generation != nil && !target.activated {
+		target.publishedStorage.RemoveDirs(target.basePath(), nil)
+	}
+}
+
+// compressionFormats returns list of index compression formats published repository uses
+func (p *PublishedRepo) compressionFormats() []string {
+	if len(p.Compression) == 0 {
+		return utils.DefaultCompression
+	}
+
+	return p.Compression
+}
+
+// checkSameIndexes verifies that other published repository gets the same index files
+func (p *PublishedRepo) checkSameIndexes(other *PublishedRepo) error {
+	var mismatch string
+
+	switch {
+	case p.SourceKind != other.SourceKind:
+		mismatch = "source kind"
+	case !utils.StrMapsEqual(p.Sources, other.Sources):
+		mismatch = "sources"
+	case !utils.StrSlicesEqual(p.Architectures, other.Architectures):
+		mismatch = "architectures"
+	case p.SkipContents != other.SkipContents:
+		mismatch = "contents indexes"
+	case p.Translations != other.Translations:
+		mismatch = "translations"
+	case p.AcquireByHash != other.AcquireByHash:
+		mismatch = "acquire-by-hash"
+	case !utils.StrSlicesEqual(p.compressionFormats(), other.compressionFormats()):
+		mismatch = "compression"
+	case !p.samePhasedUpdates(other):
+		mismatch = "phased updates"
+	case !p.sameOverrides(other):
+		mismatch = "overrides"
+	default:
+		return nil
+	}
+
+	return fmt.Errorf("published repository %s/%s can't be published along with %s/%s: %s differ",
+		other.StoragePrefix(), other.Distribution, p.StoragePrefix(), p.Distribution, mismatch)
+}
+
+// PublishBatch publishes several repositories with the same contents at once,
+// e.g. the same snapshots under different distributions and prefixes
+//
+// Index files are generated once for the first repository and copied to the
+// others, only Release files are generated for each of them
+func PublishBatch(repos []*PublishedRepo, packagePool aptly.PackagePool, publishedStorageProvider aptly.PublishedStorageProvider,
+	collectionFactory *CollectionFactory, signer pgp.Signer, progress aptly.Progress, forceOverwrite bool) error {
+	if len(repos) == 0 {
+		return nil
+	}
+
+	primary, aliases := repos[0], repos[1:]
+
+	seen := map[string]bool{}
+	for _, repo := range repos {
+		key := repo.StoragePrefix() + "/" + repo.Distribution
+		if seen[key] {
+			return fmt.Errorf("published repository %s is given more than once", key)
+		}
+		seen[key] = true
+	}
+
+	for _, alias := range aliases {
+		err := primary.checkSameIndexes(alias)
+		if err != nil {
+			return err
+		}
+
+		// aliases publish exactly the same package lists
+		for component, item := range primary.sourceItems {
+			alias.sourceItems[component] = item
+		}
+	}
+
+	return primary.publish(aliases, packagePool, publishedStorageProvider, collectionFactory, signer, progress, forceOverwrite)
+}
@@ -0,0 +1,102 @@
+package deb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *PublishedRepoSuite) TestPublishBatch(c *C) {
+	alias, _ := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{s.snapshot}, s.factory)
+	alias.SkipContents = true
+	alias.Label = "alias"
+
+	other, _ := NewPublishedRepo("files:other", ".", "squeeze", nil, []string{"main"}, []interface{}{s.snapshot}, s.factory)
+	other.SkipContents = true
+
+	err := PublishBatch([]*PublishedRepo{s.repo, alias, other}, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Assert(err, IsNil)
+
+	c.Check(alias.Architectures, DeepEquals, s.repo.Architectures)
+	c.Check(other.Architectures, DeepEquals, s.repo.Architectures)
+
+	for _, path := range []string{
+		filepath.Join(s.root, "ppa/dists/stable/main/binary-i386/Packages.gz"),
+		filepath.Join(s.root2, "dists/squeeze/main/binary-i386/Packages.gz"),
+		filepath.Join(s.root2, "pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb"),
+	} {
+		c.Check(path, PathExists)
+	}
+
+	packages, err := ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	aliasPackages, err := ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/stable/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(aliasPackages, DeepEquals, packages)
+
+	// Release files are specific to each published repository
+	release, err := ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/stable/main/binary-i386/Release"))
+	c.Assert(err, IsNil)
+	c.Check(string(release), Matches, "(?s).*Label: alias\n.*Archive: stable\n.*")
+
+	rf, err := os.Open(filepath.Join(s.root, "ppa/dists/stable/Release"))
+	c.Assert(err, IsNil)
+	st := NewControlFileReader(rf, true, false)
+	stanza, err := st.ReadStanza()
+	c.Assert(err, IsNil)
+	rf.Close()
+	c.Check(stanza["Codename"], Equals, "stable")
+	c.Check(stanza["Label"], Equals, "alias")
+
+	c.Check(alias.IndexChecksums["main/binary-i386/Packages"], DeepEquals, s.repo.IndexChecksums["main/binary-i386/Packages"])
+	c.Check(alias.IndexChecksums["main/binary-i386/Release"], Not(DeepEquals), s.repo.IndexChecksums["main/binary-i386/Release"])
+
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(collection.UpdateBatch([]*PublishedRepo{s.repo, alias, other}), IsNil)
+	c.Check(collection.Len(), Equals, 3)
+	c.Assert(collection.UpdateBatch([]*PublishedRepo{s.repo, alias}), IsNil)
+	c.Check(collection.Len(), Equals, 3)
+
+	collection = NewPublishedRepoCollection(s.db)
+	_, err = collection.ByStoragePrefixDistribution("files:other", ".", "squeeze")
+	c.Check(err, IsNil)
+
+	duplicate, _ := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{s.snapshot}, s.factory)
+	c.Check(collection.UpdateBatch([]*PublishedRepo{duplicate}), ErrorMatches, ".*already exists")
+}
+
+func (s *PublishedRepoSuite) TestPublishBatchAtomic(c *C) {
+	alias, _ := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{s.snapshot}, s.factory)
+	alias.SkipContents = true
+	alias.Atomic = true
+
+	err := PublishBatch([]*PublishedRepo{s.repo, alias}, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Assert(err, IsNil)
+
+	link, err := os.Readlink(filepath.Join(s.root, "ppa/dists/stable"))
+	c.Assert(err, IsNil)
+	c.Check(link, Equals, ".generations/stable/1")
+	c.Check(filepath.Join(s.root, "ppa/dists/stable/main/binary-i386/Packages"), PathExists)
+	c.Check(s.generationIDs(alias), DeepEquals, []int{1})
+}
+
+func (s *PublishedRepoSuite) TestPublishBatchMismatch(c *C) {
+	alias, _ := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{s.snapshot2}, s.factory)
+	alias.SkipContents = true
+
+	err := PublishBatch([]*PublishedRepo{s.repo, alias}, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Check(err, ErrorMatches, "published repository ppa/stable can't be published along with ppa/squeeze: sources differ")
+
+	alias, _ = NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{s.snapshot}, s.factory)
+	alias.Compression = []string{"xz"}
+
+	err = PublishBatch([]*PublishedRepo{s.repo, alias}, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Check(err, ErrorMatches, ".*contents indexes differ")
+
+	err = PublishBatch([]*PublishedRepo{s.repo, s.repo}, s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Check(err, ErrorMatches, "published repository ppa/squeeze is given more than once")
+
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze"), Not(PathExists))
+}
@@ -0,0 +1,138 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// PublishedComponentDiff lists changes publishing new package list would make to the component
+type PublishedComponentDiff struct {
+	Component  string
+	Added      PackageDiffs
+	Removed    PackageDiffs
+	Upgraded   PackageDiffs
+	Downgraded PackageDiffs
+	// Files which would be uploaded to the pool of the component
+	UploadedFiles []string
+	// Files which would be removed from the pool of the component on cleanup
+	CleanedUpFiles []string
+}
+
+// PublishedDiff is a preview of changes to published repository
+type PublishedDiff struct {
+	Storage      string
+	Prefix       string
+	Distribution string
+	Components   []PublishedComponentDiff
+}
+
+// PreviewSources calculates changes which publishing new package lists of components would
+// make to published repositories, neither published storage nor database are modified
+func (collection *PublishedRepoCollection) PreviewSources(repos []*PublishedRepo, refLists map[string]*PackageRefList,
+	publishedStorageProvider aptly.PublishedStorageProvider, collectionFactory *CollectionFactory, progress aptly.Progress) ([]PublishedDiff, error) {
+	components := make([]string, 0, len(refLists))
+	for component := range refLists {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	overrides := make(map[*PublishedRepo]map[string]*PackageRefList, len(repos))
+	for _, repo := range repos {
+		overrides[repo] = refLists
+	}
+
+	result := make([]PublishedDiff, 0, len(repos))
+
+	for _, repo := range repos {
+		for _, component := range components {
+			if _, ok := repo.Sources[component]; !ok {
+				return nil, fmt.Errorf("component %s is not in published repository %s/%s", component, repo.StoragePrefix(), repo.Distribution)
+			}
+		}
+	}
+
+	for _, repo := range repos {
+		referencedFiles, err := collection.referencedPoolFiles(repo.Prefix, components, collectionFactory, progress, overrides)
+		if err != nil {
+			return nil, err
+		}
+
+		publishedStorage := publishedStorageProvider.GetPublishedStorage(repo.Storage)
+
+		diff := PublishedDiff{
+			Storage:      repo.Storage,
+			Prefix:       repo.Prefix,
+			Distribution: repo.Distribution,
+		}
+
+		for _, component := range components {
+			componentDiff := PublishedComponentDiff{Component: component}
+
+			packageDiffs, err := repo.RefList(component).Diff(refLists[component], collectionFactory.PackageCollection())
+			if err != nil {
+				return nil, err
+			}
+
+			for _, packageDiff := range packageDiffs {
+				switch {
+				case packageDiff.Left == nil:
+					componentDiff.Added = append(componentDiff.Added, packageDiff)
+				case packageDiff.Right == nil:
+					componentDiff.Removed = append(componentDiff.Removed, packageDiff)
+				case CompareVersions(packageDiff.Right.Version, packageDiff.Left.Version) > 0:
+					componentDiff.Upgraded = append(componentDiff.Upgraded, packageDiff)
+				default:
+					componentDiff.Downgraded = append(componentDiff.Downgraded, packageDiff)
+				}
+			}
+
+			existingFiles, err := publishedStorage.Filelist(filepath.Join(repo.Prefix, "pool", component))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(existingFiles)
+
+			packageList, err := NewPackageListFromRefList(refLists[component], collectionFactory.PackageCollection(), progress)
+			if err != nil {
+				return nil, err
+			}
+
+			publishedFiles := []string{}
+			err = packageList.ForEach(func(p *Package) error {
+				// installer files are published under dists/
+				if p.IsInstaller {
+					return nil
+				}
+
+				poolDir, err := p.PoolDirectory()
+				if err != nil {
+					return err
+				}
+
+				for _, f := range p.Files() {
+					publishedFiles = append(publishedFiles, filepath.Join(poolDir, f.Filename))
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(publishedFiles)
+			publishedFiles = utils.StrSliceDeduplicate(publishedFiles)
+
+			componentDiff.UploadedFiles = utils.StrSlicesSubstract(publishedFiles, existingFiles)
+			componentDiff.CleanedUpFiles = utils.StrSlicesSubstract(existingFiles, referencedFiles[component])
+
+			diff.Components = append(diff.Components, componentDiff)
+		}
+
+		result = append(result, diff)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (s *PublishedRepoSuite) TestPreviewSources(c *C) {
+	collection := s.factory.PublishedRepoCollection()
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	// removing all the packages
+	diffs, err := collection.PreviewSources([]*PublishedRepo{s.repo}, map[string]*PackageRefList{"main": NewPackageRefList()},
+		s.provider, s.factory, nil)
+	c.Assert(err, IsNil)
+	c.Assert(diffs, HasLen, 1)
+	c.Check(diffs[0].Prefix, Equals, "ppa")
+	c.Check(diffs[0].Distribution, Equals, "squeeze")
+	c.Assert(diffs[0].Components, HasLen, 1)
+
+	diff := diffs[0].Components[0]
+	c.Check(diff.Component, Equals, "main")
+	c.Check(diff.Added, HasLen, 0)
+	c.Check(diff.Removed, HasLen, 3)
+	c.Check(diff.UploadedFiles, HasLen, 0)
+	c.Check(diff.CleanedUpFiles, DeepEquals, []string{"a/alien-arena/alien-arena-common_7.40-2_i386.deb"})
+
+	// published files are still in place
+	c.Check(s.root+"/ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb", PathExists)
+	c.Check(s.repo.RefList("main").Len(), Equals, 3)
+
+	// new version of package
+	p := NewPackageFromControlFile(s.p1.Stanza())
+	p.Version = "7.40-3"
+	files := p.Files()
+	files[0].Filename = "alien-arena-common_7.40-3_i386.deb"
+	p.UpdateFiles(files)
+	c.Assert(s.factory.PackageCollection().Update(p), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(p), IsNil)
+	refList := s.reflist.Merge(NewPackageRefListFromPackageList(list), true, false)
+
+	diffs, err = collection.PreviewSources([]*PublishedRepo{s.repo}, map[string]*PackageRefList{"main": refList},
+		s.provider, s.factory, nil)
+	c.Assert(err, IsNil)
+
+	diff = diffs[0].Components[0]
+	c.Check(diff.Added, HasLen, 0)
+	c.Check(diff.Removed, HasLen, 0)
+	c.Assert(diff.Upgraded, HasLen, 1)
+	c.Check(diff.Upgraded[0].Left.Version, Equals, "7.40-2")
+	c.Check(diff.Upgraded[0].Right.Version, Equals, "7.40-3")
+	c.Check(diff.UploadedFiles, DeepEquals, []string{"a/alien-arena/alien-arena-common_7.40-3_i386.deb"})
+}
@@ -0,0 +1,395 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// DefaultKeepGenerations is default number of previous generations kept for rollback
+const DefaultKeepGenerations = 2
+
+// generationsDir is directory under dists/ where generations of published metadata are staged
+const generationsDir = ".generations"
+
+// PublishedGeneration is a complete copy of dists/<distribution> tree staged by atomic publishing
+type PublishedGeneration struct {
+	// Sequential number of the generation
+	ID int
+	// Time generation has been published
+	CreatedAt time.Time
+	// Map of sources by each component at the time of publishing
+	Sources map[string]string
+	// Checksums of index files of the generation
+	IndexChecksums map[string]utils.ChecksumInfo
+}
+
+// basePath returns path to published metadata, dists/<distribution>
+func (p *PublishedRepo) basePath() string {
+	return filepath.Join(p.Prefix, "dists", p.Distribution)
+}
+
+// generationDir returns directory generation is staged at relative to the prefix
+func (p *PublishedRepo) generationDir(id int) string {
+	return filepath.Join("dists", generationsDir, p.Distribution, strconv.Itoa(id))
+}
+
+// generationPath returns path generation is staged at
+func (p *PublishedRepo) generationPath(id int) string {
+	return filepath.Join(p.Prefix, p.generationDir(id))
+}
+
+// GenerationRefKey is a unique id for package reference list of the component in the generation
+func (p *PublishedRepo) GenerationRefKey(id int, component string) []byte {
+	return []byte(fmt.Sprintf("E%s@%d/%s", p.UUID, id, component))
+}
+
+// ActiveGeneration returns generation currently published, or nil if
+// repository is not published atomically
+func (p *PublishedRepo) ActiveGeneration() *PublishedGeneration {
+	if len(p.Generations) == 0 {
+		return nil
+	}
+
+	return &p.Generations[len(p.Generations)-1]
+}
+
+// GenerationComponents returns sorted list of components published in any of the generations
+func (p *PublishedRepo) GenerationComponents() []string {
+	result := []string{}
+	for _, generation := range p.Generations {
+		for component := range generation.Sources {
+			result = append(result, component)
+		}
+	}
+
+	sort.Strings(result)
+	return utils.StrSliceDeduplicate(result)
+}
+
+// newGeneration allocates next generation for staging
+func (p *PublishedRepo) newGeneration() *PublishedGeneration {
+	id := 1
+	if active := p.ActiveGeneration(); active != nil {
+		id = active.ID + 1
+	}
+
+	return &PublishedGeneration{ID: id}
+}
+
+// activateGeneration makes staged generation visible under dists/<distribution>
+//
+// Storages supporting atomic switch just flip the link, others get all the files
+// copied over with Release files copied last, so that clients never see new
+// Release file referring to missing indexes
+func (p *PublishedRepo) activateGeneration(publishedStorage aptly.PublishedStorage, id int) error {
+	if atomicStorage, ok := publishedStorage.(aptly.AtomicPublishedStorage); ok {
+		return atomicStorage.SwitchDir(p.basePath(), p.generationPath(id))
+	}
+
+	return copyGeneration(publishedStorage, p.generationPath(id), p.basePath())
+}
+
+// isReleaseFile checks if path is top-level Release file
+func isReleaseFile(path string) bool {
+	return path == "Release" || path == "Release.gpg" || path == "InRelease"
+}
+
+// copyGeneration copies staged generation to the published location and removes
+// stale files, by-hash files are kept for the clients which still use previous Release
+func copyGeneration(publishedStorage aptly.PublishedStorage, generationPath, basePath string) error {
+	generationFiles, err := publishedStorage.Filelist(generationPath)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(generationFiles, func(i, j int) bool {
+		iRelease, jRelease := isReleaseFile(generationFiles[i]), isReleaseFile(generationFiles[j])
+		if iRelease != jRelease {
+			return jRelease
+		}
+		return generationFiles[i] < generationFiles[j]
+	})
+
+	existingFiles, err := publishedStorage.Filelist(basePath)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range generationFiles {
+		err = publishedStorage.MkDir(filepath.Dir(filepath.Join(basePath, path)))
+		if err != nil {
+			return err
+		}
+
+		err = copyGenerationFile(publishedStorage, filepath.Join(generationPath, path), filepath.Join(basePath, path))
+		if err != nil {
+			return fmt.Errorf("unable to copy %s: %s", path, err)
+		}
+	}
+
+	sort.Strings(generationFiles)
+	for _, path := range utils.StrSlicesSubstract(existingFiles, generationFiles) {
+		if strings.Contains(path, "/by-hash/") {
+			continue
+		}
+
+		err = publishedStorage.Remove(filepath.Join(basePath, path))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyGenerationFile copies single file of the generation, existing file is replaced
+// via temporary file, so that it's never missing
+func copyGenerationFile(publishedStorage aptly.PublishedStorage, src, dst string) error {
+	exists, err := publishedStorage.FileExists(dst)
+	if err != nil {
+		return err
+	}
+
+	// for object storages hard link is a server-side copy
+	if !exists {
+		return publishedStorage.HardLink(src, dst)
+	}
+
+	err = publishedStorage.HardLink(src, dst+".tmp")
+	if err != nil {
+		return err
+	}
+
+	return publishedStorage.RenameFile(dst+".tmp", dst)
+}
+
+// carryOverByHash copies by-hash index files of previous generation into staged one,
+// so that clients which have fetched previous Release file can still download indexes
+func (p *PublishedRepo) carryOverByHash(publishedStorage aptly.PublishedStorage, previous *PublishedGeneration, id int) error {
+	for path, info := range previous.IndexChecksums {
+		for hash, sum := range map[string]string{"SHA512": info.SHA512, "SHA256": info.SHA256, "SHA1": info.SHA1, "MD5Sum": info.MD5} {
+			if sum == "" {
+				continue
+			}
+
+			hashPath := filepath.Join(filepath.Dir(path), "by-hash", hash, sum)
+			src := filepath.Join(p.generationPath(previous.ID), hashPath)
+			dst := filepath.Join(p.generationPath(id), hashPath)
+
+			exists, err := publishedStorage.FileExists(src)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+
+			exists, err = publishedStorage.FileExists(dst)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+
+			err = publishedStorage.MkDir(filepath.Dir(dst))
+			if err != nil {
+				return err
+			}
+
+			err = publishedStorage.HardLink(src, dst)
+			if err != nil {
+				return fmt.Errorf("Acquire-By-Hash: error linking %s: %s", dst, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// publishGeneration switches published repository to the staged generation and
+// drops generations which are no longer kept
+func (p *PublishedRepo) publishGeneration(publishedStorage aptly.PublishedStorage, generation *PublishedGeneration,
+	progress aptly.Progress) error {
+	previous := p.ActiveGeneration()
+	if previous != nil && p.AcquireByHash {
+		err := p.carryOverByHash(publishedStorage, previous, generation.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if progress != nil {
+		progress.Printf("Switching to generation %d...\n", generation.ID)
+	}
+
+	err := p.activateGeneration(publishedStorage, generation.ID)
+	if err != nil {
+		return fmt.Errorf("unable to switch to generation %d: %s", generation.ID, err)
+	}
+
+	generation.CreatedAt = time.Now()
+	generation.Sources = make(map[string]string, len(p.Sources))
+	for component, sourceUUID := range p.Sources {
+		generation.Sources[component] = sourceUUID
+	}
+	generation.IndexChecksums = p.IndexChecksums
+
+	if p.generationRefs == nil {
+		p.generationRefs = make(map[int]map[string]*PackageRefList)
+	}
+	p.generationRefs[generation.ID] = make(map[string]*PackageRefList, len(p.sourceItems))
+	for component := range p.sourceItems {
+		p.generationRefs[generation.ID][component] = p.RefList(component)
+	}
+
+	p.Generations = append(p.Generations, *generation)
+
+	for len(p.Generations) > p.KeepGenerations+1 {
+		p.dropGeneration(publishedStorage, p.Generations[0].ID, progress)
+	}
+
+	return nil
+}
+
+// dropGeneration removes staged files of the generation, refs are removed from database
+// when PublishedRepo is saved
+func (p *PublishedRepo) dropGeneration(publishedStorage aptly.PublishedStorage, id int, progress aptly.Progress) {
+	for i := range p.Generations {
+		if p.Generations[i].ID == id {
+			p.droppedGenerations = append(p.droppedGenerations, p.Generations[i])
+			p.Generations = append(p.Generations[:i], p.Generations[i+1:]...)
+			break
+		}
+	}
+	delete(p.generationRefs, id)
+
+	err := publishedStorage.RemoveDirs(p.generationPath(id), nil)
+	if err != nil && progress != nil {
+		progress.Printf("failed to remove generation %d: %s\n", id, err)
+	}
+}
+
+// GenerationsRefList returns list of package refs published in the component by all the kept generations
+func (collection *PublishedRepoCollection) GenerationsRefList(repo *PublishedRepo, component string) (*PackageRefList, error) {
+	result := NewPackageRefList()
+
+	for _, generation := range repo.Generations {
+		if _, ok := generation.Sources[component]; !ok {
+			continue
+		}
+
+		if refs, ok := repo.generationRefs[generation.ID][component]; ok {
+			result = result.Merge(refs, false, true)
+			continue
+		}
+
+		encoded, err := collection.db.Get(repo.GenerationRefKey(generation.ID, component))
+		if err != nil {
+			if err == database.ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+
+		refs := &PackageRefList{}
+		err = refs.Decode(encoded)
+		if err != nil {
+			return nil, err
+		}
+
+		result = result.Merge(refs, false, true)
+	}
+
+	return result, nil
+}
+
+// ByGenerationSource looks up repositories which have kept generations published from
+// the source (snapshot or local repo), such sources are required to roll back
+func (collection *PublishedRepoCollection) ByGenerationSource(sourceUUID string) []*PublishedRepo {
+	collection.loadList()
+
+	var result []*PublishedRepo
+	for _, r := range collection.list {
+	generations:
+		for _, generation := range r.Generations {
+			for _, uuid := range generation.Sources {
+				if uuid == sourceUUID {
+					result = append(result, r)
+					break generations
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Rollback switches published repository back to the previous generation and saves it,
+// current generation is dropped
+func (collection *PublishedRepoCollection) Rollback(publishedStorageProvider aptly.PublishedStorageProvider,
+	repo *PublishedRepo, collectionFactory *CollectionFactory, progress aptly.Progress) error {
+	if len(repo.Generations) < 2 {
+		return fmt.Errorf("published repository %s has no previous generation to roll back to", repo.StoragePrefix()+"/"+repo.Distribution)
+	}
+
+	current := repo.Generations[len(repo.Generations)-1]
+	previous := repo.Generations[len(repo.Generations)-2]
+
+	publishedStorage := publishedStorageProvider.GetPublishedStorage(repo.Storage)
+
+	exists, err := publishedStorage.FileExists(filepath.Join(repo.generationPath(previous.ID), "Release"))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("files of generation %d are missing", previous.ID)
+	}
+
+	sources := repo.Sources
+	repo.Sources = previous.Sources
+
+	err = collection.LoadComplete(repo, collectionFactory)
+	if err == nil && repo.SourceKind == SourceLocalRepo {
+		for component, item := range repo.sourceItems {
+			var encoded []byte
+			encoded, err = collection.db.Get(repo.GenerationRefKey(previous.ID, component))
+			if err != nil {
+				break
+			}
+
+			item.packageRefs = &PackageRefList{}
+			err = item.packageRefs.Decode(encoded)
+			if err != nil {
+				break
+			}
+			repo.sourceItems[component] = item
+		}
+	}
+	if err != nil {
+		repo.Sources = sources
+		return fmt.Errorf("unable to load sources of generation %d: %s", previous.ID, err)
+	}
+
+	if progress != nil {
+		progress.Printf("Switching to generation %d...\n", previous.ID)
+	}
+
+	err = repo.activateGeneration(publishedStorage, previous.ID)
+	if err != nil {
+		repo.Sources = sources
+		return fmt.Errorf("unable to switch to generation %d: %s", previous.ID, err)
+	}
+
+	repo.IndexChecksums = previous.IndexChecksums
+	repo.dropGeneration(publishedStorage, current.ID, progress)
+
+	return collection.Update(repo)
+}
@@ -0,0 +1,204 @@
+package deb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+
+	. "gopkg.in/check.v1"
+)
+
+// nonAtomicStorage hides SwitchDir of the wrapped storage, like object storages do
+type nonAtomicStorage struct {
+	aptly.PublishedStorage
+}
+
+func (s *PublishedRepoSuite) generationIDs(repo *PublishedRepo) (ids []int) {
+	for _, generation := range repo.Generations {
+		ids = append(ids, generation.ID)
+	}
+	return
+}
+
+func (s *PublishedRepoSuite) TestPublishAtomic(c *C) {
+	s.repo.Atomic = true
+	s.repo.KeepGenerations = 1
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	link, err := os.Readlink(filepath.Join(s.root, "ppa/dists/squeeze"))
+	c.Assert(err, IsNil)
+	c.Check(link, Equals, ".generations/squeeze/1")
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/Release"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"), PathExists)
+	c.Check(s.generationIDs(s.repo), DeepEquals, []int{1})
+	c.Check(s.repo.ActiveGeneration().Sources, DeepEquals, map[string]string{"main": s.snapshot.UUID})
+	c.Check(s.repo.ActiveGeneration().IndexChecksums, DeepEquals, s.repo.IndexChecksums)
+
+	for i := 0; i < 2; i++ {
+		c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	}
+
+	link, err = os.Readlink(filepath.Join(s.root, "ppa/dists/squeeze"))
+	c.Assert(err, IsNil)
+	c.Check(link, Equals, ".generations/squeeze/3")
+	c.Check(s.generationIDs(s.repo), DeepEquals, []int{2, 3})
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/1"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/2/Release"), PathExists)
+
+	// refs of the generations are saved along with published repo
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	_, err = s.db.Get(s.repo.GenerationRefKey(1, "main"))
+	c.Check(err, NotNil)
+
+	refList, err := collection.GenerationsRefList(s.repo, "main")
+	c.Assert(err, IsNil)
+	c.Check(refList.Len(), Equals, s.reflist.Len())
+}
+
+func (s *PublishedRepoSuite) TestPublishAtomicByHash(c *C) {
+	s.repo.Atomic = true
+	s.repo.AcquireByHash = true
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	previous := s.repo.IndexChecksums["main/binary-i386/Packages"].SHA256
+
+	s.repo.Origin = "other"
+	s.repo.sourceItems["main"].snapshot.packageRefs = NewPackageRefList()
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Check(s.repo.IndexChecksums["main/binary-i386/Packages"].SHA256, Not(Equals), previous)
+
+	// index of previous generation is still available by hash
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/by-hash/SHA256", previous), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestPublishNonAtomicStorage(c *C) {
+	s.provider.storages[""] = &nonAtomicStorage{s.publishedStorage}
+	s.repo.Atomic = true
+
+	c.Assert(s.publishedStorage.MkDir("ppa/dists/squeeze/main"), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "ppa/dists/squeeze/main/stale"), nil, 0644), IsNil)
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	info, err := os.Lstat(filepath.Join(s.root, "ppa/dists/squeeze"))
+	c.Assert(err, IsNil)
+	c.Check(info.IsDir(), Equals, true)
+
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/Release"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/main/stale"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/1/Release"), PathExists)
+
+	// files published by previous generation are replaced
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/Release"), PathExists)
+	c.Check(filepath.Join(s.root, "ppa/dists/squeeze/Release.tmp"), Not(PathExists))
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/2/Release"), PathExists)
+}
+
+func (s *PublishedRepoSuite) TestRefreshAtomic(c *C) {
+	s.provider.storages[""] = &nonAtomicStorage{s.publishedStorage}
+	s.repo.Atomic = true
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	s.repo.Origin = "refreshed"
+	c.Assert(s.repo.Refresh(s.provider, &NullSigner{}, nil), IsNil)
+
+	for _, path := range []string{"ppa/dists/.generations/squeeze/1/Release", "ppa/dists/squeeze/Release"} {
+		release, err := ioutil.ReadFile(filepath.Join(s.root, path))
+		c.Assert(err, IsNil)
+		c.Check(string(release), Matches, "(?s).*Origin: refreshed\n.*")
+	}
+
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/1/Release.tmp"), Not(PathExists))
+	c.Check(s.generationIDs(s.repo), DeepEquals, []int{1})
+}
+
+func (s *PublishedRepoSuite) TestRollback(c *C) {
+	collection := s.factory.PublishedRepoCollection()
+
+	s.repo.Atomic = true
+	s.repo.KeepGenerations = 2
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	c.Check(collection.Rollback(s.provider, s.repo, s.factory, nil), ErrorMatches, ".*no previous generation to roll back to")
+
+	s.repo.UpdateSnapshot("main", s.snapshot2)
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Update(s.repo), IsNil)
+	c.Check(s.generationIDs(s.repo), DeepEquals, []int{1, 2})
+
+	c.Assert(collection.Rollback(s.provider, s.repo, s.factory, nil), IsNil)
+
+	link, err := os.Readlink(filepath.Join(s.root, "ppa/dists/squeeze"))
+	c.Assert(err, IsNil)
+	c.Check(link, Equals, ".generations/squeeze/1")
+	c.Check(filepath.Join(s.root, "ppa/dists/.generations/squeeze/2"), Not(PathExists))
+
+	c.Check(s.generationIDs(s.repo), DeepEquals, []int{1})
+	c.Check(s.repo.Sources, DeepEquals, map[string]string{"main": s.snapshot.UUID})
+	c.Check(s.repo.sourceItems["main"].snapshot.UUID, Equals, s.snapshot.UUID)
+
+	_, err = s.db.Get(s.repo.GenerationRefKey(2, "main"))
+	c.Check(err, NotNil)
+
+	// state is persisted
+	collection = NewPublishedRepoCollection(s.db)
+	repo, err := collection.ByStoragePrefixDistribution("", "ppa", "squeeze")
+	c.Assert(err, IsNil)
+	c.Check(repo.Sources, DeepEquals, map[string]string{"main": s.snapshot.UUID})
+	c.Check(s.generationIDs(repo), DeepEquals, []int{1})
+}
+
+func (s *PublishedRepoSuite) TestRollbackLocalRepo(c *C) {
+	collection := s.factory.PublishedRepoCollection()
+
+	s.repo2.Atomic = true
+	s.repo2.KeepGenerations = 1
+	c.Assert(s.repo2.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Add(s.repo2), IsNil)
+
+	s.localRepo.UpdateRefList(NewPackageRefList())
+	s.repo2.UpdateLocalRepo("main")
+	c.Assert(s.repo2.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Update(s.repo2), IsNil)
+	c.Check(s.repo2.RefList("main").Len(), Equals, 0)
+
+	c.Assert(collection.Rollback(s.provider, s.repo2, s.factory, nil), IsNil)
+	c.Check(s.repo2.RefList("main").Len(), Equals, s.reflist.Len())
+
+	collection = NewPublishedRepoCollection(s.db)
+	repo, err := collection.ByStoragePrefixDistribution("", "ppa", "maverick")
+	c.Assert(err, IsNil)
+	c.Assert(collection.LoadComplete(repo, s.factory), IsNil)
+	c.Check(repo.RefList("main").Len(), Equals, s.reflist.Len())
+}
+
+func (s *PublishedRepoSuite) TestByGenerationSource(c *C) {
+	collection := s.factory.PublishedRepoCollection()
+
+	s.repo.Atomic = true
+	s.repo.KeepGenerations = 1
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	s.repo.UpdateSnapshot("main", s.snapshot2)
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Assert(collection.Update(s.repo), IsNil)
+
+	// previous snapshot is no longer published, but it's required for rollback
+	c.Check(collection.BySnapshot(s.snapshot), HasLen, 0)
+	c.Check(collection.ByGenerationSource(s.snapshot.UUID), DeepEquals, []*PublishedRepo{s.repo})
+	c.Check(collection.ByGenerationSource(s.snapshot2.UUID), DeepEquals, []*PublishedRepo{s.repo})
+	c.Check(collection.ByGenerationSource(s.localRepo.UUID), HasLen, 0)
+
+	c.Assert(collection.Rollback(s.provider, s.repo, s.factory, nil), IsNil)
+	c.Check(collection.ByGenerationSource(s.snapshot2.UUID), HasLen, 0)
+}
@@ -0,0 +1,191 @@
+package deb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// PackageOverride is an entry of override file, it replaces fields of the package
+// when it is published
+type PackageOverride struct {
+	Priority string
+	Section  string
+}
+
+// ParseOverrideFile parses override file in the format used by Debian archive:
+// for binary packages (override.<dist>.<component>) each line is
+// "<package> <priority> <section> [<maintainer>]", for source packages
+// (override.<dist>.<component>.src) each line is "<package> <section>";
+// comments start with '#', maintainer info is ignored
+func ParseOverrideFile(r io.Reader, source bool) (map[string]PackageOverride, error) {
+	result := make(map[string]PackageOverride)
+
+	minFields := 3
+	if source {
+		minFields = 2
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < minFields {
+			return nil, fmt.Errorf("malformed override entry at line %d: %s", lineNo, scanner.Text())
+		}
+
+		if source {
+			result[fields[0]] = PackageOverride{Section: fields[1]}
+		} else {
+			result[fields[0]] = PackageOverride{Priority: fields[1], Section: fields[2]}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// overrideTables returns override tables for binary or source packages
+func (p *PublishedRepo) overrideTables(source bool) map[string]map[string]PackageOverride {
+	if source {
+		return p.SourceOverrides
+	}
+	return p.Overrides
+}
+
+// SetOverrides replaces override table of the component for binary or source
+// packages, nil removes it
+func (p *PublishedRepo) SetOverrides(component string, source bool, overrides map[string]PackageOverride) error {
+	if _, ok := p.Sources[component]; !ok {
+		return fmt.Errorf("component %s is not published", component)
+	}
+
+	tables := p.overrideTables(source)
+
+	if overrides == nil {
+		delete(tables, component)
+	} else {
+		if tables == nil {
+			tables = make(map[string]map[string]PackageOverride)
+		}
+		tables[component] = overrides
+	}
+
+	if source {
+		p.SourceOverrides = tables
+	} else {
+		p.Overrides = tables
+	}
+
+	p.rePublishing = true
+
+	return nil
+}
+
+// applyOverride replaces Priority & Section of the package stanza with override entry,
+// binary packages are looked up in binary override table, source packages in source one
+func (p *PublishedRepo) applyOverride(component string, pkg *Package, stanza Stanza) {
+	if pkg.IsInstaller {
+		return
+	}
+
+	override, ok := p.overrideTables(pkg.IsSource)[component][pkg.Name]
+	if !ok {
+		return
+	}
+
+	if override.Priority != "" {
+		stanza["Priority"] = override.Priority
+	}
+	if override.Section != "" {
+		stanza["Section"] = override.Section
+	}
+}
+
+// MissingOverrides returns names of published binary or source packages which have
+// no entry in corresponding override table of the component, components without
+// override table are skipped
+//
+// Published repository should be completely loaded (LoadComplete)
+func (p *PublishedRepo) MissingOverrides(source bool, packageCollection *PackageCollection, progress aptly.Progress) (map[string][]string, error) {
+	result := make(map[string][]string)
+
+	tables := p.overrideTables(source)
+
+	for _, component := range p.Components() {
+		overrides, ok := tables[component]
+		if !ok {
+			continue
+		}
+
+		list, err := NewPackageListFromRefList(p.RefList(component), packageCollection, progress)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		missing := []string{}
+		err = list.ForEach(func(pkg *Package) error {
+			if pkg.IsSource != source || pkg.IsInstaller {
+				return nil
+			}
+			if _, ok := overrides[pkg.Name]; !ok {
+				missing = append(missing, pkg.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			result[component] = utils.StrSliceDeduplicate(missing)
+		}
+	}
+
+	return result, nil
+}
+
+// sameOverrides checks if both published repositories have the same override tables
+func (p *PublishedRepo) sameOverrides(other *PublishedRepo) bool {
+	return sameOverrideTables(p.Overrides, other.Overrides) && sameOverrideTables(p.SourceOverrides, other.SourceOverrides)
+}
+
+func sameOverrideTables(tables, otherTables map[string]map[string]PackageOverride) bool {
+	if len(tables) != len(otherTables) {
+		return false
+	}
+
+	for component, overrides := range tables {
+		otherOverrides, ok := otherTables[component]
+		if !ok || len(otherOverrides) != len(overrides) {
+			return false
+		}
+
+		for name, override := range overrides {
+			if otherOverride, ok := otherOverrides[name]; !ok || otherOverride != override {
+				return false
+			}
+		}
+	}
+
+	return true
+}
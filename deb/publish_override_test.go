@@ -0,0 +1,111 @@
+package deb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type OverrideSuite struct{}
+
+var _ = Suite(&OverrideSuite{})
+
+func (s *OverrideSuite) TestParseOverrideFile(c *C) {
+	overrides, err := ParseOverrideFile(strings.NewReader(`# override.squeeze.main
+alien-arena-common optional games
+mars-invaders	extra	contrib/games	Old Maintainer => New Maintainer # comment
+
+`), false)
+	c.Assert(err, IsNil)
+	c.Check(overrides, DeepEquals, map[string]PackageOverride{
+		"alien-arena-common": {Priority: "optional", Section: "games"},
+		"mars-invaders":      {Priority: "extra", Section: "contrib/games"},
+	})
+
+	_, err = ParseOverrideFile(strings.NewReader("alien-arena-common optional games\nmars-invaders extra\n"), false)
+	c.Check(err, ErrorMatches, "malformed override entry at line 2: mars-invaders extra")
+}
+
+func (s *OverrideSuite) TestParseSourceOverrideFile(c *C) {
+	overrides, err := ParseOverrideFile(strings.NewReader(`# override.squeeze.main.src
+alien-arena games
+mars-invaders	contrib/games # comment
+`), true)
+	c.Assert(err, IsNil)
+	c.Check(overrides, DeepEquals, map[string]PackageOverride{
+		"alien-arena":   {Section: "games"},
+		"mars-invaders": {Section: "contrib/games"},
+	})
+
+	_, err = ParseOverrideFile(strings.NewReader("alien-arena games\nmars-invaders\n"), true)
+	c.Check(err, ErrorMatches, "malformed override entry at line 2: mars-invaders")
+}
+
+func (s *OverrideSuite) TestApplyOverride(c *C) {
+	repo := &PublishedRepo{Sources: map[string]string{"main": "uuid"}}
+	c.Assert(repo.SetOverrides("main", false, map[string]PackageOverride{
+		"access-modifier-checker": {Priority: "important", Section: "non-free/games"},
+	}), IsNil)
+
+	sourceStanza, err := NewControlFileReader(bytes.NewBufferString(sourcePackageMeta), false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	source, err := NewSourcePackageFromControlFile(sourceStanza)
+	c.Assert(err, IsNil)
+
+	// binary table isn't applied to source packages
+	stanza := source.Stanza()
+	repo.applyOverride("main", source, stanza)
+	c.Check(stanza["Section"], Equals, "java")
+	c.Check(stanza["Priority"], Equals, "source")
+
+	c.Assert(repo.SetOverrides("main", true, map[string]PackageOverride{
+		"access-modifier-checker": {Section: "devel"},
+		"alien-arena-common":      {Section: "games"},
+	}), IsNil)
+
+	stanza = source.Stanza()
+	repo.applyOverride("main", source, stanza)
+	c.Check(stanza["Section"], Equals, "devel")
+	c.Check(stanza["Priority"], Equals, "source")
+
+	// source table isn't applied to binary packages
+	binary := NewPackageFromControlFile(packageStanza.Copy())
+	stanza = binary.Stanza()
+	repo.applyOverride("main", binary, stanza)
+	c.Check(stanza["Section"], Equals, "contrib/games")
+
+	c.Assert(repo.SetOverrides("main", true, nil), IsNil)
+	c.Check(repo.SourceOverrides, HasLen, 0)
+	c.Check(repo.Overrides, HasLen, 1)
+}
+
+func (s *PublishedRepoSuite) TestOverrides(c *C) {
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	c.Check(s.repo.SetOverrides("contrib", false, map[string]PackageOverride{}), ErrorMatches, "component contrib is not published")
+
+	c.Assert(s.repo.SetOverrides("main", false, map[string]PackageOverride{
+		"alien-arena-common": {Priority: "important", Section: "non-free/games"},
+	}), IsNil)
+
+	missing, err := s.repo.MissingOverrides(false, s.packageCollection, nil)
+	c.Assert(err, IsNil)
+	c.Check(missing, DeepEquals, map[string][]string{"main": {"lonely-strangers", "mars-invaders"}})
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	packages, err := ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(string(packages), Matches, "(?s)Package: alien-arena-common\nPriority: important\nSection: non-free/games\n.*")
+	c.Check(string(packages), Matches, "(?s).*Package: mars-invaders\nPriority: extra\nSection: contrib/games\n.*")
+
+	c.Assert(s.repo.SetOverrides("main", false, nil), IsNil)
+	c.Check(s.repo.Overrides, HasLen, 0)
+
+	missing, err = s.repo.MissingOverrides(false, s.packageCollection, nil)
+	c.Assert(err, IsNil)
+	c.Check(missing, HasLen, 0)
+}
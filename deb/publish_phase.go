@@ -0,0 +1,99 @@
+package deb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// phasedUpdateField is the field of Packages index clients use to phase updates
+const phasedUpdateField = "Phased-Update-Percentage"
+
+// SetPhasedUpdate sets Phased-Update-Percentage of published binary packages matching
+// the query, percentage of 100 removes phasing; returns number of packages affected
+//
+// Published repository should be completely loaded (LoadComplete)
+func (p *PublishedRepo) SetPhasedUpdate(q PackageQuery, percentage int, packageCollection *PackageCollection,
+	progress aptly.Progress) (int, error) {
+	if percentage < 0 || percentage > 100 {
+		return 0, fmt.Errorf("phased update percentage should be in range 0..100: %d", percentage)
+	}
+
+	matched := map[string]bool{}
+
+	for _, component := range p.Components() {
+		list, err := NewPackageListFromRefList(p.RefList(component), packageCollection, progress)
+		if err != nil {
+			return 0, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		err = list.Scan(q).ForEach(func(pkg *Package) error {
+			if !pkg.IsSource && !pkg.IsInstaller {
+				matched[string(pkg.Key(""))] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(matched) == 0 {
+		return 0, fmt.Errorf("no published binary packages match the query")
+	}
+
+	for key := range matched {
+		if percentage == 100 {
+			delete(p.PhasedUpdates, key)
+			continue
+		}
+
+		if p.PhasedUpdates == nil {
+			p.PhasedUpdates = make(map[string]int)
+		}
+		p.PhasedUpdates[key] = percentage
+	}
+
+	p.rePublishing = true
+
+	return len(matched), nil
+}
+
+// applyPhasedUpdate adds Phased-Update-Percentage to the package stanza if package is phased,
+// keys of phased packages published are collected into seen
+func (p *PublishedRepo) applyPhasedUpdate(pkg *Package, stanza Stanza, seen map[string]bool) {
+	if len(p.PhasedUpdates) == 0 || pkg.IsSource || pkg.IsInstaller {
+		return
+	}
+
+	key := string(pkg.Key(""))
+	if percentage, ok := p.PhasedUpdates[key]; ok {
+		stanza[phasedUpdateField] = strconv.Itoa(percentage)
+		seen[key] = true
+	}
+}
+
+// prunePhasedUpdates drops phasing of the packages which are no longer published
+func (p *PublishedRepo) prunePhasedUpdates(seen map[string]bool) {
+	for key := range p.PhasedUpdates {
+		if !seen[key] {
+			delete(p.PhasedUpdates, key)
+		}
+	}
+}
+
+// samePhasedUpdates checks if both published repositories phase the same packages
+func (p *PublishedRepo) samePhasedUpdates(other *PublishedRepo) bool {
+	if len(p.PhasedUpdates) != len(other.PhasedUpdates) {
+		return false
+	}
+
+	for key, percentage := range p.PhasedUpdates {
+		if otherPercentage, ok := other.PhasedUpdates[key]; !ok || otherPercentage != percentage {
+			return false
+		}
+	}
+
+	return true
+}
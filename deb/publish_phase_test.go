@@ -0,0 +1,48 @@
+package deb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *PublishedRepoSuite) TestSetPhasedUpdate(c *C) {
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	q := &PkgQuery{"alien-arena-common", "7.40-2", "i386"}
+
+	_, err := s.repo.SetPhasedUpdate(q, 101, s.packageCollection, nil)
+	c.Check(err, ErrorMatches, "phased update percentage should be in range 0..100: 101")
+
+	_, err = s.repo.SetPhasedUpdate(&PkgQuery{"nginx", "1.0", "i386"}, 10, s.packageCollection, nil)
+	c.Check(err, ErrorMatches, "no published binary packages match the query")
+
+	count, err := s.repo.SetPhasedUpdate(q, 10, s.packageCollection, nil)
+	c.Assert(err, IsNil)
+	c.Check(count, Equals, 1)
+	c.Check(s.repo.PhasedUpdates, DeepEquals, map[string]int{string(s.p1.Key("")): 10})
+
+	// phasing of packages which are not published anymore is dropped
+	s.repo.PhasedUpdates["Pi386 gone 1.0 00000000"] = 50
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+	c.Check(s.repo.PhasedUpdates, DeepEquals, map[string]int{string(s.p1.Key("")): 10})
+
+	packages, err := ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(strings.Count(string(packages), "Phased-Update-Percentage: 10\n"), Equals, 1)
+	c.Check(string(packages), Matches, "(?s)Package: alien-arena-common\n.*Phased-Update-Percentage: 10\n.*")
+
+	count, err = s.repo.SetPhasedUpdate(q, 100, s.packageCollection, nil)
+	c.Assert(err, IsNil)
+	c.Check(count, Equals, 1)
+	c.Check(s.repo.PhasedUpdates, HasLen, 0)
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false), IsNil)
+
+	packages, err = ioutil.ReadFile(filepath.Join(s.root, "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	c.Check(string(packages), Not(Matches), "(?s).*Phased-Update-Percentage.*")
+}
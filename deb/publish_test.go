@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
@@ -353,6 +355,96 @@ func (s *PublishedRepoSuite) TestPublish(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *PublishedRepoSuite) TestPublishValidUntilSignedBy(c *C) {
+	s.repo.ValidFor = 7 * 24 * time.Hour
+	s.repo.SignedBy = "A0546A43624A8331"
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Assert(err, IsNil)
+
+	rf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+
+	date, err := time.Parse(releaseDateFormat, st["Date"])
+	c.Assert(err, IsNil)
+	validUntil, err := time.Parse(releaseDateFormat, st["Valid-Until"])
+	c.Assert(err, IsNil)
+
+	c.Check(validUntil.Sub(date), Equals, 7*24*time.Hour)
+	c.Check(st["Signed-By"], Equals, "A0546A43624A8331")
+}
+
+func (s *PublishedRepoSuite) TestRefresh(c *C) {
+	err := s.repo.Refresh(s.provider, &NullSigner{}, nil)
+	c.Check(err, ErrorMatches, "checksums of index files are not known.*")
+
+	err = s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Assert(err, IsNil)
+
+	releasePath := filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release")
+	original, err := ioutil.ReadFile(releasePath)
+	c.Assert(err, IsNil)
+
+	s.repo.ValidFor = time.Hour
+	err = s.repo.Refresh(s.provider, &NullSigner{}, nil)
+	c.Assert(err, IsNil)
+
+	rf, err := os.Open(releasePath)
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	st, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+
+	c.Check(st["Valid-Until"], Not(Equals), "")
+	c.Check(st["SHA256"], Not(Equals), "")
+
+	originalSt, err := NewControlFileReader(bytes.NewReader(original), true, false).ReadStanza()
+	c.Assert(err, IsNil)
+
+	// index checksums are preserved
+	c.Check(st["SHA256"], Equals, originalSt["SHA256"])
+
+	_, err = os.Stat(releasePath + ".tmp")
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *PublishedRepoSuite) TestPublishTranslations(c *C) {
+	s.repo.Translations = true
+
+	err := s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false)
+	c.Assert(err, IsNil)
+
+	tf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/i18n/Translation-en"))
+	c.Assert(err, IsNil)
+	defer tf.Close()
+
+	translation, err := NewControlFileReader(tf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(translation["Package"], Equals, "alien-arena-common")
+
+	pf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/main/binary-i386/Packages"))
+	c.Assert(err, IsNil)
+	defer pf.Close()
+
+	st, err := NewControlFileReader(pf, false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(st["Description-md5"], Equals, translation["Description-md5"])
+	c.Check(strings.Count(st["Description"], "\n"), Equals, 1)
+
+	rf, err := os.Open(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	defer rf.Close()
+
+	release, err := NewControlFileReader(rf, true, false).ReadStanza()
+	c.Assert(err, IsNil)
+	c.Check(release["SHA256"], Matches, "(?s).* main/i18n/Translation-en.gz\n.*")
+}
+
 func (s *PublishedRepoSuite) TestPublishNoSigner(c *C) {
 	err := s.repo.Publish(s.packagePool, s.provider, s.factory, nil, nil, false)
 	c.Assert(err, IsNil)
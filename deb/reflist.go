@@ -181,8 +181,13 @@ func (d PackageDiff) MarshalJSON() ([]byte, error) {
 // PackageDiffs is a list of PackageDiff records
 type PackageDiffs []PackageDiff
 
+// PackageKeyLookup loads packages by key, e.g. PackageCollection
+type PackageKeyLookup interface {
+	ByKey(key []byte) (*Package, error)
+}
+
 // Diff calculates difference between two reflists
-func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageCollection) (result PackageDiffs, err error) {
+func (l *PackageRefList) Diff(r *PackageRefList, packageCollection PackageKeyLookup) (result PackageDiffs, err error) {
 	result = make(PackageDiffs, 0, 128)
 
 	// pointer to left and right reflists
@@ -192,6 +197,28 @@ func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageColle
 	// cached loaded packages on the left & right
 	pl, pr := (*Package)(nil), (*Package)(nil)
 
+	// package present only in the left list
+	addLeft := func(pl *Package) {
+		// compaction: +(,A) -(B,) --> !(A,B)
+		if len(result) > 0 && result[len(result)-1].Left == nil && result[len(result)-1].Right.Name == pl.Name &&
+			result[len(result)-1].Right.Architecture == pl.Architecture {
+			result[len(result)-1] = PackageDiff{Left: pl, Right: result[len(result)-1].Right}
+		} else {
+			result = append(result, PackageDiff{Left: pl, Right: nil})
+		}
+	}
+
+	// package present only in the right list
+	addRight := func(pr *Package) {
+		// compaction: -(A,) +(,B) --> !(A,B)
+		if len(result) > 0 && result[len(result)-1].Right == nil && result[len(result)-1].Left.Name == pr.Name &&
+			result[len(result)-1].Left.Architecture == pr.Architecture {
+			result[len(result)-1] = PackageDiff{Left: result[len(result)-1].Left, Right: pr}
+		} else {
+			result = append(result, PackageDiff{Left: nil, Right: pr})
+		}
+	}
+
 	// until we reached end of both lists
 	for il < ll || ir < lr {
 		// if we've exhausted left list, pull the rest from the right
@@ -200,7 +227,7 @@ func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageColle
 			if err != nil {
 				return nil, err
 			}
-			result = append(result, PackageDiff{Left: nil, Right: pr})
+			addRight(pr)
 			ir++
 			continue
 		}
@@ -210,7 +237,7 @@ func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageColle
 			if err != nil {
 				return nil, err
 			}
-			result = append(result, PackageDiff{Left: pl, Right: nil})
+			addLeft(pl)
 			il++
 			continue
 		}
@@ -243,23 +270,11 @@ func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageColle
 
 			// otherwise pl or pr is missing on one of the sides
 			if rel < 0 {
-				// compaction: +(,A) -(B,) --> !(A,B)
-				if len(result) > 0 && result[len(result)-1].Left == nil && result[len(result)-1].Right.Name == pl.Name &&
-					result[len(result)-1].Right.Architecture == pl.Architecture {
-					result[len(result)-1] = PackageDiff{Left: pl, Right: result[len(result)-1].Right}
-				} else {
-					result = append(result, PackageDiff{Left: pl, Right: nil})
-				}
+				addLeft(pl)
 				il++
 				pl = nil
 			} else {
-				// compaction: -(A,) +(,B) --> !(A,B)
-				if len(result) > 0 && result[len(result)-1].Right == nil && result[len(result)-1].Left.Name == pr.Name &&
-					result[len(result)-1].Left.Architecture == pr.Architecture {
-					result[len(result)-1] = PackageDiff{Left: result[len(result)-1].Left, Right: pr}
-				} else {
-					result = append(result, PackageDiff{Left: nil, Right: pr})
-				}
+				addRight(pr)
 				ir++
 				pr = nil
 			}
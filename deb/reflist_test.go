@@ -177,6 +177,7 @@ func (s *PackageRefListSuite) TestDiff(c *C) {
 		{Name: "app", Version: "1.1~bp2", Architecture: "i386"},  //4
 		{Name: "app", Version: "1.1~bp2", Architecture: "amd64"}, //5
 		{Name: "xyz", Version: "3.0", Architecture: "sparc"},     //6
+		{Name: "xyz", Version: "3.1", Architecture: "sparc"},     //7
 	}
 
 	for _, p := range packages {
@@ -235,6 +236,17 @@ func (s *PackageRefListSuite) TestDiff(c *C) {
 	c.Check(diffBA[3].Right.String(), Equals, "xyz_3.0_sparc")
 	c.Check(diffBA[3].Left, IsNil)
 
+	// changed package at the end of the list
+	listC := NewPackageList()
+	listC.Add(packages[0])
+	listC.Add(packages[7])
+
+	diffAC, err := reflistA.Diff(NewPackageRefListFromPackageList(listC), coll)
+	c.Check(err, IsNil)
+	c.Check(diffAC, HasLen, 4)
+
+	c.Check(diffAC[3].Left.String(), Equals, "xyz_3.0_sparc")
+	c.Check(diffAC[3].Right.String(), Equals, "xyz_3.1_sparc")
 }
 
 func (s *PackageRefListSuite) TestMerge(c *C) {
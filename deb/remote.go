@@ -3,12 +3,15 @@ package deb
 import (
 	"bytes"
 	gocontext "context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -41,6 +44,8 @@ type RemoteRepo struct {
 	Name string
 	// Root of Debian archive, URL
 	ArchiveRoot string
+	// Ordered list of archive roots: ArchiveRoot followed by fallback roots
+	ArchiveRoots []string
 	// Distribution name, e.g. squeeze
 	Distribution string
 	// List of components to fetch, if empty, then fetch all components
@@ -71,12 +76,28 @@ type RemoteRepo struct {
 	DownloadUdebs bool
 	// Should we download installer files?
 	DownloadInstaller bool
+	// Installer image flavours to download (globs matching directories under images/), all if empty
+	InstallerFlavours []string `json:",omitempty"`
+	// Should we download DEP-11 AppStream metadata and icons?
+	DownloadAppStream bool `json:",omitempty"`
+	// DEP-11 files downloaded by the last update
+	AppStreamFiles []AppStreamFile `json:",omitempty"`
+	// Retention policy for snapshots created from the mirror
+	Retention *RetentionPolicy `json:",omitempty"`
+	// Download settings overriding global configuration
+	DownloadSettings *DownloadSettings `json:",omitempty"`
+	// TLS, proxy and authentication settings
+	Connection *ConnectionSettings `json:",omitempty"`
 	// Packages for json output
 	Packages []string `codec:"-" json:",omitempty"`
 	// "Snapshot" of current list of packages
 	packageRefs *PackageRefList
-	// Parsed archived root
+	// Parsed archived root (the one selected by last Fetch)
 	archiveRootURL *url.URL
+	// Parsed archive roots
+	archiveRootURLs []*url.URL
+	// Roots which serve the same Release file as selected root (filled by Fetch)
+	healthyRootURLs []*url.URL
 	// Current list of packages (filled while updating mirror)
 	packageList *PackageList
 }
@@ -119,22 +140,82 @@ func NewRemoteRepo(name string, archiveRoot string, distribution string, compone
 	return result, nil
 }
 
-// SetArchiveRoot of remote repo
+// SetArchiveRoot of remote repo, fallback roots are preserved
 func (repo *RemoteRepo) SetArchiveRoot(archiveRoot string) {
 	repo.ArchiveRoot = archiveRoot
+	repo.ArchiveRoots = append([]string{archiveRoot}, repo.FallbackRoots()...)
+	repo.resetLastKnownDate()
 	repo.prepare()
 }
 
+// FallbackRoots returns list of archive roots to be used if ArchiveRoot is not available
+func (repo *RemoteRepo) FallbackRoots() []string {
+	if len(repo.ArchiveRoots) < 2 {
+		return nil
+	}
+
+	return repo.ArchiveRoots[1:]
+}
+
+// SetFallbackRoots replaces list of fallback archive roots
+func (repo *RemoteRepo) SetFallbackRoots(fallbackRoots []string) error {
+	repo.ArchiveRoots = []string{repo.ArchiveRoot}
+	for _, root := range fallbackRoots {
+		if root != "" {
+			repo.ArchiveRoots = append(repo.ArchiveRoots, root)
+		}
+	}
+	repo.resetLastKnownDate()
+
+	return repo.prepare()
+}
+
+// CheckLocalRoots verifies that local archive roots (file://) of the mirror
+// are located in one of allowed local directories
+func (repo *RemoteRepo) CheckLocalRoots(localRoots []string) error {
+	for _, root := range repo.ArchiveRoots {
+		if err := http.CheckLocalURL(root, localRoots); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resetLastKnownDate forgets Date of last fetched Release file, so that
+// Release file from new set of roots is not compared against it
+func (repo *RemoteRepo) resetLastKnownDate() {
+	if repo.Meta != nil {
+		delete(repo.Meta, "Date")
+	}
+}
+
 func (repo *RemoteRepo) prepare() error {
-	var err error
+	if len(repo.ArchiveRoots) == 0 || repo.ArchiveRoots[0] != repo.ArchiveRoot {
+		// mirrors created before fallback roots were introduced
+		repo.ArchiveRoots = append([]string{repo.ArchiveRoot}, repo.FallbackRoots()...)
+	}
+
+	repo.archiveRootURLs = make([]*url.URL, len(repo.ArchiveRoots))
 
-	// Add final / to URL
-	if !strings.HasSuffix(repo.ArchiveRoot, "/") {
-		repo.ArchiveRoot = repo.ArchiveRoot + "/"
+	for i := range repo.ArchiveRoots {
+		// Add final / to URL
+		if !strings.HasSuffix(repo.ArchiveRoots[i], "/") {
+			repo.ArchiveRoots[i] = repo.ArchiveRoots[i] + "/"
+		}
+
+		var err error
+		repo.archiveRootURLs[i], err = url.Parse(repo.ArchiveRoots[i])
+		if err != nil {
+			return err
+		}
 	}
 
-	repo.archiveRootURL, err = url.Parse(repo.ArchiveRoot)
-	return err
+	repo.ArchiveRoot = repo.ArchiveRoots[0]
+	repo.archiveRootURL = repo.archiveRootURLs[0]
+	repo.healthyRootURLs = nil
+
+	return nil
 }
 
 // String interface
@@ -153,7 +234,23 @@ func (repo *RemoteRepo) String() string {
 	if distribution == "" {
 		distribution = "./"
 	}
-	return fmt.Sprintf("[%s]: %s %s%s", repo.Name, repo.ArchiveRoot, distribution, srcFlag)
+	return fmt.Sprintf("[%s]: %s %s%s", repo.Name, redactURL(repo.ArchiveRoot), distribution, srcFlag)
+}
+
+// MarshalJSON hides credentials in archive root URLs from JSON output
+func (repo *RemoteRepo) MarshalJSON() ([]byte, error) {
+	type plain RemoteRepo
+
+	result := plain(*repo)
+	result.ArchiveRoot = redactURL(result.ArchiveRoot)
+	if result.ArchiveRoots != nil {
+		result.ArchiveRoots = make([]string, len(repo.ArchiveRoots))
+		for i := range repo.ArchiveRoots {
+			result.ArchiveRoots[i] = redactURL(repo.ArchiveRoots[i])
+		}
+	}
+
+	return json.Marshal(result)
 }
 
 // IsFlat determines if repository is flat
@@ -209,6 +306,10 @@ func (repo *RemoteRepo) CheckLock() error {
 
 // IndexesRootURL builds URL for various indexes
 func (repo *RemoteRepo) IndexesRootURL() *url.URL {
+	return repo.indexesRootURL(repo.archiveRootURL)
+}
+
+func (repo *RemoteRepo) indexesRootURL(root *url.URL) *url.URL {
 	var path *url.URL
 
 	if !repo.IsFlat() {
@@ -217,12 +318,16 @@ func (repo *RemoteRepo) IndexesRootURL() *url.URL {
 		path = &url.URL{Path: repo.Distribution}
 	}
 
-	return repo.archiveRootURL.ResolveReference(path)
+	return root.ResolveReference(path)
 }
 
 // ReleaseURL returns URL to Release* files in repo root
 func (repo *RemoteRepo) ReleaseURL(name string) *url.URL {
-	return repo.IndexesRootURL().ResolveReference(&url.URL{Path: name})
+	return repo.releaseURL(repo.archiveRootURL, name)
+}
+
+func (repo *RemoteRepo) releaseURL(root *url.URL, name string) *url.URL {
+	return repo.indexesRootURL(root).ResolveReference(&url.URL{Path: name})
 }
 
 // FlatBinaryPath returns path to Packages files for flat repo
@@ -265,8 +370,50 @@ func (repo *RemoteRepo) PackageURL(filename string) *url.URL {
 	return repo.archiveRootURL.ResolveReference(path)
 }
 
-// Fetch updates information about repository
-func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier) error {
+// healthyRoots returns list of roots to download files from, selected root goes first
+func (repo *RemoteRepo) healthyRoots() []*url.URL {
+	if len(repo.healthyRootURLs) == 0 {
+		return []*url.URL{repo.archiveRootURL}
+	}
+
+	return repo.healthyRootURLs
+}
+
+// packageURLs returns list of URLs to download package file from, n-th file
+// starts with n-th healthy root, so that downloads are spread across roots
+func (repo *RemoteRepo) packageURLs(filename string, n int) []string {
+	roots := repo.healthyRoots()
+	result := make([]string, len(roots))
+
+	for i := range roots {
+		result[i] = roots[(n+i)%len(roots)].ResolveReference(&url.URL{Path: filename}).String()
+	}
+
+	return result
+}
+
+// parseReleaseDate parses Date field of Release file
+func parseReleaseDate(date string) (time.Time, error) {
+	var (
+		t   time.Time
+		err error
+	)
+
+	// some archives pad day & hour with spaces
+	date = strings.Join(strings.Fields(date), " ")
+
+	for _, layout := range []string{"Mon, 2 Jan 2006 15:04:05 MST", "Mon, 2 Jan 2006 15:04:05 -0700"} {
+		t, err = time.Parse(layout, date)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return t, err
+}
+
+// fetchRelease downloads and verifies Release file from archive root
+func (repo *RemoteRepo) fetchRelease(root *url.URL, d aptly.Downloader, verifier pgp.Verifier) (Stanza, error) {
 	var (
 		release, inrelease, releasesig *os.File
 		err                            error
@@ -274,13 +421,13 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier) error {
 
 	if verifier == nil {
 		// 0. Just download release file to temporary URL
-		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release").String())
+		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.releaseURL(root, "Release").String())
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		// 1. try InRelease file
-		inrelease, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("InRelease").String())
+		inrelease, err = http.DownloadTemp(gocontext.TODO(), d, repo.releaseURL(root, "InRelease").String())
 		if err != nil {
 			goto splitsignature
 		}
@@ -302,24 +449,24 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier) error {
 
 	splitsignature:
 		// 2. try Release + Release.gpg
-		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release").String())
+		release, err = http.DownloadTemp(gocontext.TODO(), d, repo.releaseURL(root, "Release").String())
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		releasesig, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("Release.gpg").String())
+		releasesig, err = http.DownloadTemp(gocontext.TODO(), d, repo.releaseURL(root, "Release.gpg").String())
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		err = verifier.VerifyDetachedSignature(releasesig, release, true)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		_, err = release.Seek(0, 0)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 ok:
@@ -327,9 +474,67 @@ ok:
 	defer release.Close()
 
 	sreader := NewControlFileReader(release, true, false)
-	stanza, err := sreader.ReadStanza()
-	if err != nil {
-		return err
+	return sreader.ReadStanza()
+}
+
+// Fetch updates information about repository
+//
+// Release file is fetched from all the archive roots, first root which serves
+// valid Release file (not older than the one fetched last time) is selected
+// for downloading, other roots with the same Release file are used as fallbacks
+func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier) error {
+	var (
+		stanza      Stanza
+		selected    bool
+		lastDate    time.Time
+		errs        []string
+		err         error
+		hasLastDate bool
+	)
+
+	if repo.Meta != nil && repo.Meta["Date"] != "" {
+		lastDate, err = parseReleaseDate(repo.Meta["Date"])
+		hasLastDate = err == nil
+	}
+
+	repo.healthyRootURLs = nil
+
+	for _, root := range repo.archiveRootURLs {
+		var rootStanza Stanza
+
+		rootStanza, err = repo.fetchRelease(root, d, verifier)
+		if err == nil && hasLastDate {
+			var date time.Time
+			date, err = parseReleaseDate(rootStanza["Date"])
+			if err == nil && date.Before(lastDate) {
+				err = fmt.Errorf("Release file is older than the last fetched one (%s < %s)", rootStanza["Date"], repo.Meta["Date"])
+			} else {
+				// Release files without parseable Date are accepted
+				err = nil
+			}
+		}
+
+		if err != nil {
+			if len(repo.archiveRootURLs) == 1 {
+				return err
+			}
+
+			errs = append(errs, fmt.Sprintf("%s: %s", root, err))
+			continue
+		}
+
+		if !selected {
+			selected = true
+			stanza = rootStanza
+			repo.archiveRootURL = root
+			repo.healthyRootURLs = append(repo.healthyRootURLs, root)
+		} else if rootStanza["Date"] == stanza["Date"] {
+			repo.healthyRootURLs = append(repo.healthyRootURLs, root)
+		}
+	}
+
+	if !selected {
+		return fmt.Errorf("unable to fetch Release file from any archive root:\n  %s", strings.Join(errs, "\n  "))
 	}
 
 	if !repo.IsFlat() {
@@ -424,8 +629,11 @@ ok:
 }
 
 // DownloadPackageIndexes downloads & parses package index files
+//
+// If indexCachePath is not empty, copies of downloaded indexes are kept there,
+// and next time indexes are updated incrementally via PDiff when archive supports that
 func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.Downloader, verifier pgp.Verifier, collectionFactory *CollectionFactory,
-	ignoreMismatch bool) error {
+	ignoreMismatch bool, indexCachePath string) error {
 	if repo.packageList != nil {
 		panic("packageList != nil")
 	}
@@ -456,54 +664,42 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 		}
 	}
 
+	cacheDir := ""
+	if indexCachePath != "" {
+		cacheDir = repo.IndexCacheDir(indexCachePath)
+	}
+
 	for _, info := range packagesPaths {
 		path, kind, component, architecture := info[0], info[1], info[2], info[3]
-		packagesReader, packagesFile, err := http.DownloadTryCompression(gocontext.TODO(), d, repo.IndexesRootURL(), path, repo.ReleaseFiles, ignoreMismatch)
-
 		isInstaller := kind == PackageTypeInstaller
-		if err != nil {
-			if _, ok := err.(*http.NoCandidateFoundError); isInstaller && ok {
-				// checking if gpg file is only needed when checksums matches are required.
-				// otherwise there actually has been no candidate found and we can continue
-				if ignoreMismatch {
-					continue
-				}
-
-				// some repos do not have installer hashsum file listed in release file but provide a separate gpg file
-				hashsumPath := repo.IndexesRootURL().ResolveReference(&url.URL{Path: path}).String()
-				packagesFile, err = http.DownloadTemp(gocontext.TODO(), d, hashsumPath)
-				if err != nil {
-					if herr, ok := err.(*http.Error); ok && (herr.Code == 404 || herr.Code == 403) {
-						// installer files are not available in all components and architectures
-						// so ignore it if not found
-						continue
-					}
-
-					return err
-				}
-
-				if verifier != nil {
-					hashsumGpgPath := repo.IndexesRootURL().ResolveReference(&url.URL{Path: path + ".gpg"}).String()
-					var filesig *os.File
-					filesig, err = http.DownloadTemp(gocontext.TODO(), d, hashsumGpgPath)
-					if err != nil {
-						return err
-					}
 
-					err = verifier.VerifyDetachedSignature(filesig, packagesFile, false)
-					if err != nil {
-						return err
-					}
-
-					_, err = packagesFile.Seek(0, 0)
-				}
+		var (
+			packagesReader io.Reader
+			packagesFile   *os.File
+			err            error
+		)
 
+		if cacheDir != "" && !isInstaller {
+			packagesFile, err = repo.downloadIndexPDiff(d, path, cacheDir)
+			if err == nil {
 				packagesReader = packagesFile
+			} else if err != errPDiffNotAvailable && progress != nil {
+				progress.ColoredPrintf("@y[!]@| @!unable to update %s with pdiff, downloading full index: %s@|", path, err)
 			}
+		}
 
-			if err != nil {
-				return err
+		if isInstaller {
+			packagesReader, packagesFile, err = repo.downloadInstallerHashsum(d, verifier, path, ignoreMismatch)
+			if err == nil && packagesFile == nil {
+				// installer files are not available in all components and architectures
+				continue
 			}
+		} else if packagesFile == nil {
+			packagesReader, packagesFile, err = repo.downloadIndex(d, path, ignoreMismatch)
+		}
+
+		if err != nil {
+			return err
 		}
 		defer packagesFile.Close()
 
@@ -512,6 +708,17 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 			progress.InitBar(stat.Size(), true)
 		}
 
+		var cache *indexCacheWriter
+		if cacheDir != "" && !isInstaller {
+			cache, err = newIndexCacheWriter(filepath.Join(cacheDir, path))
+			if err != nil {
+				return err
+			}
+			defer cache.Abort()
+
+			packagesReader = io.TeeReader(packagesReader, cache)
+		}
+
 		sreader := NewControlFileReader(packagesReader, false, isInstaller)
 
 		for {
@@ -544,6 +751,10 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 				if err != nil {
 					return err
 				}
+				if p == nil {
+					// no files matching installer flavours
+					continue
+				}
 			}
 			err = repo.packageList.Add(p)
 			if _, ok := err.(*PackageConflictError); ok {
@@ -558,11 +769,87 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 		if progress != nil {
 			progress.ShutdownBar()
 		}
+
+		if cache != nil {
+			if err = cache.Commit(); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// downloadInstallerHashsum downloads installer hash sum file
+//
+// If hash sum file is listed in Release file, it's verified by checksum, otherwise
+// its detached signature is verified against mirror keyring. If hash sum file is not
+// available, nil file is returned
+func (repo *RemoteRepo) downloadInstallerHashsum(d aptly.Downloader, verifier pgp.Verifier, path string, ignoreMismatch bool) (io.Reader, *os.File, error) {
+	if _, listed := repo.ReleaseFiles[path]; listed {
+		return repo.downloadIndex(d, path, ignoreMismatch)
+	}
+
+	// some repos do not have installer hashsum file listed in release file but provide a separate gpg file
+	hashsumURL := repo.IndexesRootURL().ResolveReference(&url.URL{Path: path}).String()
+	file, err := http.DownloadTemp(gocontext.TODO(), d, hashsumURL)
+	if err != nil {
+		if herr, ok := err.(*http.Error); ok && (herr.Code == 404 || herr.Code == 403) {
+			return nil, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	if verifier != nil {
+		var signature *os.File
+		signature, err = http.DownloadTemp(gocontext.TODO(), d, hashsumURL+".gpg")
+		if err != nil {
+			file.Close()
+			if herr, ok := err.(*http.Error); ok && (herr.Code == 404 || herr.Code == 403) {
+				return nil, nil, fmt.Errorf("%s is not listed in Release file and has no signature", path)
+			}
+			return nil, nil, err
+		}
+		defer signature.Close()
+
+		err = verifier.VerifyDetachedSignature(signature, file, false)
+		if err == nil {
+			_, err = file.Seek(0, 0)
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("unable to verify signature of %s: %s", path, err)
+		}
+	}
+
+	return file, file, nil
+}
+
+// downloadIndex downloads index file trying healthy roots one by one
+func (repo *RemoteRepo) downloadIndex(d aptly.Downloader, path string, ignoreMismatch bool) (io.Reader, *os.File, error) {
+	var err error
+
+	for _, root := range repo.healthyRoots() {
+		var (
+			reader io.Reader
+			file   *os.File
+		)
+
+		reader, file, err = http.DownloadTryCompression(gocontext.TODO(), d, repo.indexesRootURL(root), path, repo.ReleaseFiles, ignoreMismatch)
+		if err == nil {
+			return reader, file, nil
+		}
+
+		if _, ok := err.(*http.NoCandidateFoundError); ok {
+			// file is not listed in Release file, other roots won't help
+			break
+		}
+	}
+
+	return nil, nil, err
+}
+
 // ApplyFilter applies filtering to already built PackageList
 func (repo *RemoteRepo) ApplyFilter(dependencyOptions int, filterQuery PackageQuery, progress aptly.Progress) (oldLen, newLen int, err error) {
 	repo.packageList.PrepareIndex()
@@ -607,6 +894,7 @@ func (repo *RemoteRepo) BuildDownloadQueue(packagePool aptly.PackagePool, packag
 			key := task.File.DownloadURL()
 			idx, found := seen[key]
 			if !found {
+				task.URLs = repo.packageURLs(key, len(queue))
 				queue = append(queue, task)
 				downloadSize += task.File.Checksums.Size
 				seen[key] = len(queue) - 1
@@ -832,7 +1120,17 @@ func (collection *RemoteRepoCollection) Update(repo *RemoteRepo) error {
 		}
 	}
 
-	return transaction.Commit()
+	err = transaction.Commit()
+	if err != nil {
+		return err
+	}
+
+	// repo might be a copy of cached one with modified settings
+	if _, cached := collection.cache[repo.UUID]; cached {
+		collection.cache[repo.UUID] = repo
+	}
+
+	return nil
 }
 
 // LoadComplete loads additional information for remote repo
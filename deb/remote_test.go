@@ -5,7 +5,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/console"
@@ -22,6 +24,14 @@ import (
 type NullVerifier struct {
 }
 
+type failingVerifier struct {
+	NullVerifier
+}
+
+func (f *failingVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, hint bool) error {
+	return errors.New("bad signature")
+}
+
 func (n *NullVerifier) InitKeyring() error {
 	return nil
 }
@@ -247,6 +257,105 @@ func (s *RemoteRepoSuite) TestFetchWrongComponent(c *C) {
 	c.Assert(err, ErrorMatches, "component xyz not available in repo.*")
 }
 
+func (s *RemoteRepoSuite) TestFallbackRoots(c *C) {
+	c.Check(s.repo.ArchiveRoots, DeepEquals, []string{"http://mirror.yandex.ru/debian/"})
+	c.Check(s.repo.FallbackRoots(), IsNil)
+
+	c.Assert(s.repo.SetFallbackRoots([]string{"http://ftp.debian.org/debian", ""}), IsNil)
+	c.Check(s.repo.ArchiveRoots, DeepEquals, []string{"http://mirror.yandex.ru/debian/", "http://ftp.debian.org/debian/"})
+	c.Check(s.repo.FallbackRoots(), DeepEquals, []string{"http://ftp.debian.org/debian/"})
+
+	s.repo.SetArchiveRoot("http://deb.debian.org/debian")
+	c.Check(s.repo.ArchiveRoot, Equals, "http://deb.debian.org/debian/")
+	c.Check(s.repo.ArchiveRoots, DeepEquals, []string{"http://deb.debian.org/debian/", "http://ftp.debian.org/debian/"})
+
+	c.Check(s.repo.SetFallbackRoots([]string{"http://lolo%2"}), ErrorMatches, ".*(hexadecimal escape in host|percent-encoded characters in host|invalid URL escape).*")
+}
+
+func (s *RemoteRepoSuite) TestFetchFallback(c *C) {
+	c.Assert(s.repo.SetFallbackRoots([]string{"http://ftp.debian.org/debian/"}), IsNil)
+
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/Release", &http.Error{Code: 503})
+	downloader.ExpectResponse("http://ftp.debian.org/debian/dists/squeeze/Release", exampleReleaseFile)
+
+	err := s.repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+	c.Assert(downloader.Empty(), Equals, true)
+	c.Check(s.repo.ReleaseFiles, HasLen, 39)
+	c.Check(s.repo.PackageURL("pool/main/a/amanda/amanda.deb").String(), Equals, "http://ftp.debian.org/debian/pool/main/a/amanda/amanda.deb")
+
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/Release", &http.Error{Code: 503})
+	downloader.ExpectError("http://ftp.debian.org/debian/dists/squeeze/Release", &http.Error{Code: 404})
+
+	err = s.repo.Fetch(downloader, nil)
+	c.Assert(err, ErrorMatches, "(?s)unable to fetch Release file from any archive root:.*mirror.yandex.ru.*ftp.debian.org.*")
+}
+
+func (s *RemoteRepoSuite) TestFetchOlderRelease(c *C) {
+	newerReleaseFile := strings.Replace(exampleReleaseFile, "Thu, 05 Dec 2013  8:14:32 UTC", "Fri, 06 Dec 2013 08:14:32 UTC", 1)
+
+	s.repo.Meta = Stanza{"Date": "Fri, 06 Dec 2013 08:14:32 UTC"}
+
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+
+	err := s.repo.Fetch(downloader, nil)
+	c.Assert(err, ErrorMatches, "Release file is older than the last fetched one.*")
+
+	c.Assert(s.repo.SetFallbackRoots([]string{"http://ftp.debian.org/debian/"}), IsNil)
+	s.repo.Meta = Stanza{"Date": "Fri, 06 Dec 2013 08:14:32 UTC"}
+
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	downloader.ExpectResponse("http://ftp.debian.org/debian/dists/squeeze/Release", newerReleaseFile)
+
+	err = s.repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+	c.Assert(downloader.Empty(), Equals, true)
+	c.Check(s.repo.Meta["Date"], Equals, "Fri, 06 Dec 2013 08:14:32 UTC")
+	c.Check(s.repo.IndexesRootURL().String(), Equals, "http://ftp.debian.org/debian/dists/squeeze/")
+}
+
+func (s *RemoteRepoSuite) TestDownloadFallback(c *C) {
+	s.repo.Architectures = []string{"i386"}
+	c.Assert(s.repo.SetFallbackRoots([]string{"http://ftp.debian.org/debian/", "http://stale.debian.org/debian/"}), IsNil)
+
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	downloader.ExpectResponse("http://ftp.debian.org/debian/dists/squeeze/Release", exampleReleaseFile)
+	downloader.ExpectResponse("http://stale.debian.org/debian/dists/squeeze/Release",
+		strings.Replace(exampleReleaseFile, "Thu, 05 Dec 2013  8:14:32 UTC", "Wed, 04 Dec 2013 08:14:32 UTC", 1))
+
+	err := s.repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+
+	// primary root is half-synced, indexes are taken from the fallback root
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", &http.Error{Code: 404})
+	downloader.ExpectError("http://ftp.debian.org/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	downloader.ExpectError("http://ftp.debian.org/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	downloader.ExpectResponse("http://ftp.debian.org/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, false, "")
+	c.Assert(err, IsNil)
+	c.Assert(downloader.Empty(), Equals, true)
+
+	// stale root is not used for package downloads
+	queue, _, err := s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+	c.Assert(queue, HasLen, 1)
+	c.Check(queue[0].URLs, DeepEquals, []string{
+		"http://mirror.yandex.ru/debian/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb",
+		"http://ftp.debian.org/debian/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb",
+	})
+
+	c.Check(s.repo.packageURLs("pool/b.deb", 1), DeepEquals, []string{
+		"http://ftp.debian.org/debian/pool/b.deb",
+		"http://mirror.yandex.ru/debian/pool/b.deb",
+	})
+}
+
 func (s *RemoteRepoSuite) TestEncodeDecode(c *C) {
 	repo := &RemoteRepo{}
 	err := repo.Decode(s.repo.Encode())
@@ -254,6 +363,13 @@ func (s *RemoteRepoSuite) TestEncodeDecode(c *C) {
 
 	c.Check(repo.Name, Equals, "yandex")
 	c.Check(repo.ArchiveRoot, Equals, "http://mirror.yandex.ru/debian/")
+	c.Check(repo.ArchiveRoots, DeepEquals, []string{"http://mirror.yandex.ru/debian/"})
+
+	s.repo.SetFallbackRoots([]string{"http://ftp.debian.org/debian/"})
+	repo = &RemoteRepo{}
+	c.Assert(repo.Decode(s.repo.Encode()), IsNil)
+	c.Check(repo.ArchiveRoots, DeepEquals, []string{"http://mirror.yandex.ru/debian/", "http://ftp.debian.org/debian/"})
+	c.Check(repo.PackageURL("pool/a.deb").String(), Equals, "http://mirror.yandex.ru/debian/pool/a.deb")
 }
 
 func (s *RemoteRepoSuite) TestKey(c *C) {
@@ -277,7 +393,7 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -304,7 +420,7 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -325,7 +441,7 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -352,7 +468,7 @@ func (s *RemoteRepoSuite) TestDownloadWithInstaller(c *C) {
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS", exampleInstallerHashSumFile)
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/MANIFEST", exampleInstallerManifestFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -382,6 +498,87 @@ func (s *RemoteRepoSuite) TestDownloadWithInstaller(c *C) {
 	c.Check(pkg.Name, Equals, "installer")
 }
 
+func (s *RemoteRepoSuite) TestDownloadWithInstallerSignature(c *C) {
+	s.repo.Architectures = []string{"i386"}
+	s.repo.DownloadInstaller = true
+
+	err := s.repo.Fetch(s.downloader, nil)
+	c.Assert(err, IsNil)
+
+	expectIndexes := func(ignoreMismatch bool) {
+		s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+		s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+		if ignoreMismatch {
+			s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.xz", &http.Error{Code: 404})
+		}
+		s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+		s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS", exampleInstallerHashSumFile)
+	}
+
+	// hash sum file isn't listed in Release file and has no signature
+	expectIndexes(false)
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS.gpg", &http.Error{Code: 404})
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, &NullVerifier{}, s.collectionFactory, false, "")
+	c.Assert(err, ErrorMatches, "main/installer-i386/current/images/SHA256SUMS is not listed in Release file and has no signature")
+	c.Assert(s.downloader.Empty(), Equals, true)
+
+	// signature doesn't match
+	s.repo.packageList = nil
+	expectIndexes(false)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS.gpg", "sig")
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, &failingVerifier{}, s.collectionFactory, false, "")
+	c.Assert(err, ErrorMatches, "unable to verify signature of main/installer-i386/current/images/SHA256SUMS: bad signature")
+	c.Assert(s.downloader.Empty(), Equals, true)
+
+	// signature is verified even if checksums are ignored
+	s.repo.packageList = nil
+	expectIndexes(true)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS.gpg", "sig")
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/MANIFEST", exampleInstallerManifestFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, &NullVerifier{}, s.collectionFactory, true, "")
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.packageList.Len(), Equals, 2)
+}
+
+func (s *RemoteRepoSuite) TestDownloadWithInstallerFlavours(c *C) {
+	s.repo.Architectures = []string{"i386"}
+	s.repo.DownloadInstaller = true
+	s.repo.InstallerFlavours = []string{"netboot"}
+
+	err := s.repo.Fetch(s.downloader, nil)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/SHA256SUMS", exampleInstallerFlavoursHashSumFile)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/MANIFEST", exampleInstallerManifestFile)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/netboot/mini.iso", "iso")
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/installer-i386/current/images/netboot/debian-installer/i386/linux", "linux")
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
+	c.Assert(err, IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+
+	c.Check(s.repo.packageList.Len(), Equals, 2)
+
+	var files []string
+	_ = s.repo.packageList.ForEach(func(p *Package) error {
+		if p.IsInstaller {
+			for _, f := range p.Files() {
+				files = append(files, f.Filename)
+			}
+		}
+		return nil
+	})
+	sort.Strings(files)
+	c.Check(files, DeepEquals, []string{"./MANIFEST", "./netboot/debian-installer/i386/linux", "./netboot/mini.iso"})
+}
+
 func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.repo.Architectures = []string{"i386"}
 	s.repo.DownloadSources = true
@@ -396,7 +593,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources", exampleSourcesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -440,7 +637,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources", exampleSourcesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -465,7 +662,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources.gz", &http.Error{Code: 404})
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/source/Sources", exampleSourcesFile)
 
-	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false)
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, false, "")
 	c.Assert(err, IsNil)
 	c.Assert(s.downloader.Empty(), Equals, true)
 
@@ -489,7 +686,7 @@ func (s *RemoteRepoSuite) TestDownloadFlat(c *C) {
 	err := s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -517,7 +714,7 @@ func (s *RemoteRepoSuite) TestDownloadFlat(c *C) {
 	err = s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -539,7 +736,7 @@ func (s *RemoteRepoSuite) TestDownloadFlat(c *C) {
 	err = s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -553,6 +750,110 @@ func (s *RemoteRepoSuite) TestDownloadFlat(c *C) {
 	c.Assert(s.flat.packageRefs, NotNil)
 }
 
+func (s *RemoteRepoSuite) localArchive(c *C, files map[string]string) (string, aptly.Downloader) {
+	root := c.MkDir()
+	for path, contents := range files {
+		c.Assert(os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(root, path), []byte(contents), 0644), IsNil)
+	}
+
+	downloader, err := http.NewDownloaderWithOptions(0, 1, s.progress, http.DownloaderOptions{LocalRoots: []string{root}})
+	c.Assert(err, IsNil)
+
+	return root, downloader
+}
+
+func (s *RemoteRepoSuite) TestDownloadLocal(c *C) {
+	root, downloader := s.localArchive(c, map[string]string{
+		"debian/dists/squeeze/Release":                   exampleReleaseFile,
+		"debian/dists/squeeze/main/binary-i386/Packages": examplePackagesFile,
+	})
+
+	repo, err := NewRemoteRepo("local", "file://"+filepath.ToSlash(root)+"/debian", "squeeze", []string{"main"}, []string{"i386"}, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(repo.CheckLocalRoots([]string{root}), IsNil)
+
+	err = repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+	c.Check(repo.Components, DeepEquals, []string{"main"})
+
+	err = repo.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, false, "")
+	c.Assert(err, IsNil)
+
+	queue, size, err := repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(3))
+	c.Assert(queue, HasLen, 1)
+	c.Check(queue[0].URLs, DeepEquals, []string{"file://" + filepath.ToSlash(root) + "/debian/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb"})
+
+	repo.FinalizeDownload(s.collectionFactory, nil)
+	c.Assert(repo.packageRefs, NotNil)
+
+	// update of the mirror fetches indexes from the same local archive once again
+	err = repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+
+	err = repo.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, false, "")
+	c.Assert(err, IsNil)
+
+	queue, size, err = repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, true)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(0))
+	c.Check(queue, HasLen, 0)
+}
+
+func (s *RemoteRepoSuite) TestDownloadLocalFlat(c *C) {
+	root, downloader := s.localArchive(c, map[string]string{
+		"virool/Release":  exampleReleaseFile,
+		"virool/Packages": examplePackagesFile,
+	})
+
+	repo, err := NewRemoteRepo("local", "file://"+filepath.ToSlash(root)+"/virool/", "./", []string{}, []string{}, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(repo.IsFlat(), Equals, true)
+	c.Assert(repo.CheckLocalRoots([]string{root}), IsNil)
+
+	err = repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+
+	err = repo.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
+	c.Assert(err, IsNil)
+
+	queue, size, err := repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(3))
+	c.Assert(queue, HasLen, 1)
+	c.Check(queue[0].URLs, DeepEquals, []string{"file://" + filepath.ToSlash(root) + "/virool/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb"})
+
+	repo.FinalizeDownload(s.collectionFactory, nil)
+	c.Assert(repo.packageRefs, NotNil)
+
+	// update of the mirror
+	err = repo.Fetch(downloader, nil)
+	c.Assert(err, IsNil)
+
+	err = repo.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
+	c.Assert(err, IsNil)
+
+	queue, _, err = repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, true)
+	c.Assert(err, IsNil)
+	c.Check(queue, HasLen, 0)
+}
+
+func (s *RemoteRepoSuite) TestDownloadLocalOutsideRoots(c *C) {
+	_, downloader := s.localArchive(c, nil)
+	outside := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(outside, "dists", "squeeze"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(outside, "dists", "squeeze", "Release"), []byte(exampleReleaseFile), 0644), IsNil)
+
+	repo, err := NewRemoteRepo("local", "file://"+filepath.ToSlash(outside), "squeeze", []string{"main"}, []string{"i386"}, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.CheckLocalRoots(nil), ErrorMatches, "local archive path .* is not allowed.*")
+
+	err = repo.Fetch(downloader, nil)
+	c.Check(err, ErrorMatches, ".*local archive path .* is not allowed.*")
+}
+
 func (s *RemoteRepoSuite) TestDownloadWithSourcesFlat(c *C) {
 	s.flat.DownloadSources = true
 
@@ -570,7 +871,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSourcesFlat(c *C) {
 	err := s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -616,7 +917,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSourcesFlat(c *C) {
 	err = s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -642,7 +943,7 @@ func (s *RemoteRepoSuite) TestDownloadWithSourcesFlat(c *C) {
 	err = s.flat.Fetch(downloader, nil)
 	c.Assert(err, IsNil)
 
-	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true)
+	err = s.flat.DownloadPackageIndexes(s.progress, downloader, nil, s.collectionFactory, true, "")
 	c.Assert(err, IsNil)
 	c.Assert(downloader.Empty(), Equals, true)
 
@@ -729,6 +1030,23 @@ func (s *RemoteRepoCollectionSuite) TestUpdateLoadComplete(c *C) {
 	c.Assert(r.NumPackages(), Equals, 3)
 }
 
+func (s *RemoteRepoCollectionSuite) TestUpdateCopy(c *C) {
+	repo, _ := NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false)
+	c.Assert(s.collection.Add(repo), IsNil)
+
+	edited := &RemoteRepo{}
+	c.Assert(edited.Decode(repo.Encode()), IsNil)
+	edited.Name = "yandex-renamed"
+	c.Assert(s.collection.Update(edited), IsNil)
+
+	r, err := s.collection.ByName("yandex-renamed")
+	c.Assert(err, IsNil)
+	c.Check(r, Equals, edited)
+
+	_, err = s.collection.ByName("yandex")
+	c.Check(err, ErrorMatches, "mirror with name yandex not found")
+}
+
 func (s *RemoteRepoCollectionSuite) TestForEachAndLen(c *C) {
 	repo, _ := NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false)
 	s.collection.Add(repo)
@@ -931,6 +1249,12 @@ SHA256: 3608bca1e44ea6c4d268eb6db02260269892c0b42b86bbf1e77a6fa16c3c9282
 const exampleInstallerHashSumFile = `82f69d557f0004d2923fb03e4fb47d18187e37768dbfd0c99756f8a6c68a6d3a  ./MANIFEST
 `
 
+const exampleInstallerFlavoursHashSumFile = `82f69d557f0004d2923fb03e4fb47d18187e37768dbfd0c99756f8a6c68a6d3a  ./MANIFEST
+1f4a3e2e2a7d4f8c6a8e0e8a2d2a6b0f9c3f1a7e5b6d8c9e0f1a2b3c4d5e6f7a  ./cdrom/vmlinuz
+2f4a3e2e2a7d4f8c6a8e0e8a2d2a6b0f9c3f1a7e5b6d8c9e0f1a2b3c4d5e6f7a  ./netboot/mini.iso
+3f4a3e2e2a7d4f8c6a8e0e8a2d2a6b0f9c3f1a7e5b6d8c9e0f1a2b3c4d5e6f7a  ./netboot/debian-installer/i386/linux
+`
+
 const exampleInstallerManifestFile = `cdrom/debian-cd_info.tar.gz     -- isolinux config files for CD
 cdrom/gtk/debian-cd_info.tar.gz -- isolinux help screens for CD (graphical)
 cdrom/gtk/initrd.gz             -- initrd for use with isolinux to build a CD (graphical)
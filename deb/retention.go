@@ -0,0 +1,133 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RetentionPolicy describes which snapshots created from a mirror should be kept
+// when pruning, rules are combined: snapshot is kept if any of the rules keeps it
+//
+// Days and weeks are calculated in UTC based on Snapshot.CreatedAt
+type RetentionPolicy struct {
+	// Keep N most recent snapshots
+	KeepLast int `json:",omitempty"`
+	// Keep most recent snapshot for each of N most recent days which have snapshots
+	KeepDaily int `json:",omitempty"`
+	// Keep most recent snapshot for each of N most recent weeks which have snapshots
+	KeepWeekly int `json:",omitempty"`
+}
+
+// IsEmpty checks whether policy has any rules
+//
+// Empty policy never prunes anything
+func (policy *RetentionPolicy) IsEmpty() bool {
+	return policy == nil || (policy.KeepLast <= 0 && policy.KeepDaily <= 0 && policy.KeepWeekly <= 0)
+}
+
+// Validate checks policy for consistency
+func (policy *RetentionPolicy) Validate() error {
+	if policy.KeepLast < 0 || policy.KeepDaily < 0 || policy.KeepWeekly < 0 {
+		return fmt.Errorf("retention policy values should be non-negative")
+	}
+
+	return nil
+}
+
+// String returns human-readable description of the policy
+func (policy *RetentionPolicy) String() string {
+	if policy.IsEmpty() {
+		return "keep all"
+	}
+
+	var rules []string
+	if policy.KeepLast > 0 {
+		rules = append(rules, fmt.Sprintf("keep last %d", policy.KeepLast))
+	}
+	if policy.KeepDaily > 0 {
+		rules = append(rules, fmt.Sprintf("keep daily %d", policy.KeepDaily))
+	}
+	if policy.KeepWeekly > 0 {
+		rules = append(rules, fmt.Sprintf("keep weekly %d", policy.KeepWeekly))
+	}
+
+	return strings.Join(rules, ", ")
+}
+
+// Select splits snapshots into the ones to keep and the ones to prune,
+// both lists are sorted from newest to oldest
+func (policy *RetentionPolicy) Select(snapshots []*Snapshot) (keep, prune []*Snapshot) {
+	sorted := make([]*Snapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	if policy.IsEmpty() {
+		return sorted, nil
+	}
+
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+
+	for i, snapshot := range sorted {
+		kept := i < policy.KeepLast
+
+		createdAt := snapshot.CreatedAt.UTC()
+
+		day := createdAt.Format("2006-01-02")
+		if !days[day] && len(days) < policy.KeepDaily {
+			days[day] = true
+			kept = true
+		}
+
+		year, w := createdAt.ISOWeek()
+		week := fmt.Sprintf("%d-W%02d", year, w)
+		if !weeks[week] && len(weeks) < policy.KeepWeekly {
+			weeks[week] = true
+			kept = true
+		}
+
+		if kept {
+			keep = append(keep, snapshot)
+		} else {
+			prune = append(prune, snapshot)
+		}
+	}
+
+	return
+}
+
+// ProtectedSnapshot is a snapshot which should be pruned according to retention policy,
+// but it is still in use
+type ProtectedSnapshot struct {
+	Snapshot *Snapshot
+	Reason   string
+}
+
+// SnapshotsToPrune applies retention policy to snapshots created from the mirror
+//
+// Snapshots which are published (including kept generations of atomic publishes,
+// required for rollback) or were used as source for other snapshots are never pruned,
+// they're returned as protected along with the reason
+func (factory *CollectionFactory) SnapshotsToPrune(repo *RemoteRepo, policy *RetentionPolicy) (prune []*Snapshot, protected []ProtectedSnapshot) {
+	snapshotCollection := factory.SnapshotCollection()
+	publishedCollection := factory.PublishedRepoCollection()
+
+	_, candidates := policy.Select(snapshotCollection.ByRemoteRepoSource(repo))
+
+	for _, snapshot := range candidates {
+		if len(publishedCollection.BySnapshot(snapshot)) > 0 {
+			protected = append(protected, ProtectedSnapshot{snapshot, "snapshot is published"})
+		} else if len(publishedCollection.ByGenerationSource(snapshot.UUID)) > 0 {
+			protected = append(protected, ProtectedSnapshot{snapshot, "snapshot is referenced by a kept publish generation"})
+		} else if len(snapshotCollection.BySnapshotSource(snapshot)) > 0 {
+			protected = append(protected, ProtectedSnapshot{snapshot, "snapshot was used as source for other snapshots"})
+		} else {
+			prune = append(prune, snapshot)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,122 @@
+package deb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type RetentionSuite struct {
+	db        database.Storage
+	factory   *CollectionFactory
+	repo      *RemoteRepo
+	snapshots []*Snapshot
+}
+
+var _ = Suite(&RetentionSuite{})
+
+func (s *RetentionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false)
+	s.repo.packageRefs = NewPackageRefList()
+	c.Assert(s.factory.RemoteRepoCollection().Add(s.repo), IsNil)
+
+	// two snapshots a day for 20 days: Mon, 2 Dec 2019 ... Sat, 21 Dec 2019
+	start := time.Date(2019, 12, 2, 0, 0, 0, 0, time.UTC)
+	s.snapshots = nil
+	for day := 0; day < 20; day++ {
+		for _, hour := range []int{6, 18} {
+			snapshot, _ := NewSnapshotFromRepository(fmt.Sprintf("snap-%02d-%02d", day, hour), s.repo)
+			snapshot.CreatedAt = start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)
+			c.Assert(s.factory.SnapshotCollection().Add(snapshot), IsNil)
+			s.snapshots = append(s.snapshots, snapshot)
+		}
+	}
+}
+
+func (s *RetentionSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func snapshotNames(snapshots []*Snapshot) (result []string) {
+	for _, snapshot := range snapshots {
+		result = append(result, snapshot.Name)
+	}
+	return
+}
+
+func (s *RetentionSuite) TestIsEmpty(c *C) {
+	c.Check((*RetentionPolicy)(nil).IsEmpty(), Equals, true)
+	c.Check((&RetentionPolicy{}).IsEmpty(), Equals, true)
+	c.Check((&RetentionPolicy{KeepWeekly: 1}).IsEmpty(), Equals, false)
+
+	c.Check((*RetentionPolicy)(nil).String(), Equals, "keep all")
+	c.Check((&RetentionPolicy{KeepLast: 3, KeepWeekly: 2}).String(), Equals, "keep last 3, keep weekly 2")
+
+	c.Check((&RetentionPolicy{KeepDaily: -1}).Validate(), ErrorMatches, "retention policy values should be non-negative")
+	c.Check((&RetentionPolicy{KeepDaily: 1}).Validate(), IsNil)
+}
+
+func (s *RetentionSuite) TestSelect(c *C) {
+	keep, prune := (&RetentionPolicy{}).Select(s.snapshots)
+	c.Check(keep, HasLen, 40)
+	c.Check(prune, HasLen, 0)
+	c.Check(keep[0].Name, Equals, "snap-19-18")
+
+	keep, prune = (&RetentionPolicy{KeepLast: 3}).Select(s.snapshots)
+	c.Check(snapshotNames(keep), DeepEquals, []string{"snap-19-18", "snap-19-06", "snap-18-18"})
+	c.Check(prune, HasLen, 37)
+	c.Check(prune[0].Name, Equals, "snap-18-06")
+
+	keep, _ = (&RetentionPolicy{KeepDaily: 3}).Select(s.snapshots)
+	c.Check(snapshotNames(keep), DeepEquals, []string{"snap-19-18", "snap-18-18", "snap-17-18"})
+
+	// weeks: 16-19 (Dec 16-21), 9-15, 2-8
+	keep, _ = (&RetentionPolicy{KeepWeekly: 5}).Select(s.snapshots)
+	c.Check(snapshotNames(keep), DeepEquals, []string{"snap-19-18", "snap-13-18", "snap-06-18"})
+
+	keep, _ = (&RetentionPolicy{KeepLast: 2, KeepDaily: 2, KeepWeekly: 2}).Select(s.snapshots)
+	c.Check(snapshotNames(keep), DeepEquals, []string{"snap-19-18", "snap-19-06", "snap-18-18", "snap-13-18"})
+}
+
+func (s *RetentionSuite) TestSnapshotsToPrune(c *C) {
+	published, _ := NewPublishedRepo("", "ppa", "squeeze", nil, []string{"main"}, []interface{}{s.snapshots[0]}, s.factory)
+	published.Generations = []PublishedGeneration{
+		{ID: 1, Sources: map[string]string{"main": s.snapshots[6].UUID}},
+		{ID: 2, Sources: map[string]string{"main": s.snapshots[0].UUID}},
+	}
+	c.Assert(s.factory.PublishedRepoCollection().Add(published), IsNil)
+
+	merged := NewSnapshotFromRefList("merged", []*Snapshot{s.snapshots[2], s.snapshots[4]}, NewPackageRefList(), "Merged")
+	c.Assert(s.factory.SnapshotCollection().Add(merged), IsNil)
+
+	other, _ := NewRemoteRepo("other", "http://mirror.yandex.ru/debian/", "wheezy", []string{"main"}, []string{}, false, false, false)
+	other.packageRefs = NewPackageRefList()
+	c.Assert(s.factory.RemoteRepoCollection().Add(other), IsNil)
+	otherSnapshot, _ := NewSnapshotFromRepository("other-snap", other)
+	c.Assert(s.factory.SnapshotCollection().Add(otherSnapshot), IsNil)
+
+	prune, protected := s.factory.SnapshotsToPrune(s.repo, &RetentionPolicy{KeepLast: 30})
+	c.Check(prune, HasLen, 6)
+	c.Check(utils.StrSliceHasItem(snapshotNames(prune), "other-snap"), Equals, false)
+	c.Check(utils.StrSliceHasItem(snapshotNames(prune), "merged"), Equals, false)
+
+	c.Assert(protected, HasLen, 4)
+	c.Check(protected[0].Snapshot.Name, Equals, "snap-03-06")
+	c.Check(protected[0].Reason, Equals, "snapshot is referenced by a kept publish generation")
+	c.Check(protected[1].Snapshot.Name, Equals, "snap-02-06")
+	c.Check(protected[1].Reason, Equals, "snapshot was used as source for other snapshots")
+	c.Check(protected[3].Snapshot.Name, Equals, "snap-00-06")
+	c.Check(protected[3].Reason, Equals, "snapshot is published")
+
+	prune, protected = s.factory.SnapshotsToPrune(s.repo, nil)
+	c.Check(prune, HasLen, 0)
+	c.Check(protected, HasLen, 0)
+}
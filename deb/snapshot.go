@@ -41,6 +41,9 @@ type Snapshot struct {
 	NotAutomatic         string
 	ButAutomaticUpgrades string
 
+	// DEP-11 files of the mirror snapshot was created from
+	AppStreamFiles []AppStreamFile `json:",omitempty"`
+
 	packageRefs *PackageRefList
 }
 
@@ -60,6 +63,7 @@ func NewSnapshotFromRepository(name string, repo *RemoteRepo) (*Snapshot, error)
 		Origin:               repo.Meta["Origin"],
 		NotAutomatic:         repo.Meta["NotAutomatic"],
 		ButAutomaticUpgrades: repo.Meta["ButAutomaticUpgrades"],
+		AppStreamFiles:       repo.AppStreamFiles,
 		packageRefs:          repo.packageRefs,
 	}, nil
 }
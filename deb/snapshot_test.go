@@ -30,6 +30,11 @@ func (s *SnapshotSuite) TestNewSnapshotFromRepository(c *C) {
 	c.Check(snapshot.RefList().Len(), Equals, 3)
 	c.Check(snapshot.SourceKind, Equals, SourceRemoteRepo)
 	c.Check(snapshot.SourceIDs, DeepEquals, []string{s.repo.UUID})
+	c.Check(snapshot.AppStreamFiles, IsNil)
+
+	s.repo.AppStreamFiles = []AppStreamFile{{Component: "main", Name: "Components-i386.yml", PoolPath: "ab/cd/Components-i386.yml"}}
+	snapshot, _ = NewSnapshotFromRepository("snap1", s.repo)
+	c.Check(snapshot.AppStreamFiles, DeepEquals, s.repo.AppStreamFiles)
 
 	s.repo.packageRefs = nil
 	_, err := NewSnapshotFromRepository("snap2", s.repo)
@@ -0,0 +1,61 @@
+package deb
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+)
+
+// descriptionMD5 calculates Description-md5 of package description the same way apt does:
+// over short description and long description lines, including trailing newline
+func descriptionMD5(description string) string {
+	description = strings.TrimPrefix(description, " ")
+	if !strings.HasSuffix(description, "\n") {
+		description += "\n"
+	}
+
+	return fmt.Sprintf("%x", md5.Sum([]byte(description)))
+}
+
+// splitTranslation moves long description out of binary package stanza
+//
+// Package stanza is modified in place to contain only short description and Description-md5,
+// returned stanza is an entry for Translation-en index. If package has no long description,
+// nil is returned and stanza is not modified
+func splitTranslation(stanza Stanza) Stanza {
+	description, ok := stanza["Description"]
+	if !ok {
+		return nil
+	}
+
+	lines := strings.SplitAfterN(description, "\n", 2)
+	if len(lines) < 2 || lines[1] == "" {
+		return nil
+	}
+
+	md5sum := descriptionMD5(description)
+
+	stanza["Description"] = lines[0]
+	stanza["Description-md5"] = md5sum
+
+	return Stanza{
+		"Package":         stanza["Package"],
+		"Description-md5": md5sum,
+		"Description-en":  description,
+	}
+}
+
+// writeTranslation appends entry to Translation-* index
+func writeTranslation(file *indexFile, translation Stanza) error {
+	bufWriter, err := file.BufWriter()
+	if err != nil {
+		return err
+	}
+
+	err = translation.WriteTo(bufWriter, false, false, false)
+	if err != nil {
+		return err
+	}
+
+	return bufWriter.WriteByte('\n')
+}
@@ -0,0 +1,49 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TranslationSuite struct{}
+
+var _ = Suite(&TranslationSuite{})
+
+const bashDescription = " GNU Bourne Again SHell\n" +
+	" Bash is an sh-compatible command language interpreter that executes\n" +
+	" commands read from the standard input or from a file.  Bash also\n" +
+	" incorporates useful features from the Korn and C shells (ksh and csh).\n" +
+	" .\n" +
+	" Bash is ultimately intended to be a conformant implementation of the\n" +
+	" IEEE POSIX Shell and Tools specification (IEEE Working Group 1003.2).\n" +
+	" .\n" +
+	" The Programmable Completion Code, by Ian Macdonald, is now found in\n" +
+	" the bash-completion package.\n"
+
+func (s *TranslationSuite) TestDescriptionMD5(c *C) {
+	c.Check(descriptionMD5(bashDescription), Equals, "3522aa7b4374048d6450e348a5bb45d9")
+}
+
+func (s *TranslationSuite) TestSplitTranslation(c *C) {
+	stanza := Stanza{"Package": "bash", "Version": "5.2.15-2+b9", "Description": bashDescription}
+
+	translation := splitTranslation(stanza)
+	c.Check(translation, DeepEquals, Stanza{
+		"Package":         "bash",
+		"Description-md5": "3522aa7b4374048d6450e348a5bb45d9",
+		"Description-en":  bashDescription,
+	})
+	c.Check(stanza, DeepEquals, Stanza{
+		"Package":         "bash",
+		"Version":         "5.2.15-2+b9",
+		"Description":     " GNU Bourne Again SHell\n",
+		"Description-md5": "3522aa7b4374048d6450e348a5bb45d9",
+	})
+}
+
+func (s *TranslationSuite) TestSplitTranslationShort(c *C) {
+	stanza := Stanza{"Package": "bash", "Description": " GNU Bourne Again SHell\n", "Description-md5": "3522aa7b4374048d6450e348a5bb45d9"}
+	c.Check(splitTranslation(stanza), IsNil)
+	c.Check(stanza["Description"], Equals, " GNU Bourne Again SHell\n")
+
+	c.Check(splitTranslation(Stanza{"Package": "bash"}), IsNil)
+}
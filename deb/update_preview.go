@@ -0,0 +1,107 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// UpgradedPackage is a package which would be replaced with another version
+type UpgradedPackage struct {
+	From string
+	To   string
+}
+
+// UpdatePreview describes changes mirror update would bring
+type UpdatePreview struct {
+	// Packages which would be added to the mirror
+	Added []string
+	// Packages which would be removed from the mirror
+	Removed []string
+	// Packages which would be replaced with other version
+	Upgraded []UpgradedPackage
+	// Number of files to download and their total size
+	DownloadCount int
+	DownloadSize  int64
+}
+
+// IsEmpty checks whether update would change mirror contents
+func (preview *UpdatePreview) IsEmpty() bool {
+	return len(preview.Added) == 0 && len(preview.Removed) == 0 && len(preview.Upgraded) == 0
+}
+
+// downloadedPackages looks up packages in the list of downloaded packages first,
+// and in the database for the packages which are not downloaded
+type downloadedPackages struct {
+	packages map[string]*Package
+	fallback PackageKeyLookup
+}
+
+func (l *downloadedPackages) ByKey(key []byte) (*Package, error) {
+	if p, ok := l.packages[string(key)]; ok {
+		return p, nil
+	}
+
+	return l.fallback.ByKey(key)
+}
+
+// PreviewUpdate compares current mirror contents with the packages downloaded by
+// DownloadPackageIndexes (with filter applied) and download queue
+//
+// Nothing is saved to the database, packages which are not in the database
+// yet are taken from the list of downloaded packages
+func (repo *RemoteRepo) PreviewUpdate(packageCollection *PackageCollection, queue []PackageDownloadTask, downloadSize int64) (*UpdatePreview, error) {
+	lookup := &downloadedPackages{
+		packages: make(map[string]*Package, repo.packageList.Len()),
+		fallback: packageCollection,
+	}
+	_ = repo.packageList.ForEach(func(p *Package) error {
+		lookup.packages[string(p.Key(""))] = p
+		return nil
+	})
+
+	current := repo.RefList()
+	if current == nil {
+		current = NewPackageRefList()
+	}
+
+	diff, err := current.Diff(NewPackageRefListFromPackageList(repo.packageList), lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &UpdatePreview{
+		Added:         []string{},
+		Removed:       []string{},
+		Upgraded:      []UpgradedPackage{},
+		DownloadCount: len(queue),
+		DownloadSize:  downloadSize,
+	}
+
+	for _, pdiff := range diff {
+		if pdiff.Left == nil {
+			preview.Added = append(preview.Added, pdiff.Right.String())
+		} else if pdiff.Right == nil {
+			preview.Removed = append(preview.Removed, pdiff.Left.String())
+		} else {
+			preview.Upgraded = append(preview.Upgraded, UpgradedPackage{From: pdiff.Left.String(), To: pdiff.Right.String()})
+		}
+	}
+
+	return preview, nil
+}
+
+// ReadOnlyChecksumStorage wraps ChecksumStorage discarding all the updates,
+// so that pool could be checked without modifying the database
+type ReadOnlyChecksumStorage struct {
+	aptly.ChecksumStorage
+}
+
+// Update does nothing
+func (ReadOnlyChecksumStorage) Update(path string, c *utils.ChecksumInfo) error {
+	return nil
+}
+
+// Check interface
+var (
+	_ aptly.ChecksumStorage = ReadOnlyChecksumStorage{}
+)
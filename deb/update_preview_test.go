@@ -0,0 +1,87 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type UpdatePreviewSuite struct {
+	db   database.Storage
+	coll *PackageCollection
+	repo *RemoteRepo
+}
+
+var _ = Suite(&UpdatePreviewSuite{})
+
+func (s *UpdatePreviewSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.coll = NewPackageCollection(s.db)
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false)
+}
+
+func (s *UpdatePreviewSuite) TearDownTest(c *C) {
+	s.db.Close()
+}
+
+func (s *UpdatePreviewSuite) TestPreviewUpdate(c *C) {
+	current := []*Package{
+		{Name: "app", Version: "1.0", Architecture: "i386"},
+		{Name: "lib", Version: "1.0", Architecture: "i386"},
+		{Name: "old", Version: "0.9", Architecture: "all"},
+	}
+
+	list := NewPackageList()
+	for _, p := range current {
+		c.Assert(s.coll.Update(p), IsNil)
+		list.Add(p)
+	}
+
+	// mirror was never updated
+	s.repo.packageList = list
+	preview, err := s.repo.PreviewUpdate(s.coll, make([]PackageDownloadTask, 3), 1024)
+	c.Assert(err, IsNil)
+	c.Check(preview.Added, DeepEquals, []string{"old_0.9_all", "app_1.0_i386", "lib_1.0_i386"})
+	c.Check(preview.Removed, HasLen, 0)
+	c.Check(preview.DownloadCount, Equals, 3)
+	c.Check(preview.DownloadSize, Equals, int64(1024))
+
+	s.repo.packageRefs = NewPackageRefListFromPackageList(list)
+
+	// packages which are not in the database yet are taken from downloaded list
+	s.repo.packageList = NewPackageList()
+	s.repo.packageList.Add(current[0])
+	s.repo.packageList.Add(&Package{Name: "lib", Version: "1.1", Architecture: "i386"})
+	s.repo.packageList.Add(&Package{Name: "new", Version: "2.0", Architecture: "i386"})
+
+	preview, err = s.repo.PreviewUpdate(s.coll, nil, 0)
+	c.Assert(err, IsNil)
+	c.Check(preview.IsEmpty(), Equals, false)
+	c.Check(preview.Added, DeepEquals, []string{"new_2.0_i386"})
+	c.Check(preview.Removed, DeepEquals, []string{"old_0.9_all"})
+	c.Check(preview.Upgraded, DeepEquals, []UpgradedPackage{{From: "lib_1.0_i386", To: "lib_1.1_i386"}})
+
+	// nothing changed
+	s.repo.packageList = list
+	preview, err = s.repo.PreviewUpdate(s.coll, nil, 0)
+	c.Assert(err, IsNil)
+	c.Check(preview.IsEmpty(), Equals, true)
+}
+
+func (s *UpdatePreviewSuite) TestReadOnlyChecksumStorage(c *C) {
+	checksums := NewChecksumCollection(s.db)
+	c.Assert(checksums.Update("pool/a.deb", &utils.ChecksumInfo{Size: 1}), IsNil)
+
+	storage := ReadOnlyChecksumStorage{ChecksumStorage: checksums}
+	c.Check(storage.Update("pool/b.deb", &utils.ChecksumInfo{Size: 2}), IsNil)
+
+	info, err := storage.Get("pool/a.deb")
+	c.Check(err, IsNil)
+	c.Check(info.Size, Equals, int64(1))
+
+	info, err = checksums.Get("pool/b.deb")
+	c.Check(err, IsNil)
+	c.Check(info, IsNil)
+}
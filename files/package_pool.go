@@ -344,7 +344,8 @@ func (pool *PackagePool) Import(srcPath, basename string, checksums *utils.Check
 	}
 
 	if err != nil {
-		// different filesystems or failed hardlink, fallback to copy
+		// different filesystems or failed hardlink, try to clone file contents,
+		// fallback to copy
 		var target *os.File
 		target, err = os.Create(fullPoolPath)
 		if err != nil {
@@ -352,7 +353,9 @@ func (pool *PackagePool) Import(srcPath, basename string, checksums *utils.Check
 		}
 		defer target.Close()
 
-		_, err = io.Copy(target, source)
+		if reflink(target, source) != nil {
+			_, err = io.Copy(target, source)
+		}
 
 		if err == nil {
 			err = target.Close()
@@ -23,6 +23,7 @@ type PublishedStorage struct {
 var (
 	_ aptly.PublishedStorage           = (*PublishedStorage)(nil)
 	_ aptly.FileSystemPublishedStorage = (*PublishedStorage)(nil)
+	_ aptly.AtomicPublishedStorage     = (*PublishedStorage)(nil)
 )
 
 // Constants defining the type of creating links
@@ -255,6 +256,50 @@ func (storage *PublishedStorage) RenameFile(oldName, newName string) error {
 	return os.Rename(filepath.Join(storage.rootPath, oldName), filepath.Join(storage.rootPath, newName))
 }
 
+// SwitchDir atomically replaces path with a relative symbolic link to target
+//
+// If path is a directory (e.g. published before staging has been enabled), it is
+// moved away first, so only the very first switch is not atomic
+func (storage *PublishedStorage) SwitchDir(path, target string) error {
+	fullPath := filepath.Join(storage.rootPath, path)
+	linkTarget, err := filepath.Rel(filepath.Dir(fullPath), filepath.Join(storage.rootPath, target))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fullPath), 0777)
+	if err != nil {
+		return err
+	}
+
+	tempLink := fullPath + ".aptly-switch"
+	err = os.Remove(tempLink)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Symlink(linkTarget, tempLink)
+	if err != nil {
+		return err
+	}
+
+	if info, e := os.Lstat(fullPath); e == nil && info.IsDir() {
+		oldPath := fullPath + ".aptly-old"
+		err = os.RemoveAll(oldPath)
+		if err != nil {
+			return err
+		}
+
+		err = os.Rename(fullPath, oldPath)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(oldPath)
+	}
+
+	return os.Rename(tempLink, fullPath)
+}
+
 // SymLink creates a symbolic link, which can be read with ReadLink
 func (storage *PublishedStorage) SymLink(src string, dst string) error {
 	return os.Symlink(filepath.Join(storage.rootPath, src), filepath.Join(storage.rootPath, dst))
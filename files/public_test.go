@@ -149,6 +149,37 @@ func (s *PublishedStorageSuite) TestHardLink(c *C) {
 	c.Check(exists, Equals, true)
 }
 
+func (s *PublishedStorageSuite) TestSwitchDir(c *C) {
+	// directory published before staging is replaced
+	c.Assert(s.storage.MkDir("ppa/dists/squeeze/"), IsNil)
+	c.Assert(s.storage.PutFile("ppa/dists/squeeze/Release", "/dev/null"), IsNil)
+
+	for _, gen := range []string{"1", "2"} {
+		c.Assert(s.storage.MkDir("ppa/dists/.generations/squeeze/"+gen), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(s.storage.rootPath, "ppa/dists/.generations/squeeze", gen, "Release"), []byte(gen), 0644), IsNil)
+	}
+
+	c.Assert(s.storage.SwitchDir("ppa/dists/squeeze", "ppa/dists/.generations/squeeze/1"), IsNil)
+
+	linkTarget, err := os.Readlink(filepath.Join(s.storage.rootPath, "ppa/dists/squeeze"))
+	c.Assert(err, IsNil)
+	c.Check(linkTarget, Equals, ".generations/squeeze/1")
+
+	content, err := ioutil.ReadFile(filepath.Join(s.storage.rootPath, "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "1")
+
+	c.Assert(s.storage.SwitchDir("ppa/dists/squeeze", "ppa/dists/.generations/squeeze/2"), IsNil)
+
+	content, err = ioutil.ReadFile(filepath.Join(s.storage.rootPath, "ppa/dists/squeeze/Release"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "2")
+
+	list, err := ioutil.ReadDir(filepath.Join(s.storage.rootPath, "ppa/dists"))
+	c.Assert(err, IsNil)
+	c.Check(list, HasLen, 2)
+}
+
 func (s *PublishedStorageSuite) TestRemoveDirs(c *C) {
 	err := s.storage.MkDir("ppa/dists/squeeze/")
 	c.Assert(err, IsNil)
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package files
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink clones contents of src into dst sharing data blocks (copy-on-write),
+// it's supported on btrfs, xfs and some other filesystems
+func reflink(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is not supported on this platform
+func reflink(dst, src *os.File) error {
+	return errors.New("reflinks are not supported")
+}
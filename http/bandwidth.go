@@ -0,0 +1,122 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxk/go-flowrate/flowrate"
+)
+
+// BandwidthWindow limits download speed during part of the day (local time)
+type BandwidthWindow struct {
+	// Start and End of the window as offsets from midnight,
+	// if End is before Start, window wraps around midnight
+	Start, End time.Duration
+	// Limit is download speed limit in bytes/sec, 0 means no limit
+	Limit int64
+}
+
+// ParseBandwidthWindow parses window in format HH:MM-HH:MM=<kbytes/sec>
+func ParseBandwidthWindow(s string) (BandwidthWindow, error) {
+	var (
+		window BandwidthWindow
+		err    error
+	)
+
+	parts := strings.Split(s, "=")
+	if len(parts) != 2 {
+		return window, fmt.Errorf("wrong bandwidth window %q, expected HH:MM-HH:MM=<kbytes/sec>", s)
+	}
+
+	times := strings.Split(parts[0], "-")
+	if len(times) != 2 {
+		return window, fmt.Errorf("wrong bandwidth window %q, expected HH:MM-HH:MM=<kbytes/sec>", s)
+	}
+
+	window.Start, err = parseTimeOfDay(times[0])
+	if err != nil {
+		return window, fmt.Errorf("wrong bandwidth window %q: %s", s, err)
+	}
+
+	window.End, err = parseTimeOfDay(times[1])
+	if err != nil {
+		return window, fmt.Errorf("wrong bandwidth window %q: %s", s, err)
+	}
+
+	limit, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || limit < 0 {
+		return window, fmt.Errorf("wrong bandwidth window %q: speed limit should be non-negative number", s)
+	}
+	window.Limit = limit * 1024
+
+	return window, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse time %q", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains checks whether time of day falls into the window
+func (window BandwidthWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if window.Start <= window.End {
+		return offset >= window.Start && offset < window.End
+	}
+
+	return offset >= window.Start || offset < window.End
+}
+
+// scheduledWriter limits write speed switching limits according to bandwidth windows
+type scheduledWriter struct {
+	sync.Mutex
+	*flowrate.Writer
+
+	defaultLimit int64
+	windows      []BandwidthWindow
+	current      int64
+	now          func() time.Time
+}
+
+func newScheduledWriter(w io.Writer, defaultLimit int64, windows []BandwidthWindow) *scheduledWriter {
+	writer := &scheduledWriter{
+		defaultLimit: defaultLimit,
+		windows:      windows,
+		now:          time.Now,
+	}
+	writer.current = writer.limit(writer.now())
+	writer.Writer = flowrate.NewWriter(w, writer.current)
+
+	return writer
+}
+
+// limit returns speed limit at the time t, first matching window wins
+func (w *scheduledWriter) limit(t time.Time) int64 {
+	for _, window := range w.windows {
+		if window.Contains(t) {
+			return window.Limit
+		}
+	}
+
+	return w.defaultLimit
+}
+
+func (w *scheduledWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	if limit := w.limit(w.now()); limit != w.current {
+		w.current = limit
+		w.Writer.SetLimit(limit)
+	}
+	w.Unlock()
+
+	return w.Writer.Write(p)
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"io/ioutil"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type BandwidthSuite struct{}
+
+var _ = Suite(&BandwidthSuite{})
+
+func (s *BandwidthSuite) TestParseBandwidthWindow(c *C) {
+	window, err := ParseBandwidthWindow("09:00-18:30=256")
+	c.Assert(err, IsNil)
+	c.Check(window, Equals, BandwidthWindow{Start: 9 * time.Hour, End: 18*time.Hour + 30*time.Minute, Limit: 256 * 1024})
+
+	window, err = ParseBandwidthWindow("22:00-06:00=0")
+	c.Assert(err, IsNil)
+	c.Check(window, Equals, BandwidthWindow{Start: 22 * time.Hour, End: 6 * time.Hour})
+
+	_, err = ParseBandwidthWindow("09:00-18:00")
+	c.Check(err, ErrorMatches, "wrong bandwidth window \"09:00-18:00\", expected HH:MM-HH:MM=<kbytes/sec>")
+
+	_, err = ParseBandwidthWindow("09:00=10")
+	c.Check(err, ErrorMatches, "wrong bandwidth window \"09:00=10\", expected HH:MM-HH:MM=<kbytes/sec>")
+
+	_, err = ParseBandwidthWindow("09:00-25:00=10")
+	c.Check(err, ErrorMatches, "wrong bandwidth window \"09:00-25:00=10\": unable to parse time \"25:00\"")
+
+	_, err = ParseBandwidthWindow("09:00-18:00=-1")
+	c.Check(err, ErrorMatches, "wrong bandwidth window \"09:00-18:00=-1\": speed limit should be non-negative number")
+}
+
+func (s *BandwidthSuite) TestContains(c *C) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2021, 6, 1, hour, min, 0, 0, time.Local)
+	}
+
+	day := BandwidthWindow{Start: 9 * time.Hour, End: 18 * time.Hour}
+	c.Check(day.Contains(at(8, 59)), Equals, false)
+	c.Check(day.Contains(at(9, 0)), Equals, true)
+	c.Check(day.Contains(at(17, 59)), Equals, true)
+	c.Check(day.Contains(at(18, 0)), Equals, false)
+
+	night := BandwidthWindow{Start: 22 * time.Hour, End: 6 * time.Hour}
+	c.Check(night.Contains(at(21, 0)), Equals, false)
+	c.Check(night.Contains(at(23, 0)), Equals, true)
+	c.Check(night.Contains(at(0, 30)), Equals, true)
+	c.Check(night.Contains(at(6, 0)), Equals, false)
+}
+
+func (s *BandwidthSuite) TestScheduledWriter(c *C) {
+	now := time.Date(2021, 6, 1, 8, 0, 0, 0, time.Local)
+
+	w := newScheduledWriter(ioutil.Discard, 1024, []BandwidthWindow{
+		{Start: 9 * time.Hour, End: 18 * time.Hour, Limit: 100},
+		{Start: 12 * time.Hour, End: 13 * time.Hour, Limit: 0},
+	})
+	w.now = func() time.Time { return now }
+
+	c.Check(w.current, Equals, int64(1024))
+
+	now = now.Add(2 * time.Hour)
+	_, err := w.Write([]byte("a"))
+	c.Assert(err, IsNil)
+	c.Check(w.current, Equals, int64(100))
+
+	// first matching window wins
+	now = now.Add(2 * time.Hour)
+	c.Check(w.limit(now), Equals, int64(100))
+
+	now = now.Add(10 * time.Hour)
+	_, err = w.Write([]byte("a"))
+	c.Assert(err, IsNil)
+	c.Check(w.current, Equals, int64(1024))
+}
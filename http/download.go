@@ -31,12 +31,23 @@ type downloaderImpl struct {
 	progress  aptly.Progress
 	aggWriter io.Writer
 	maxTries  int
+	options   DownloaderOptions
 	client    *http.Client
 }
 
 // NewDownloader creates new instance of Downloader which specified number
 // of threads and download limit in bytes/sec
 func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly.Downloader {
+	downloader, _ := NewDownloaderWithOptions(downLimit, maxTries, progress, DownloaderOptions{})
+	return downloader
+}
+
+// NewDownloaderWithOptions creates new instance of Downloader with optional
+// settings: bandwidth windows, TLS, proxy and credentials
+//
+// If bandwidth windows are given, download limit changes during the day:
+// limit of the window is used while it's active, downLimit otherwise
+func NewDownloaderWithOptions(downLimit int64, maxTries int, progress aptly.Progress, options DownloaderOptions) (aptly.Downloader, error) {
 	transport := http.Transport{}
 	transport.Proxy = http.DefaultTransport.(*http.Transport).Proxy
 	transport.ResponseHeaderTimeout = 30 * time.Second
@@ -45,10 +56,16 @@ func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly
 	transport.DisableCompression = true
 	initTransport(&transport)
 	transport.RegisterProtocol("ftp", &protocol.FTPRoundTripper{})
+	transport.RegisterProtocol("file", http.NewFileTransport(localFileSystem{roots: options.LocalRoots}))
+
+	if err := options.configureTransport(&transport); err != nil {
+		return nil, err
+	}
 
 	downloader := &downloaderImpl{
 		progress: progress,
 		maxTries: maxTries,
+		options:  options,
 		client: &http.Client{
 			Transport: &transport,
 		},
@@ -60,16 +77,22 @@ func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly
 	}
 
 	downloader.client.CheckRedirect = downloader.checkRedirect
-	if downLimit > 0 {
+	if len(options.BandwidthWindows) > 0 {
+		downloader.aggWriter = newScheduledWriter(progressWriter, downLimit, options.BandwidthWindows)
+	} else if downLimit > 0 {
 		downloader.aggWriter = flowrate.NewWriter(progressWriter, downLimit)
 	} else {
 		downloader.aggWriter = progressWriter
 	}
 
-	return downloader
+	return downloader, nil
 }
 
 func (downloader *downloaderImpl) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > 0 && req.URL.Scheme != via[0].URL.Scheme {
+		return errors.Errorf("redirect from %s to %s changes URL scheme", via[0].URL, req.URL)
+	}
+
 	if downloader.progress != nil {
 		downloader.progress.Printf("Following redirect to %s...\n", req.URL)
 	}
@@ -84,6 +107,23 @@ func (downloader *downloaderImpl) GetProgress() aptly.Progress {
 
 // GetLength of given url
 func (downloader *downloaderImpl) GetLength(ctx context.Context, url string) (int64, error) {
+	if path, ok := LocalPath(url); ok {
+		err := CheckLocalPath(path, downloader.options.LocalRoots)
+		if err != nil {
+			return -1, err
+		}
+
+		// file transport doesn't report length for HEAD requests
+		stat, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return -1, &Error{Code: 404, URL: url}
+			}
+			return -1, errors.Wrap(err, url)
+		}
+		return stat.Size(), nil
+	}
+
 	req, err := downloader.newRequest(ctx, "HEAD", url)
 	if err != nil {
 		return -1, err
@@ -151,6 +191,11 @@ func retryableError(err error) bool {
 }
 
 func (downloader *downloaderImpl) newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	err := CheckLocalURL(url, downloader.options.LocalRoots)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, url)
@@ -164,6 +209,8 @@ func (downloader *downloaderImpl) newRequest(ctx context.Context, method, url st
 		req.URL.RawQuery = ""
 	}
 
+	downloader.options.authorize(req)
+
 	return req, nil
 }
 
@@ -175,6 +222,9 @@ func (downloader *downloaderImpl) DownloadWithChecksum(ctx context.Context, url
 		downloader.progress.Printf("Downloading %s...\n", url)
 	}
 	req, err := downloader.newRequest(ctx, "GET", url)
+	if err != nil {
+		return err
+	}
 
 	var temppath string
 	maxTries := downloader.maxTries
@@ -247,18 +297,7 @@ func (downloader *downloaderImpl) download(req *http.Request, url, destination s
 	if expected != nil {
 		actual := checksummer.Sum()
 
-		if actual.Size != expected.Size {
-			err = fmt.Errorf("%s: size check mismatch %d != %d", url, actual.Size, expected.Size)
-		} else if expected.MD5 != "" && actual.MD5 != expected.MD5 {
-			err = fmt.Errorf("%s: md5 hash mismatch %#v != %#v", url, actual.MD5, expected.MD5)
-		} else if expected.SHA1 != "" && actual.SHA1 != expected.SHA1 {
-			err = fmt.Errorf("%s: sha1 hash mismatch %#v != %#v", url, actual.SHA1, expected.SHA1)
-		} else if expected.SHA256 != "" && actual.SHA256 != expected.SHA256 {
-			err = fmt.Errorf("%s: sha256 hash mismatch %#v != %#v", url, actual.SHA256, expected.SHA256)
-		} else if expected.SHA512 != "" && actual.SHA512 != expected.SHA512 {
-			err = fmt.Errorf("%s: sha512 hash mismatch %#v != %#v", url, actual.SHA512, expected.SHA512)
-		}
-
+		err = verifyChecksums(url, actual, expected)
 		if err != nil {
 			if ignoreMismatch {
 				downloader.progress.Printf("WARNING: %s\n", err.Error())
@@ -274,3 +313,21 @@ func (downloader *downloaderImpl) download(req *http.Request, url, destination s
 
 	return temppath, nil
 }
+
+// verifyChecksums compares actual checksums of the file with expected ones,
+// checksums missing in expected are not verified
+func verifyChecksums(url string, actual utils.ChecksumInfo, expected *utils.ChecksumInfo) error {
+	if actual.Size != expected.Size {
+		return fmt.Errorf("%s: size check mismatch %d != %d", url, actual.Size, expected.Size)
+	} else if expected.MD5 != "" && actual.MD5 != expected.MD5 {
+		return fmt.Errorf("%s: md5 hash mismatch %#v != %#v", url, actual.MD5, expected.MD5)
+	} else if expected.SHA1 != "" && actual.SHA1 != expected.SHA1 {
+		return fmt.Errorf("%s: sha1 hash mismatch %#v != %#v", url, actual.SHA1, expected.SHA1)
+	} else if expected.SHA256 != "" && actual.SHA256 != expected.SHA256 {
+		return fmt.Errorf("%s: sha256 hash mismatch %#v != %#v", url, actual.SHA256, expected.SHA256)
+	} else if expected.SHA512 != "" && actual.SHA512 != expected.SHA512 {
+		return fmt.Errorf("%s: sha512 hash mismatch %#v != %#v", url, actual.SHA512, expected.SHA512)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/pkg/errors"
+)
+
+// LocalPath returns path to the file on local filesystem if URL
+// points to local archive (file://)
+func LocalPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+		return "", false
+	}
+
+	return filepath.FromSlash(u.Path), true
+}
+
+// resolvePath returns absolute path with symlinks resolved, for paths which
+// don't exist yet the longest existing parent is resolved
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+
+	return filepath.Join(resolvePath(parent), filepath.Base(path))
+}
+
+// CheckLocalPath checks that path is located under one of the allowed local
+// archive roots, symlinks are resolved, so they can't lead outside of the roots
+func CheckLocalPath(path string, roots []string) error {
+	if !filepath.IsAbs(path) {
+		return errors.Errorf("local archive path %s should be absolute", path)
+	}
+
+	resolved := resolvePath(path)
+
+	for _, root := range roots {
+		if !filepath.IsAbs(root) {
+			continue
+		}
+
+		resolvedRoot := resolvePath(root)
+		if resolved == resolvedRoot || strings.HasPrefix(resolved, strings.TrimSuffix(resolvedRoot, string(filepath.Separator))+string(filepath.Separator)) {
+			return nil
+		}
+	}
+
+	return errors.Errorf("local archive path %s is not allowed, it should be under one of localArchiveRoots in configuration", path)
+}
+
+// CheckLocalURL checks that local archive URL (file://) is allowed by local archive
+// roots, other URLs are not checked
+func CheckLocalURL(rawURL string, roots []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return nil
+	}
+
+	path, ok := LocalPath(rawURL)
+	if !ok {
+		return errors.Errorf("%s: local archive URL should not have a host", rawURL)
+	}
+
+	return CheckLocalPath(path, roots)
+}
+
+// localFileSystem serves files for file:// URLs, only from allowed local archive roots
+type localFileSystem struct {
+	roots []string
+}
+
+// Open implements http.FileSystem
+func (fs localFileSystem) Open(name string) (http.File, error) {
+	path := filepath.FromSlash(filepath.Clean("/" + name))
+
+	if CheckLocalPath(path, fs.roots) != nil {
+		return nil, os.ErrPermission
+	}
+
+	return os.Open(path)
+}
+
+// VerifyLocalFile checks file in local archive (file:// URL) against expected checksums
+//
+// File is not copied, so it could be imported into the package pool directly
+// (using hardlinks or reflinks if possible). On success path to the file is returned,
+// and expected checksums are updated to contain exactly expected set
+//
+// Only files under local archive roots are allowed
+func VerifyLocalFile(rawURL string, roots []string, expected *utils.ChecksumInfo, ignoreMismatch bool, progress aptly.Progress) (string, error) {
+	path, ok := LocalPath(rawURL)
+	if !ok {
+		return "", errors.Errorf("%s: not a local file", rawURL)
+	}
+
+	err := CheckLocalPath(path, roots)
+	if err != nil {
+		return "", err
+	}
+
+	actual, err := utils.ChecksumsForFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", &Error{Code: 404, URL: rawURL}
+		}
+		return "", errors.Wrap(err, rawURL)
+	}
+
+	if progress != nil {
+		progress.AddBar(int(actual.Size))
+	}
+
+	if expected != nil {
+		err = verifyChecksums(rawURL, actual, expected)
+		if err != nil {
+			if !ignoreMismatch {
+				return "", err
+			}
+			if progress != nil {
+				progress.Printf("WARNING: %s\n", err.Error())
+			}
+		} else {
+			*expected = actual
+		}
+	}
+
+	return path, nil
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type FileSuite struct {
+	DownloaderSuiteBase
+	root    string
+	rootURL string
+}
+
+var _ = Suite(&FileSuite{})
+
+func (s *FileSuite) SetUpTest(c *C) {
+	s.DownloaderSuiteBase.SetUpTest(c)
+
+	s.root = c.MkDir()
+	s.rootURL = "file://" + filepath.ToSlash(s.root)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "test"), []byte("Hello, /test"), 0644), IsNil)
+
+	var err error
+	s.d, err = NewDownloaderWithOptions(0, 1, s.progress, DownloaderOptions{LocalRoots: []string{s.root}})
+	c.Assert(err, IsNil)
+}
+
+func (s *FileSuite) TearDownTest(c *C) {
+	s.DownloaderSuiteBase.TearDownTest(c)
+}
+
+func (s *FileSuite) TestLocalPath(c *C) {
+	path, ok := LocalPath("file:///media/cdrom/dists/stable/Release")
+	c.Check(ok, Equals, true)
+	c.Check(path, Equals, "/media/cdrom/dists/stable/Release")
+
+	_, ok = LocalPath("http://mirror.yandex.ru/debian/dists/stable/Release")
+	c.Check(ok, Equals, false)
+
+	_, ok = LocalPath("%%")
+	c.Check(ok, Equals, false)
+
+	_, ok = LocalPath("file://example.com/etc/passwd")
+	c.Check(ok, Equals, false)
+}
+
+func (s *FileSuite) TestCheckLocalPath(c *C) {
+	c.Check(CheckLocalPath(filepath.Join(s.root, "test"), []string{s.root}), IsNil)
+	c.Check(CheckLocalPath(filepath.Join(s.root, "missing", "file"), []string{s.root}), IsNil)
+	c.Check(CheckLocalPath(s.root, []string{s.root + "/"}), IsNil)
+
+	c.Check(CheckLocalPath(filepath.Join(s.root, "test"), nil), ErrorMatches, "local archive path .* is not allowed.*")
+	c.Check(CheckLocalPath(filepath.Join(s.root, "..", "test"), []string{s.root}), ErrorMatches, "local archive path .* is not allowed.*")
+	c.Check(CheckLocalPath(s.root+"-other", []string{s.root}), ErrorMatches, "local archive path .* is not allowed.*")
+	c.Check(CheckLocalPath("test", []string{s.root}), ErrorMatches, "local archive path test should be absolute")
+
+	// symlinks can't lead outside of the roots
+	outside := c.MkDir()
+	c.Assert(os.Symlink(outside, filepath.Join(s.root, "link")), IsNil)
+	c.Check(CheckLocalPath(filepath.Join(s.root, "link", "file"), []string{s.root}), ErrorMatches, "local archive path .* is not allowed.*")
+}
+
+func (s *FileSuite) TestCheckLocalURL(c *C) {
+	c.Check(CheckLocalURL(s.rootURL+"/test", []string{s.root}), IsNil)
+	c.Check(CheckLocalURL("http://example.com/etc/passwd", nil), IsNil)
+	c.Check(CheckLocalURL("file:///etc/passwd", []string{s.root}), ErrorMatches, "local archive path /etc/passwd is not allowed.*")
+	c.Check(CheckLocalURL("file://example.com/etc/passwd", []string{s.root}), ErrorMatches, ".*local archive URL should not have a host")
+}
+
+func (s *FileSuite) TestDownloadFile(c *C) {
+	err := s.d.DownloadWithChecksum(s.ctx, s.rootURL+"/test", s.tempfile.Name(), &utils.ChecksumInfo{Size: 12,
+		SHA256: "b3c92ee1246176ed35f6e8463cd49074f29442f5bbffc3f8591cde1dcc849dac"}, false)
+	c.Assert(err, IsNil)
+
+	contents, _ := ioutil.ReadFile(s.tempfile.Name())
+	c.Check(string(contents), Equals, "Hello, /test")
+
+	err = s.d.Download(s.ctx, s.rootURL+"/missing", s.tempfile.Name())
+	c.Assert(err, FitsTypeOf, &Error{})
+	c.Check(err.(*Error).Code, Equals, 404)
+
+	err = s.d.Download(s.ctx, "file:///etc/passwd", s.tempfile.Name())
+	c.Check(err, ErrorMatches, "local archive path /etc/passwd is not allowed.*")
+
+	d := NewDownloader(0, 1, s.progress)
+	err = d.Download(s.ctx, s.rootURL+"/test", s.tempfile.Name())
+	c.Check(err, ErrorMatches, "local archive path .* is not allowed.*")
+}
+
+func (s *FileSuite) TestDownloadRedirectToFile(c *C) {
+	server := httptest.NewServer(http.RedirectHandler(s.rootURL+"/test", http.StatusFound))
+	defer server.Close()
+
+	err := s.d.Download(s.ctx, server.URL+"/test", s.tempfile.Name())
+	c.Check(err, ErrorMatches, ".*redirect from .* to file://.* changes URL scheme")
+}
+
+func (s *FileSuite) TestGetLength(c *C) {
+	size, err := s.d.GetLength(s.ctx, s.rootURL+"/test")
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(12))
+
+	_, err = s.d.GetLength(s.ctx, s.rootURL+"/missing")
+	c.Assert(err, FitsTypeOf, &Error{})
+	c.Check(err.(*Error).Code, Equals, 404)
+
+	_, err = s.d.GetLength(s.ctx, "file:///etc/passwd")
+	c.Check(err, ErrorMatches, "local archive path /etc/passwd is not allowed.*")
+}
+
+func (s *FileSuite) TestVerifyLocalFile(c *C) {
+	expected := &utils.ChecksumInfo{Size: 12, MD5: "a1acb0fe91c7db45ec4d775192ec5738"}
+
+	path, err := VerifyLocalFile(s.rootURL+"/test", []string{s.root}, expected, false, s.progress)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, filepath.Join(s.root, "test"))
+	c.Check(expected.SHA256, Equals, "b3c92ee1246176ed35f6e8463cd49074f29442f5bbffc3f8591cde1dcc849dac")
+
+	_, err = VerifyLocalFile(s.rootURL+"/test", []string{s.root}, &utils.ChecksumInfo{Size: 12, MD5: "a1acb0fe91c7db45ec4d775192ec5739"}, false, s.progress)
+	c.Check(err, ErrorMatches, ".*md5 hash mismatch.*")
+
+	path, err = VerifyLocalFile(s.rootURL+"/test", []string{s.root}, &utils.ChecksumInfo{Size: 13}, true, s.progress)
+	c.Check(err, IsNil)
+	c.Check(path, Equals, filepath.Join(s.root, "test"))
+
+	_, err = VerifyLocalFile(s.rootURL+"/missing", []string{s.root}, &utils.ChecksumInfo{Size: 12}, false, s.progress)
+	c.Check(err, ErrorMatches, "HTTP code 404 while fetching .*/missing")
+
+	_, err = VerifyLocalFile("http://example.com/test", []string{s.root}, &utils.ChecksumInfo{Size: 12}, false, s.progress)
+	c.Check(err, ErrorMatches, "http://example.com/test: not a local file")
+
+	_, err = VerifyLocalFile(s.rootURL+"/test", nil, &utils.ChecksumInfo{Size: 12}, false, s.progress)
+	c.Check(err, ErrorMatches, "local archive path .* is not allowed.*")
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// DownloaderOptions are optional settings of the downloader
+type DownloaderOptions struct {
+	// Download speed limits for parts of the day
+	BandwidthWindows []BandwidthWindow
+
+	// Path to PEM-encoded CA certificates trusted in addition to system ones
+	CAFile string
+	// Paths to PEM-encoded client certificate and key for mutual TLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// Skip verification of server certificate (insecure)
+	InsecureSkipVerify bool
+
+	// Proxy URL, overrides proxy configured via environment
+	ProxyURL string
+
+	// HTTP basic authentication credentials
+	Username string
+	Password string
+	// HTTP bearer token, used instead of basic authentication
+	BearerToken string
+
+	// Local directories files could be fetched from using file:// URLs
+	LocalRoots []string
+}
+
+// configureTransport applies TLS and proxy settings to the transport
+func (options *DownloaderOptions) configureTransport(transport *http.Transport) error {
+	if options.ProxyURL != "" {
+		proxyURL, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("unable to parse proxy URL: %s", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.CAFile == "" && options.ClientCertFile == "" && options.ClientKeyFile == "" && !options.InsecureSkipVerify {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify, // nolint: gosec
+	}
+
+	if options.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return fmt.Errorf("unable to load CA certificates: %s", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("unable to load CA certificates: no certificates found in %s", options.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.ClientCertFile != "" || options.ClientKeyFile != "" {
+		if options.ClientCertFile == "" || options.ClientKeyFile == "" {
+			return fmt.Errorf("both client certificate and key should be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(options.ClientCertFile, options.ClientKeyFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate: %s", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return nil
+}
+
+// authorize adds credentials to the request
+//
+// Credentials are sent only over HTTP(S), Go HTTP client drops them
+// on redirects to other hosts
+func (options *DownloaderOptions) authorize(req *http.Request) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return
+	}
+
+	if options.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+options.BearerToken)
+	} else if options.Username != "" || options.Password != "" {
+		req.SetBasicAuth(options.Username, options.Password)
+	}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+
+	. "gopkg.in/check.v1"
+)
+
+type OptionsSuite struct {
+	dir    string
+	server *httptest.Server
+	caFile string
+	ctx    context.Context
+}
+
+var _ = Suite(&OptionsSuite{})
+
+func (s *OptionsSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.ctx = context.Background()
+
+	s.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, %s", r.URL.Path)
+	}))
+
+	s.caFile = filepath.Join(s.dir, "ca.pem")
+	c.Assert(ioutil.WriteFile(s.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.server.Certificate().Raw}), 0644), IsNil)
+}
+
+func (s *OptionsSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *OptionsSuite) fetch(c *C, d aptly.Downloader, url string) (string, error) {
+	destination := filepath.Join(s.dir, "download")
+	err := d.Download(s.ctx, url, destination)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(destination)
+	c.Assert(err, IsNil)
+	return string(content), nil
+}
+
+func (s *OptionsSuite) downloader(c *C, options DownloaderOptions) aptly.Downloader {
+	d, err := NewDownloaderWithOptions(0, 1, nil, options)
+	c.Assert(err, IsNil)
+	return d
+}
+
+// writeClientCertificate generates self-signed client certificate and returns its paths
+func (s *OptionsSuite) writeClientCertificate(c *C) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "aptly"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile, keyFile := filepath.Join(s.dir, "client.crt"), filepath.Join(s.dir, "client.key")
+	c.Assert(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600), IsNil)
+
+	return cert, certFile, keyFile
+}
+
+func (s *OptionsSuite) TestUnknownCA(c *C) {
+	_, err := s.fetch(c, NewDownloader(0, 1, nil), s.server.URL+"/test")
+	c.Check(err, ErrorMatches, ".*certificate.*")
+}
+
+func (s *OptionsSuite) TestCAFile(c *C) {
+	content, err := s.fetch(c, s.downloader(c, DownloaderOptions{CAFile: s.caFile}), s.server.URL+"/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "Hello, /test")
+}
+
+func (s *OptionsSuite) TestInsecureSkipVerify(c *C) {
+	content, err := s.fetch(c, s.downloader(c, DownloaderOptions{InsecureSkipVerify: true}), s.server.URL+"/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "Hello, /test")
+}
+
+func (s *OptionsSuite) TestClientCertificate(c *C) {
+	cert, certFile, keyFile := s.writeClientCertificate(c)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, %s", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  x509.NewCertPool(),
+	}
+	server.TLS.ClientCAs.AddCert(cert)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(s.dir, "server-ca.pem")
+	c.Assert(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644), IsNil)
+
+	_, err := s.fetch(c, s.downloader(c, DownloaderOptions{CAFile: caFile}), server.URL+"/test")
+	c.Check(err, NotNil)
+
+	content, err := s.fetch(c, s.downloader(c, DownloaderOptions{CAFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}), server.URL+"/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "Hello, aptly")
+}
+
+func (s *OptionsSuite) TestCredentials(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); ok {
+			fmt.Fprintf(w, "basic %s:%s", username, password)
+			return
+		}
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	_, err := s.fetch(c, s.downloader(c, DownloaderOptions{InsecureSkipVerify: true}), server.URL+"/test")
+	c.Check(err, ErrorMatches, ".*401.*")
+
+	content, err := s.fetch(c, s.downloader(c, DownloaderOptions{InsecureSkipVerify: true, Username: "user", Password: "secret"}), server.URL+"/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "basic user:secret")
+
+	content, err = s.fetch(c, s.downloader(c, DownloaderOptions{InsecureSkipVerify: true, BearerToken: "t0ken"}), server.URL+"/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "Bearer t0ken")
+}
+
+func (s *OptionsSuite) TestProxy(c *C) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Proxied %s", r.URL)
+	}))
+	defer proxy.Close()
+
+	content, err := s.fetch(c, s.downloader(c, DownloaderOptions{ProxyURL: proxy.URL}), "http://archive.example.com/debian/test")
+	c.Check(err, IsNil)
+	c.Check(content, Equals, "Proxied http://archive.example.com/debian/test")
+}
+
+func (s *OptionsSuite) TestErrors(c *C) {
+	_, err := NewDownloaderWithOptions(0, 1, nil, DownloaderOptions{CAFile: filepath.Join(s.dir, "missing.pem")})
+	c.Check(err, ErrorMatches, "unable to load CA certificates: .*no such file or directory")
+
+	empty := filepath.Join(s.dir, "empty.pem")
+	c.Assert(ioutil.WriteFile(empty, nil, 0644), IsNil)
+	_, err = NewDownloaderWithOptions(0, 1, nil, DownloaderOptions{CAFile: empty})
+	c.Check(err, ErrorMatches, "unable to load CA certificates: no certificates found in .*")
+
+	_, err = NewDownloaderWithOptions(0, 1, nil, DownloaderOptions{ClientCertFile: s.caFile})
+	c.Check(err, ErrorMatches, "both client certificate and key should be specified")
+
+	_, err = NewDownloaderWithOptions(0, 1, nil, DownloaderOptions{ClientCertFile: s.caFile, ClientKeyFile: s.caFile})
+	c.Check(err, ErrorMatches, "unable to load client certificate: .*")
+
+	_, err = NewDownloaderWithOptions(0, 1, nil, DownloaderOptions{ProxyURL: "http://proxy:port"})
+	c.Check(err, ErrorMatches, "unable to parse proxy URL: .*")
+}
@@ -415,3 +415,20 @@ class CreateMirror32Test(BaseTest):
     def check(self):
         self.check_output()
         self.check_cmd_output("aptly mirror show mirror32", "mirror_show")
+
+
+class CreateMirror33Test(BaseTest):
+    """
+    create mirror: wrong installer flavour
+    """
+    runCmd = "aptly mirror create -ignore-signatures -with-installer -installer-flavour=cdrom/[ mirror33 http://cdn-fastly.deb.debian.org/debian/ stretch main"
+    expectedCode = 1
+
+
+class CreateMirror34Test(BaseTest):
+    """
+    create mirror: local archive outside of localArchiveRoots
+    """
+    configOverride = {"localArchiveRoots": ["/srv/archives"]}
+    runCmd = "aptly mirror create -ignore-signatures mirror34 file:///etc/ stretch main"
+    expectedCode = 1
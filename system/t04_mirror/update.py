@@ -424,3 +424,21 @@ class UpdateMirror24Test(BaseTest):
 
     def output_processor(self, output):
         return "\n".join(sorted(output.split("\n")))
+
+
+class UpdateMirror25Test(BaseTest):
+    """
+    update mirrors: dry run
+    """
+    fixtureCmds = [
+        "aptly mirror create --ignore-signatures failure ${url} hardy main",
+    ]
+    fixtureWebServer = "test_release2"
+    runCmd = "aptly mirror update -dry-run --ignore-signatures failure"
+
+    def gold_processor(self, gold):
+        return string.Template(gold).substitute({'url': self.webServerUrl})
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly mirror show failure", "mirror_show")
@@ -0,0 +1,74 @@
+from lib import BaseTest
+
+
+class PruneSnapshot1Test(BaseTest):
+    """
+    prune snapshots: dry run
+    """
+    fixtureDB = True
+    fixtureCmds = [
+        "aptly snapshot create snap1 from mirror wheezy-non-free",
+        "aptly snapshot create snap2 from mirror wheezy-non-free",
+        "aptly snapshot create snap3 from mirror wheezy-non-free",
+    ]
+    runCmd = "aptly snapshot prune -keep-last=1 -dry-run wheezy-non-free"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot list -raw", "snapshot_list")
+
+
+class PruneSnapshot2Test(BaseTest):
+    """
+    prune snapshots: snapshot used as source is kept
+    """
+    fixtureDB = True
+    fixtureCmds = [
+        "aptly snapshot create snap1 from mirror wheezy-non-free",
+        "aptly snapshot create snap2 from mirror wheezy-non-free",
+        "aptly snapshot create snap3 from mirror wheezy-non-free",
+        "aptly snapshot merge snap4 snap1",
+    ]
+    runCmd = "aptly snapshot prune -keep-last=1 wheezy-non-free"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot list -raw", "snapshot_list")
+
+
+class PruneSnapshot3Test(BaseTest):
+    """
+    prune snapshots: retention policy configured for the mirror
+    """
+    fixtureDB = True
+    fixtureCmds = [
+        "aptly snapshot create snap1 from mirror wheezy-non-free",
+        "aptly snapshot create snap2 from mirror wheezy-non-free",
+        "aptly snapshot create snap3 from mirror wheezy-non-free",
+        "aptly mirror edit -keep-last=2 wheezy-non-free",
+    ]
+    runCmd = "aptly snapshot prune"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot list -raw", "snapshot_list")
+
+
+class PruneSnapshot4Test(BaseTest):
+    """
+    prune snapshots: mirror without retention policy
+    """
+    fixtureDB = True
+    fixtureCmds = [
+        "aptly snapshot create snap1 from mirror wheezy-non-free",
+    ]
+    runCmd = "aptly snapshot prune wheezy-non-free"
+
+
+class PruneSnapshot5Test(BaseTest):
+    """
+    prune snapshots: invalid policy
+    """
+    fixtureDB = True
+    runCmd = "aptly snapshot prune -keep-last=-1"
+    expectedCode = 1
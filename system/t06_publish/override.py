@@ -0,0 +1,87 @@
+import os
+
+from lib import BaseTest
+
+# binary override file shared by tests
+override_main = os.path.join(os.path.dirname(os.path.abspath(__file__)), "PublishOverride1Test", "override.maverick.main")
+
+
+class PublishOverride1Test(BaseTest):
+    """
+    publish override: load override file
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish override -skip-signing maverick main ${testfiles}/override.maverick.main"
+
+    def check(self):
+        super(PublishOverride1Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show")
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Priority: important\nSection: libdevel/custom\n', packages)
+
+        sources = self.read_file('public/dists/maverick/main/source/Sources')
+        self.check_equal('libdevel/custom' in sources, False)
+
+
+class PublishOverride2Test(BaseTest):
+    """
+    publish override: remove override table
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+        "aptly publish override -skip-signing maverick main " + override_main,
+    ]
+    runCmd = "aptly publish override -skip-signing -remove maverick main"
+
+    def check(self):
+        super(PublishOverride2Test, self).check()
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_equal('libdevel/custom' in packages, False)
+
+
+class PublishOverride3Test(BaseTest):
+    """
+    publish override: component not published
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish override -skip-signing -remove maverick contrib"
+    expectedCode = 1
+
+
+class PublishOverride4Test(BaseTest):
+    """
+    publish override: load source override file
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+        "aptly publish override -skip-signing maverick main " + override_main,
+    ]
+    runCmd = "aptly publish override -skip-signing -source maverick main ${testfiles}/override.maverick.main.src"
+
+    def check(self):
+        super(PublishOverride4Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show")
+
+        sources = self.read_file('public/dists/maverick/main/source/Sources')
+        self.check_in('Section: python/custom\n', sources)
+
+        # binary override table is kept
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Priority: important\nSection: libdevel/custom\n', packages)
+        self.check_equal('python/custom' in packages, False)
@@ -0,0 +1,94 @@
+from lib import BaseTest
+
+
+class PublishPhase1Test(BaseTest):
+    """
+    publish phase: set phased update percentage
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish phase -skip-signing maverick 'libboost-program-options-dev (>> 1.50)' 10"
+
+    def check(self):
+        super(PublishPhase1Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show")
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Phased-Update-Percentage: 10', packages)
+        self.check_equal(packages.count('Phased-Update-Percentage'), 1)
+
+
+class PublishPhase2Test(BaseTest):
+    """
+    publish phase: no packages match
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish phase -skip-signing maverick nginx 10"
+    expectedCode = 1
+
+
+class PublishPhase3Test(BaseTest):
+    """
+    publish phase: phase new version on publish update
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+    ]
+    runCmd = "aptly publish update -skip-signing -phase='libboost-program-options-dev (>> 1.50):10' maverick"
+
+    def check(self):
+        super(PublishPhase3Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show")
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Version: 1.62.0.1\n', packages)
+        self.check_in('Phased-Update-Percentage: 10', packages)
+        self.check_equal(packages.count('Phased-Update-Percentage'), 1)
+
+
+class PublishPhase4Test(BaseTest):
+    """
+    publish phase: phase new version on publish switch
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly repo remove local-repo libboost-program-options-dev",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly snapshot create snap2 from repo local-repo",
+        "aptly publish snapshot -skip-signing -distribution=maverick snap1",
+    ]
+    runCmd = "aptly publish switch -skip-signing -phase=libboost-program-options-dev:25 maverick snap2"
+
+    def check(self):
+        super(PublishPhase4Test, self).check()
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Version: 1.62.0.1\n', packages)
+        self.check_in('Phased-Update-Percentage: 25', packages)
+
+
+class PublishPhase5Test(BaseTest):
+    """
+    publish phase: publish update fails if phase query matches nothing
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish update -skip-signing -phase=nginx:10 maverick"
+    expectedCode = 1
@@ -0,0 +1,34 @@
+from lib import BaseTest
+
+
+class PublishRefresh1Test(BaseTest):
+    """
+    publish refresh: re-date Release file with Valid-Until
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/",
+        "aptly publish repo -skip-signing -valid-for=168h -signed-by=C5ACD2179B5231DFE842EE6121DBB89C16DB3E6D -distribution=maverick local-repo",
+    ]
+    runCmd = "aptly publish refresh -keyring=${files}/aptly.pub -secret-keyring=${files}/aptly.sec -valid-for=24h maverick"
+    gold_processor = BaseTest.expand_environ
+
+    def check(self):
+        super(PublishRefresh1Test, self).check()
+
+        self.check_exists('public/dists/maverick/InRelease')
+        self.check_exists('public/dists/maverick/Release.gpg')
+        self.check_not_exists('public/dists/maverick/Release.tmp')
+
+        release = self.read_file('public/dists/maverick/Release')
+        self.check_in('Valid-Until: ', release)
+        self.check_in('Signed-By: C5ACD2179B5231DFE842EE6121DBB89C16DB3E6D', release)
+        self.check_in('main/binary-i386/Packages.gz', release)
+
+
+class PublishRefresh2Test(BaseTest):
+    """
+    publish refresh: not published
+    """
+    runCmd = "aptly publish refresh -skip-signing maverick"
+    expectedCode = 1
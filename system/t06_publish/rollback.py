@@ -0,0 +1,155 @@
+import os
+import re
+
+from lib import BaseTest
+
+
+def strip_generation_dates(s):
+    return re.sub(r'\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} UTC', '', s)
+
+
+class PublishRollbackBase(BaseTest):
+    """
+    base class for publish rollback tests: checks on-disk layout of generations
+    """
+
+    def check_dists_link(self, path, target):
+        self.check_equal(os.readlink(os.path.join(os.environ["HOME"], ".aptly", path)), target)
+
+
+class PublishRollback1Test(PublishRollbackBase):
+    """
+    publish rollback: local repo published atomically, rolled back after update
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly publish repo -atomic -skip-signing -distribution=maverick local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly publish update -skip-signing maverick",
+    ]
+    runCmd = "aptly publish rollback maverick"
+
+    def check(self):
+        super(PublishRollback1Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show", match_prepare=strip_generation_dates)
+
+        self.check_dists_link('public/dists/maverick', '.generations/maverick/1')
+        self.check_exists('public/dists/.generations/maverick/1/Release')
+        self.check_not_exists('public/dists/.generations/maverick/2')
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Version: 1.49.0.1\n', packages)
+        self.check_equal('Version: 1.62.0.1' in packages, False)
+
+        # files published only by dropped generation are cleaned up
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_not_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb')
+
+
+class PublishRollback2Test(PublishRollbackBase):
+    """
+    publish rollback: snapshot published atomically, switched twice, rolled back
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly snapshot create snap2 from repo local-repo",
+        "aptly repo remove local-repo libboost-program-options-dev_1.49.0.1_i386",
+        "aptly snapshot create snap3 from repo local-repo",
+        "aptly publish snapshot -atomic -keep-generations=1 -skip-signing -distribution=maverick snap1",
+        "aptly publish switch -skip-signing maverick snap2",
+        "aptly publish switch -skip-signing maverick snap3",
+    ]
+    runCmd = "aptly publish rollback maverick"
+
+    def check(self):
+        super(PublishRollback2Test, self).check()
+
+        self.check_cmd_output("aptly publish show maverick", "publish_show", match_prepare=strip_generation_dates)
+
+        # only one previous generation was kept, so generation 1 has been dropped by second switch
+        self.check_dists_link('public/dists/maverick', '.generations/maverick/2')
+        self.check_not_exists('public/dists/.generations/maverick/1')
+        self.check_not_exists('public/dists/.generations/maverick/3')
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_in('Version: 1.49.0.1\n', packages)
+        self.check_in('Version: 1.62.0.1\n', packages)
+
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb')
+
+        # there's no generation to roll back to anymore
+        self.check_cmd_output("aptly publish rollback maverick", "rollback_again", expected_code=1)
+
+
+class PublishRollback3Test(PublishRollbackBase):
+    """
+    publish rollback: -skip-cleanup keeps files of dropped generation
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly publish repo -atomic -skip-signing -distribution=maverick local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly publish update -skip-signing maverick",
+    ]
+    runCmd = "aptly publish rollback -skip-cleanup maverick"
+
+    def check(self):
+        super(PublishRollback3Test, self).check()
+
+        self.check_dists_link('public/dists/maverick', '.generations/maverick/1')
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb')
+
+
+class PublishRollback4Test(BaseTest):
+    """
+    publish rollback: repository not published atomically
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly publish repo -skip-signing -distribution=maverick local-repo",
+        "aptly publish update -skip-signing maverick",
+    ]
+    runCmd = "aptly publish rollback maverick"
+    expectedCode = 1
+
+
+class PublishRollback5Test(BaseTest):
+    """
+    publish rollback: no such published repository
+    """
+    runCmd = "aptly publish rollback maverick ppa"
+    expectedCode = 1
+
+
+class PublishRollback6Test(PublishRollbackBase):
+    """
+    publish rollback: snapshot of previous generation can't be dropped
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly snapshot create snap2 from repo local-repo",
+        "aptly publish snapshot -atomic -skip-signing -distribution=maverick snap1",
+        "aptly publish switch -skip-signing maverick snap2",
+    ]
+    runCmd = "aptly snapshot drop snap1"
+    expectedCode = 1
+
+    def check(self):
+        super(PublishRollback6Test, self).check()
+
+        self.check_cmd_output("aptly publish rollback maverick", "rollback")
+        self.check_dists_link('public/dists/maverick', '.generations/maverick/1')
+
+        packages = self.read_file('public/dists/maverick/main/binary-i386/Packages')
+        self.check_equal('Version: 1.62.0.1' in packages, False)
@@ -1217,3 +1217,42 @@ class PublishSnapshot39Test(BaseTest):
                                  'contents_i386', match_prepare=ungzip_if_required)
         self.check_file_contents('public/dists/maverick/main/Contents-amd64.gz',
                                  'contents_amd64', match_prepare=ungzip_if_required)
+
+
+class PublishSnapshot40Test(BaseTest):
+    """
+    publish snapshot: custom index compression
+    """
+    fixtureDB = True
+    fixturePool = True
+    fixtureCmds = [
+        "aptly snapshot create snap40 from mirror gnuplot-maverick",
+    ]
+    runCmd = "aptly publish snapshot -compression=gz,xz,zst -keyring=${files}/aptly.pub -secret-keyring=${files}/aptly.sec -distribution=squeeze snap40 ppa/smira"
+
+    gold_processor = BaseTest.expand_environ
+
+    def check(self):
+        super(PublishSnapshot40Test, self).check()
+
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-i386/Packages.gz')
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-i386/Packages.xz')
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-i386/Packages.zst')
+        self.check_not_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-i386/Packages')
+        self.check_not_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-i386/Packages.bz2')
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/binary-amd64/Packages.zst')
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/Contents-i386.zst')
+        self.check_exists(
+            'public/ppa/smira/dists/squeeze/main/Contents-amd64.zst')
+
+        release = self.read_file('public/ppa/smira/dists/squeeze/Release')
+        self.check_in('main/binary-i386/Packages.zst', release)
+        self.check_in('main/binary-i386/Packages.xz', release)
+        self.check_in('main/Contents-amd64.zst', release)
@@ -545,3 +545,90 @@ class PublishSwitch14Test(BaseTest):
                              'main/binary-amd64/Release', 'main/binary-i386/Release', 'main/Contents-amd64.gz',
                              'main/Contents-i386.gz', 'Contents-i386.gz', 'Contents-amd64.gz']):
             raise Exception("path seen wrong: %r" % (pathsSeen, ))
+
+
+class PublishSwitch15Test(BaseTest):
+    """
+    publish switch: several published repositories at once
+    """
+    fixtureCmds = [
+        "aptly repo create -distribution=maverick local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot create snap2 from repo local-repo",
+        "aptly publish snapshot -architectures=i386 -skip-signing -distribution=maverick -target=maverick-security -target=other:maverick snap1",
+    ]
+    runCmd = "aptly publish switch -skip-signing -target=maverick-security -target=other:maverick maverick snap2"
+
+    def check(self):
+        super(PublishSwitch15Test, self).check()
+
+        for path in ['public/dists/maverick', 'public/dists/maverick-security', 'public/other/dists/maverick']:
+            self.check_exists(path + '/Release')
+            self.check_exists(path + '/main/binary-i386/Packages')
+
+        self.check_file_contents('public/dists/maverick-security/main/binary-i386/Packages', 'binary',
+                                 match_prepare=lambda s: "\n".join(sorted(s.split("\n"))))
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_exists('public/other/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb')
+
+        if self.read_file('public/dists/maverick/main/binary-i386/Packages') != \
+                self.read_file('public/other/dists/maverick/main/binary-i386/Packages'):
+            raise Exception("Packages files differ")
+
+
+class PublishSwitch16Test(BaseTest):
+    """
+    publish switch: target with different components
+    """
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot create snap2 empty",
+        "aptly publish snapshot -architectures=i386 -skip-signing -distribution=maverick snap1",
+        "aptly publish snapshot -architectures=i386 -skip-signing -distribution=maverick-security -component=contrib snap1",
+    ]
+    runCmd = "aptly publish switch -skip-signing -target=maverick-security maverick snap2"
+    expectedCode = 1
+
+
+class PublishSwitch17Test(BaseTest):
+    """
+    publish switch: -dry-run
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly repo remove local-repo libboost-program-options-dev pyspi",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly snapshot create snap2 from repo local-repo",
+        "aptly publish snapshot -architectures=i386,source -skip-signing -distribution=maverick snap1",
+    ]
+    runCmd = "aptly publish switch -dry-run maverick snap2"
+
+    def check(self):
+        super(PublishSwitch17Test, self).check()
+
+        self.check_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb')
+        self.check_exists('public/pool/main/p/pyspi/pyspi_0.6.1-1.3.dsc')
+        self.check_not_exists('public/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb')
+        self.check_cmd_output("aptly publish show maverick", "publish_show")
+
+
+class PublishSwitch18Test(BaseTest):
+    """
+    publish switch: target without prefix is a distribution, even with slash
+    """
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot create snap2 empty",
+        "aptly publish snapshot -architectures=i386 -skip-signing -distribution=maverick snap1",
+        "aptly publish snapshot -architectures=i386 -skip-signing -distribution=updates snap1 maverick",
+    ]
+    runCmd = "aptly publish switch -skip-signing -target=maverick/updates maverick snap2"
+    expectedCode = 1
+
+    def check(self):
+        super(PublishSwitch18Test, self).check()
+
+        self.check_cmd_output("aptly publish show updates maverick", "publish_show")
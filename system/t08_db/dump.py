@@ -0,0 +1,84 @@
+import os
+import shutil
+
+from lib import BaseTest
+
+
+class ExportDB1Test(BaseTest):
+    """
+    export db: regular export
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly repo create -distribution=squeeze local-repo2",
+        "aptly repo add local-repo2 ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo local-repo2",
+    ]
+    runCmd = "aptly db export ${aptlyroot}/dump.jsonl.gz"
+    gold_processor = BaseTest.expand_environ
+
+    def check(self):
+        self.check_output()
+        self.check_exists("dump.jsonl.gz")
+
+
+class ExportDB2Test(BaseTest):
+    """
+    export db: unable to create file
+    """
+    runCmd = "aptly db export ${aptlyroot}/no-such-dir/dump.jsonl.gz"
+    expectedCode = 1
+    gold_processor = BaseTest.expand_environ
+
+
+class ImportDB1Test(BaseTest):
+    """
+    import db: export, drop database and import back
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly repo create -distribution=squeeze local-repo2",
+        "aptly repo add local-repo2 ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo local-repo2",
+        "aptly db export ${aptlyroot}/dump.jsonl.gz",
+    ]
+    runCmd = "aptly db import ${aptlyroot}/dump.jsonl.gz"
+    gold_processor = BaseTest.expand_environ
+
+    def prepare(self):
+        super(ImportDB1Test, self).prepare()
+
+        shutil.rmtree(os.path.join(os.environ["HOME"], ".aptly", "db"))
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly repo list", "repo_list")
+        self.check_cmd_output("aptly repo show -with-packages local-repo2", "repo_show")
+
+
+class ImportDB2Test(BaseTest):
+    """
+    import db: database is not empty
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly db export ${aptlyroot}/dump.jsonl.gz",
+    ]
+    runCmd = "aptly db import ${aptlyroot}/dump.jsonl.gz"
+    expectedCode = 1
+    gold_processor = BaseTest.expand_environ
+
+
+class ImportDB3Test(BaseTest):
+    """
+    import db: not a dump
+    """
+    runCmd = "aptly db import ${aptlyroot}/dump.jsonl"
+    expectedCode = 1
+    gold_processor = BaseTest.expand_environ
+
+    def prepare(self):
+        super(ImportDB3Test, self).prepare()
+
+        with open(os.path.join(os.environ["HOME"], ".aptly", "dump.jsonl"), "w") as f:
+            f.write('{"type": "header", "format": "something-else"}\n')
@@ -0,0 +1,49 @@
+from lib import BaseTest
+
+
+class MigrateDB1Test(BaseTest):
+    """
+    migrate db: goleveldb to bbolt
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly repo create -distribution=squeeze local-repo2",
+        "aptly repo add local-repo2 ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+    ]
+    runCmd = "aptly db migrate bbolt"
+
+    def check(self):
+        self.check_output()
+
+        self.configOverride = {"databaseBackend": "bbolt"}
+        self.prepare_default_config()
+        self.check_cmd_output("aptly repo list", "repo_list")
+        self.check_cmd_output("aptly repo show -with-packages local-repo2", "repo_show")
+
+
+class MigrateDB2Test(BaseTest):
+    """
+    migrate db: to the same backend
+    """
+    runCmd = "aptly db migrate goleveldb"
+    expectedCode = 1
+
+
+class MigrateDB3Test(BaseTest):
+    """
+    migrate db: unknown backend
+    """
+    runCmd = "aptly db migrate sqlite"
+    expectedCode = 1
+
+
+class MigrateDB4Test(BaseTest):
+    """
+    migrate db: target is not empty
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly db migrate bbolt",
+    ]
+    runCmd = "aptly db migrate bbolt"
+    expectedCode = 1
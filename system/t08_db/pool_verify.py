@@ -0,0 +1,36 @@
+from lib import BaseTest
+
+
+class PoolVerify1Test(BaseTest):
+    """
+    pool verify: empty database
+    """
+    runCmd = "aptly pool verify"
+
+
+class PoolVerify2Test(BaseTest):
+    """
+    pool verify: all files are present
+    """
+    fixtureCmds = [
+        "aptly repo create local",
+        "aptly repo add local ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+    ]
+    runCmd = "aptly pool verify -quick"
+
+
+class PoolVerify3Test(BaseTest):
+    """
+    pool verify: missing file
+    """
+    fixtureCmds = [
+        "aptly repo create local",
+        "aptly repo add local ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+    ]
+    runCmd = "aptly pool verify"
+    expectedCode = 1
+
+    def prepare(self):
+        super(PoolVerify3Test, self).prepare()
+
+        self.delete_file("pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb")
@@ -0,0 +1,46 @@
+import gzip
+import io
+import json
+
+from api_lib import APITest
+
+
+class DbExportAPITest(APITest):
+    """
+    GET /db/export
+    """
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post("/api/repos", json={"Name": repo_name}).status_code, 201)
+
+        resp = self.get("/api/db/export")
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.headers["Content-Type"], "application/gzip")
+
+        lines = gzip.GzipFile(fileobj=io.BytesIO(resp.content)).read().decode("utf-8").splitlines()
+
+        header = json.loads(lines[0])
+        self.check_equal(header["format"], "aptly-db-dump")
+        self.check_equal(header["version"], 1)
+
+        trailer = json.loads(lines[-1])
+        self.check_equal(trailer["type"], "trailer")
+        self.check_equal(trailer["records"], len(lines) - 2)
+        self.check_in("repos", trailer["collections"])
+
+
+class DbImportAPITest(APITest):
+    """
+    POST /db/import
+    """
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post("/api/repos", json={"Name": repo_name}).status_code, 201)
+
+        dump = self.get("/api/db/export").content
+
+        resp = self.post("/api/db/import", data=dump)
+        self.check_equal(resp.status_code, 409)
+        self.check_equal(resp.json(), {"error": "database is not empty"})
@@ -0,0 +1,25 @@
+from api_lib import APITest
+
+
+class MetricsAPITest(APITest):
+    """
+    GET /metrics
+    """
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post("/api/repos", json={"Name": repo_name}).status_code, 201)
+
+        resp = self.get("/api/metrics")
+        self.check_equal(resp.status_code, 200)
+        self.check_in('aptly_repo_packages{name="%s"} 0' % repo_name, resp.text)
+        self.check_in('aptly_api_http_requests_total{code="201",method="POST",path="/api/repos"}', resp.text)
+
+        self.get("/api/repos/" + repo_name)
+        resp = self.get("/api/metrics")
+        self.check_in('path="/api/repos/:name"', resp.text)
+
+        self.get("/api/no-such-route/" + repo_name)
+        resp = self.get("/api/metrics")
+        self.check_in('path="<unmatched>"', resp.text)
+        self.check_equal(repo_name in resp.text.replace('aptly_repo_packages{name="%s"}' % repo_name, ''), False)
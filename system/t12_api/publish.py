@@ -585,3 +585,129 @@ class PublishSwitchAPISkipCleanupTestRepo(APITest):
             "public/" + prefix + "/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb")
         self.check_exists("public/" + prefix +
                           "/pool/main/p/pyspi/pyspi-0.6.1-1.3.stripped.dsc")
+
+
+class PublishRollbackAPITestRepo(APITest):
+    """
+    POST /publish/:prefix (atomic), PUT /publish/:prefix/:distribution, POST /publish/:prefix/:distribution/rollback
+    """
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post(
+            "/api/repos", json={"Name": repo_name, "DefaultDistribution": "wheezy"}).status_code, 201)
+
+        snapshots = []
+        for filename in ["libboost-program-options-dev_1.49.0.1_i386.deb", "libboost-program-options-dev_1.62.0.1_i386.deb"]:
+            d = self.random_name()
+            self.check_equal(self.upload("/api/files/" + d, filename).status_code, 200)
+            self.check_equal(self.post("/api/repos/" + repo_name + "/file/" + d).status_code, 200)
+
+            snapshots.append(self.random_name())
+            self.check_equal(self.post("/api/repos/" + repo_name +
+                                       '/snapshots', json={'Name': snapshots[-1]}).status_code, 201)
+
+        prefix = self.random_name()
+        resp = self.post("/api/publish/" + prefix,
+                         json={
+                             "Architectures": ["i386"],
+                             "SourceKind": "snapshot",
+                             "Sources": [{"Name": snapshots[0]}],
+                             "Signing": {"Skip": True},
+                             "Atomic": True,
+                             "KeepGenerations": 1,
+                         })
+        self.check_equal(resp.status_code, 201)
+        self.check_equal(resp.json()['Atomic'], True)
+        self.check_equal([g['ID'] for g in resp.json()['Generations']], [1])
+
+        dists = os.path.join(os.environ["HOME"], ".aptly", "public", prefix, "dists")
+        self.check_equal(os.readlink(os.path.join(dists, "wheezy")), ".generations/wheezy/1")
+
+        resp = self.put("/api/publish/" + prefix + "/wheezy",
+                        json={
+                            "Snapshots": [{"Component": "main", "Name": snapshots[1]}],
+                            "Signing": {"Skip": True},
+                        })
+        self.check_equal(resp.status_code, 200)
+        self.check_equal([g['ID'] for g in resp.json()['Generations']], [1, 2])
+        self.check_equal(os.readlink(os.path.join(dists, "wheezy")), ".generations/wheezy/2")
+        self.check_exists("public/" + prefix +
+                          "/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb")
+
+        resp = self.post("/api/publish/" + prefix + "/wheezy/rollback")
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.json()['Sources'], [{'Component': 'main', 'Name': snapshots[0]}])
+        self.check_equal([g['ID'] for g in resp.json()['Generations']], [1])
+
+        self.check_equal(os.readlink(os.path.join(dists, "wheezy")), ".generations/wheezy/1")
+        self.check_not_exists("public/" + prefix + "/dists/.generations/wheezy/2")
+        self.check_exists("public/" + prefix +
+                          "/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb")
+        self.check_not_exists("public/" + prefix +
+                              "/pool/main/b/boost-defaults/libboost-program-options-dev_1.62.0.1_i386.deb")
+
+        packages = self.read_file("public/" + prefix + "/dists/wheezy/main/binary-i386/Packages")
+        self.check_equal('Version: 1.62.0.1' in packages, False)
+
+        # no previous generation anymore
+        resp = self.post("/api/publish/" + prefix + "/wheezy/rollback")
+        self.check_equal(resp.status_code, 500)
+
+
+class PublishSwitchPhaseAPITestRepo(APITest):
+    """
+    PUT /publish/:prefix/:distribution (snapshots) with phased updates
+    """
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post(
+            "/api/repos", json={"Name": repo_name, "DefaultDistribution": "wheezy"}).status_code, 201)
+
+        snapshots = []
+        for filename in ["libboost-program-options-dev_1.49.0.1_i386.deb", "libboost-program-options-dev_1.62.0.1_i386.deb"]:
+            d = self.random_name()
+            self.check_equal(self.upload("/api/files/" + d, filename).status_code, 200)
+            self.check_equal(self.post("/api/repos/" + repo_name + "/file/" + d).status_code, 200)
+
+            snapshots.append(self.random_name())
+            self.check_equal(self.post("/api/repos/" + repo_name +
+                                       '/snapshots', json={'Name': snapshots[-1]}).status_code, 201)
+
+        prefix = self.random_name()
+        resp = self.post("/api/publish/" + prefix,
+                         json={
+                             "Architectures": ["i386"],
+                             "SourceKind": "snapshot",
+                             "Sources": [{"Name": snapshots[0]}],
+                             "Signing": {"Skip": True},
+                         })
+        self.check_equal(resp.status_code, 201)
+
+        resp = self.put("/api/publish/" + prefix + "/wheezy",
+                        json={
+                            "Snapshots": [{"Component": "main", "Name": snapshots[1]}],
+                            "PhasedUpdates": [{"Query": "nginx", "Percentage": 10}],
+                            "Signing": {"Skip": True},
+                        })
+        self.check_equal(resp.status_code, 400)
+
+        packages = self.read_file("public/" + prefix + "/dists/wheezy/main/binary-i386/Packages")
+        self.check_equal('Version: 1.62.0.1' in packages, False)
+
+        resp = self.put("/api/publish/" + prefix + "/wheezy",
+                        json={
+                            "Snapshots": [{"Component": "main", "Name": snapshots[1]}],
+                            "PhasedUpdates": [{"Query": "libboost-program-options-dev (>> 1.50)", "Percentage": 10}],
+                            "Signing": {"Skip": True},
+                        })
+        self.check_equal(resp.status_code, 200)
+        phased = resp.json()['PhasedUpdates']
+        self.check_equal(list(phased.values()), [10])
+        self.check_equal(list(phased.keys())[0].startswith('Pi386 libboost-program-options-dev 1.62.0.1 '), True)
+
+        packages = self.read_file("public/" + prefix + "/dists/wheezy/main/binary-i386/Packages")
+        self.check_in('Version: 1.62.0.1\n', packages)
+        self.check_in('Phased-Update-Percentage: 10', packages)
+        self.check_equal(packages.count('Phased-Update-Percentage'), 1)
@@ -277,3 +277,40 @@ class SnapshotsAPITestDiff(APITest):
         resp = self.get("/api/snapshots/" + snapshots[1] + "/diff/" + snapshots[1])
         self.check_equal(resp.status_code, 200)
         self.check_equal(resp.json(), [])
+
+
+class SnapshotsAPITestPrune(APITest):
+    """
+    POST /api/snapshots/prune
+    """
+    def check(self):
+        mirror_name = self.random_name()
+        mirror_desc = {u'Name': mirror_name,
+                       u'ArchiveURL': 'http://security.debian.org/',
+                       u'Architectures': ['amd64'],
+                       u'Components': ['main'],
+                       u'Distribution': 'wheezy/updates',
+                       u'IgnoreSignatures': True,
+                       u'Retention': {u'KeepLast': 3, u'KeepWeekly': 2}}
+
+        resp = self.post("/api/mirrors", json=mirror_desc)
+        self.check_equal(resp.status_code, 201)
+        self.check_equal(self.get("/api/mirrors/" + mirror_name).json()['Retention'],
+                         {u'KeepLast': 3, u'KeepWeekly': 2})
+
+        resp = self.post("/api/snapshots/prune", json={u'Mirrors': [mirror_name], u'DryRun': True})
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.json(), [{u'Mirror': mirror_name,
+                                        u'Retention': {u'KeepLast': 3, u'KeepWeekly': 2},
+                                        u'Dropped': [],
+                                        u'Kept': []}])
+
+        resp = self.post("/api/snapshots/prune", json={u'Mirrors': [mirror_name], u'Retention': {u'KeepDaily': 1}})
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.json()[0]['Retention'], {u'KeepDaily': 1})
+
+        resp = self.post("/api/snapshots/prune", json={u'Mirrors': [self.random_name()]})
+        self.check_equal(resp.status_code, 404)
+
+        resp = self.post("/api/snapshots/prune", json={u'Retention': {u'KeepLast': -1}})
+        self.check_equal(resp.status_code, 400)
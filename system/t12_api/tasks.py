@@ -0,0 +1,78 @@
+from api_lib import APITest
+from publish import DefaultSigningOptions
+
+
+class TaskAPITestList(APITest):
+    """
+    GET /tasks, GET /tasks/:id, GET /tasks/:id/wait, GET /tasks/:id/output,
+    GET /tasks/:id/return_value, DELETE /tasks/:id, POST /tasks-clear, GET /tasks-wait
+    """
+    fixtureGpg = True
+
+    def check(self):
+        repo_name = self.random_name()
+        self.check_equal(self.post(
+            "/api/repos", json={"Name": repo_name, "DefaultDistribution": "wheezy"}).status_code, 201)
+
+        d = self.random_name()
+        self.check_equal(self.upload("/api/files/" + d,
+                                     "libboost-program-options-dev_1.49.0.1_i386.deb").status_code, 200)
+
+        resp = self.post("/api/repos/" + repo_name + "/file/" + d + "?_async=true")
+        self.check_equal(resp.status_code, 202)
+        add_task = resp.json()
+        self.check_equal(add_task['Name'], "Add packages from " + d + " to repo " + repo_name)
+
+        prefix = self.random_name()
+        resp = self.post("/api/publish/" + prefix + "?_async=true",
+                         json={
+                             "SourceKind": "local",
+                             "Sources": [{"Name": repo_name}],
+                             "Signing": DefaultSigningOptions,
+                         })
+        self.check_equal(resp.status_code, 202)
+        publish_task = resp.json()
+        self.check_equal(publish_task['State'], 0)
+
+        resp = self.get("/api/tasks/" + str(publish_task['ID']) + "/wait")
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.json()['State'], 2)
+
+        self.check_equal(self.get("/api/tasks/" + str(add_task['ID'])).json()['State'], 2)
+
+        resp = self.get("/api/tasks/" + str(publish_task['ID']) + "/return_value")
+        self.check_equal(resp.status_code, 200)
+        self.check_equal(resp.json()['Prefix'], prefix)
+        self.check_equal(resp.json()['Distribution'], 'wheezy')
+
+        resp = self.get("/api/tasks/" + str(publish_task['ID']) + "/output")
+        self.check_equal(resp.status_code, 200)
+        self.check_in("Task succeeded", resp.json())
+
+        self.check_exists("public/" + prefix + "/dists/wheezy/Release")
+        self.check_exists(
+            "public/" + prefix + "/pool/main/b/boost-defaults/libboost-program-options-dev_1.49.0.1_i386.deb")
+
+        # task which fails
+        resp = self.post("/api/publish/" + prefix + "?_async=true",
+                         json={
+                             "SourceKind": "local",
+                             "Sources": [{"Name": repo_name}],
+                             "Signing": DefaultSigningOptions,
+                         })
+        self.check_equal(resp.status_code, 202)
+        failed_task = resp.json()
+
+        self.check_equal(self.get("/api/tasks-wait").status_code, 200)
+        self.check_equal(self.get("/api/tasks/" + str(failed_task['ID'])).json()['State'], 3)
+        self.check_in("prefix/distribution already used",
+                      self.get("/api/tasks/" + str(failed_task['ID']) + "/return_value").json()['Error'])
+
+        self.check_equal(len(self.get("/api/tasks").json()), 3)
+
+        self.check_equal(self.delete("/api/tasks/" + str(failed_task['ID'])).status_code, 200)
+        self.check_equal(self.get("/api/tasks/" + str(failed_task['ID'])).status_code, 404)
+        self.check_equal(self.get("/api/tasks/abc").status_code, 400)
+
+        self.check_equal(self.post("/api/tasks-clear").status_code, 200)
+        self.check_equal(self.get("/api/tasks").json(), [])
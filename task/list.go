@@ -0,0 +1,249 @@
+package task
+
+import (
+	"fmt"
+	"sync"
+)
+
+// List is handling list of processes and makes sure
+// only one process is executed at the time
+type List struct {
+	*sync.Mutex
+	cond    *sync.Cond
+	tasks   []*Task
+	queue   []*Task
+	lastID  int
+	stopped bool
+}
+
+// NewList creates empty task list
+func NewList() *List {
+	mutex := &sync.Mutex{}
+	list := &List{
+		Mutex: mutex,
+		cond:  sync.NewCond(mutex),
+		tasks: make([]*Task, 0),
+		queue: make([]*Task, 0),
+	}
+	return list
+}
+
+// GetTasks gets complete list of tasks
+func (list *List) GetTasks() []Task {
+	list.Lock()
+	defer list.Unlock()
+
+	tasks := make([]Task, len(list.tasks))
+	for i, task := range list.tasks {
+		tasks[i] = *task
+	}
+
+	return tasks
+}
+
+func (list *List) getTaskByID(ID int) (*Task, error) {
+	for _, task := range list.tasks {
+		if task.ID == ID {
+			return task, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not find task with id %v", ID)
+}
+
+// GetTaskByID returns task with given id
+func (list *List) GetTaskByID(ID int) (Task, error) {
+	list.Lock()
+	defer list.Unlock()
+
+	task, err := list.getTaskByID(ID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return *task, nil
+}
+
+// GetTaskOutputByID returns captured output of task with given id
+func (list *List) GetTaskOutputByID(ID int) (string, error) {
+	task, err := list.GetTaskByID(ID)
+	if err != nil {
+		return "", err
+	}
+
+	return task.output.String(), nil
+}
+
+// GetTaskReturnValueByID returns process return value of task with given id
+func (list *List) GetTaskReturnValueByID(ID int) (*ProcessReturnValue, error) {
+	task, err := list.GetTaskByID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !task.IsFinished() {
+		return nil, fmt.Errorf("task with id %v has not finished yet", ID)
+	}
+
+	return task.returnValue, nil
+}
+
+// DeleteTaskByID deletes given task from list. Running task can't be deleted.
+func (list *List) DeleteTaskByID(ID int) (Task, error) {
+	list.Lock()
+	defer list.Unlock()
+
+	task, err := list.getTaskByID(ID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if task.State == RUNNING {
+		return *task, fmt.Errorf("task with id %v is still running", ID)
+	}
+
+	if task.State == IDLE {
+		// task never got a chance to run, release anyone waiting for it
+		task.err = fmt.Errorf("task with id %v has been deleted", ID)
+		task.State = FAILED
+		close(task.done)
+	}
+
+	list.tasks = removeTask(list.tasks, task)
+	list.queue = removeTask(list.queue, task)
+
+	return *task, nil
+}
+
+// Clear removes all finished tasks from list
+func (list *List) Clear() {
+	list.Lock()
+	defer list.Unlock()
+
+	var tasks []*Task
+	for _, task := range list.tasks {
+		if !task.IsFinished() {
+			tasks = append(tasks, task)
+		}
+	}
+
+	list.tasks = tasks
+}
+
+// RunTaskInBackground puts process into the queue, it will be executed
+// as soon as all the tasks queued before it are finished
+func (list *List) RunTaskInBackground(name string, process Process) Task {
+	list.Lock()
+	defer list.Unlock()
+
+	list.lastID++
+	task := NewTask(process, name, list.lastID)
+
+	list.tasks = append(list.tasks, task)
+	list.queue = append(list.queue, task)
+	list.cond.Signal()
+
+	return *task
+}
+
+// Next blocks until there's a task waiting in the queue and returns it,
+// nil is returned when list has been stopped
+func (list *List) Next() *Task {
+	list.Lock()
+	defer list.Unlock()
+
+	for len(list.queue) == 0 && !list.stopped {
+		list.cond.Wait()
+	}
+
+	if list.stopped {
+		return nil
+	}
+
+	task := list.queue[0]
+	list.queue = list.queue[1:]
+
+	return task
+}
+
+// Stop wakes up all the workers waiting in Next, no more tasks
+// would be returned by Next afterwards
+func (list *List) Stop() {
+	list.Lock()
+	defer list.Unlock()
+
+	list.stopped = true
+	list.cond.Broadcast()
+}
+
+// Run executes process of the task, updating its state
+func (list *List) Run(task *Task) {
+	list.Lock()
+	task.State = RUNNING
+	list.Unlock()
+
+	returnValue, err := task.process(task.output)
+
+	list.finish(task, returnValue, err)
+}
+
+// Fail marks task as failed without running it
+func (list *List) Fail(task *Task, err error) {
+	list.finish(task, nil, err)
+}
+
+func (list *List) finish(task *Task, returnValue *ProcessReturnValue, err error) {
+	list.Lock()
+	defer list.Unlock()
+
+	task.returnValue = returnValue
+	task.err = err
+
+	if err != nil {
+		task.output.Printf("Task failed with error: %v\n", err)
+		task.State = FAILED
+	} else {
+		task.output.Printf("Task succeeded\n")
+		task.State = SUCCEEDED
+	}
+
+	close(task.done)
+}
+
+// WaitForTaskByID waits for task with given id to be finished
+func (list *List) WaitForTaskByID(ID int) (Task, error) {
+	list.Lock()
+	task, err := list.getTaskByID(ID)
+	list.Unlock()
+
+	if err != nil {
+		return Task{}, err
+	}
+
+	<-task.done
+
+	return list.GetTaskByID(ID)
+}
+
+// Wait waits till all the tasks currently in the list are finished
+func (list *List) Wait() {
+	list.Lock()
+	tasks := make([]*Task, len(list.tasks))
+	copy(tasks, list.tasks)
+	list.Unlock()
+
+	for _, task := range tasks {
+		<-task.done
+	}
+}
+
+func removeTask(tasks []*Task, task *Task) []*Task {
+	result := make([]*Task, 0, len(tasks))
+	for _, t := range tasks {
+		if t != task {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,112 @@
+package task_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/task"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ListSuite struct{}
+
+var _ = Suite(&ListSuite{})
+
+func (s *ListSuite) TestList(c *C) {
+	list := task.NewList()
+	c.Check(list.GetTasks(), HasLen, 0)
+
+	t := list.RunTaskInBackground("Successful task", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		out.Printf("Hello %s\n", "world")
+		return &task.ProcessReturnValue{Code: 201, Value: "created"}, nil
+	})
+	c.Check(t.ID, Equals, 1)
+	c.Check(t.Name, Equals, "Successful task")
+	c.Check(t.State, Equals, task.IDLE)
+
+	_, err := list.GetTaskReturnValueByID(1)
+	c.Check(err, ErrorMatches, "task with id 1 has not finished yet")
+
+	t = list.RunTaskInBackground("Faulty task", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		out.ColoredPrintf("@{r}Failing@|")
+		return &task.ProcessReturnValue{Code: 500}, errors.New("boom")
+	})
+	c.Check(t.ID, Equals, 2)
+
+	go func() {
+		for t := list.Next(); t != nil; t = list.Next() {
+			list.Run(t)
+		}
+	}()
+	defer list.Stop()
+
+	list.Wait()
+
+	t, err = list.GetTaskByID(1)
+	c.Assert(err, IsNil)
+	c.Check(t.State, Equals, task.SUCCEEDED)
+
+	output, err := list.GetTaskOutputByID(1)
+	c.Assert(err, IsNil)
+	c.Check(output, Equals, "Hello world\nTask succeeded\n")
+
+	value, err := list.GetTaskReturnValueByID(1)
+	c.Assert(err, IsNil)
+	c.Check(value, DeepEquals, &task.ProcessReturnValue{Code: 201, Value: "created"})
+
+	t, err = list.WaitForTaskByID(2)
+	c.Assert(err, IsNil)
+	c.Check(t.State, Equals, task.FAILED)
+	c.Check(t.Err(), ErrorMatches, "boom")
+
+	output, _ = list.GetTaskOutputByID(2)
+	c.Check(output, Equals, "Failing\nTask failed with error: boom\n")
+
+	_, err = list.GetTaskByID(3)
+	c.Check(err, ErrorMatches, "could not find task with id 3")
+
+	_, err = list.DeleteTaskByID(1)
+	c.Check(err, IsNil)
+	c.Check(list.GetTasks(), HasLen, 1)
+
+	list.Clear()
+	c.Check(list.GetTasks(), HasLen, 0)
+}
+
+func (s *ListSuite) TestDeleteIdle(c *C) {
+	list := task.NewList()
+
+	t := list.RunTaskInBackground("Idle task", func(out aptly.Progress) (*task.ProcessReturnValue, error) {
+		return nil, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		list.Wait()
+		close(done)
+	}()
+
+	t, err := list.DeleteTaskByID(t.ID)
+	c.Assert(err, IsNil)
+	c.Check(t.State, Equals, task.FAILED)
+
+	<-done
+	c.Check(list.GetTasks(), HasLen, 0)
+
+	list.Stop()
+	c.Check(list.Next(), IsNil)
+}
+
+func (s *ListSuite) TestOutput(c *C) {
+	out := task.NewOutput()
+	out.ColoredPrintf("@{y}Warning@|: @{r}%s@{!}y@| @@", "x")
+	out.PrintfStdErr("error\n")
+	c.Check(out.String(), Equals, "Warning: xy @\nerror\n")
+}
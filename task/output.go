@@ -0,0 +1,129 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// Output is a progress implementation which captures all the output
+// of the task into memory buffer
+type Output struct {
+	mu     sync.Mutex
+	output *bytes.Buffer
+}
+
+// Check interface
+var (
+	_ aptly.Progress = (*Output)(nil)
+)
+
+// NewOutput creates new output
+func NewOutput() *Output {
+	return &Output{output: &bytes.Buffer{}}
+}
+
+// String returns captured output
+func (t *Output) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.output.String()
+}
+
+// Write is used to determine how many bytes have been written
+// not needed in our case
+func (t *Output) Write(p []byte) (n int, err error) {
+	return len(p), err
+}
+
+// Start is needed for progress interface
+func (t *Output) Start() {
+	// Not implemented
+}
+
+// Shutdown is needed for progress interface
+func (t *Output) Shutdown() {
+	// Not implemented
+}
+
+// Flush is needed for progress interface
+func (t *Output) Flush() {
+	// Not implemented
+}
+
+// InitBar is needed for progress interface
+func (t *Output) InitBar(count int64, isBytes bool) {
+	// Not implemented
+}
+
+// ShutdownBar is needed for progress interface
+func (t *Output) ShutdownBar() {
+	// Not implemented
+}
+
+// AddBar is needed for progress interface
+func (t *Output) AddBar(count int) {
+	// Not implemented
+}
+
+// SetBar sets current position for progress bar
+func (t *Output) SetBar(count int) {
+	// Not implemented
+}
+
+// Printf does printf but in safe manner to output
+func (t *Output) Printf(msg string, a ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintf(t.output, msg, a...)
+}
+
+// ColoredPrintf does printf with color marks stripped + newline
+func (t *Output) ColoredPrintf(msg string, a ...interface{}) {
+	t.Printf(stripColorMarks(msg)+"\n", a...)
+}
+
+// PrintfStdErr does printf but in safe manner to output
+func (t *Output) PrintfStdErr(msg string, a ...interface{}) {
+	t.Printf(msg, a...)
+}
+
+// stripColorMarks removes color marks like @{r} or @| from the message
+func stripColorMarks(msg string) string {
+	var inColorMark, inCurly bool
+
+	return strings.Map(func(r rune) rune {
+		if inColorMark {
+			if inCurly {
+				if r == '}' {
+					inCurly = false
+					inColorMark = false
+				}
+				return -1
+			}
+
+			if r == '{' {
+				inCurly = true
+				return -1
+			} else if r == '@' {
+				inColorMark = false
+				return '@'
+			}
+
+			inColorMark = false
+			return -1
+		}
+
+		if r == '@' {
+			inColorMark = true
+			return -1
+		}
+
+		return r
+	}, msg)
+}
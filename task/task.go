@@ -0,0 +1,75 @@
+// Package task provides background task queue used by aptly REST API
+package task
+
+import (
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// State task is in
+type State int
+
+// ProcessReturnValue represents the result of task process:
+// HTTP status code and value to be returned to the client
+type ProcessReturnValue struct {
+	Code  int
+	Value interface{}
+}
+
+// Process is a function implementing the actual task logic
+type Process func(out aptly.Progress) (*ProcessReturnValue, error)
+
+const (
+	// IDLE when task is waiting
+	IDLE State = iota
+	// RUNNING when task is running
+	RUNNING
+	// SUCCEEDED when task is successfully finished
+	SUCCEEDED
+	// FAILED when task failed
+	FAILED
+)
+
+// Task represents as task in a queue encapsulates process code
+type Task struct {
+	output      *Output
+	process     Process
+	returnValue *ProcessReturnValue
+	err         error
+	done        chan struct{}
+	Name        string
+	ID          int
+	State       State
+}
+
+// NewTask creates new task
+func NewTask(process Process, name string, ID int) *Task {
+	task := &Task{
+		output:  NewOutput(),
+		process: process,
+		done:    make(chan struct{}),
+		Name:    name,
+		ID:      ID,
+		State:   IDLE,
+	}
+	return task
+}
+
+// Output returns progress capturing task output
+func (t *Task) Output() *Output {
+	return t.output
+}
+
+// ReturnValue returns result of the process once task is finished
+func (t *Task) ReturnValue() *ProcessReturnValue {
+	return t.returnValue
+}
+
+// Err returns error which made task fail (if any)
+func (t *Task) Err() error {
+	return t.err
+}
+
+// IsFinished returns true if task has either succeeded or failed
+func (t *Task) IsFinished() bool {
+	return t.State == SUCCEEDED || t.State == FAILED
+}
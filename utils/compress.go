@@ -2,32 +2,112 @@ package utils
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"os"
-	"os/exec"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression formats for published indexes
+const (
+	CompressionUncompressed = "uncompressed"
+	CompressionGzip         = "gz"
+	CompressionBzip2        = "bz2"
+	CompressionXz           = "xz"
+	CompressionZstd         = "zst"
 )
 
-// CompressFile compresses file specified by source to .gz & .bz2
-//
-// It uses internal gzip and external bzip2, see:
-// https://code.google.com/p/go/issues/detail?id=4828
-func CompressFile(source *os.File, onlyGzip bool) error {
-	gzPath := source.Name() + ".gz"
-	gzFile, err := os.Create(gzPath)
+// DefaultCompression is the list of formats published indexes are produced in
+// when nothing else is configured
+var DefaultCompression = []string{CompressionUncompressed, CompressionGzip, CompressionBzip2}
+
+// CompressionExtension returns file extension for compression format
+func CompressionExtension(format string) string {
+	if format == CompressionUncompressed {
+		return ""
+	}
+	return "." + format
+}
+
+// ValidateCompression checks that every format in the list is supported
+func ValidateCompression(formats []string) error {
+	if len(formats) == 0 {
+		return fmt.Errorf("list of compression formats is empty")
+	}
+
+	for _, format := range formats {
+		switch format {
+		case CompressionUncompressed, CompressionGzip, CompressionBzip2, CompressionXz, CompressionZstd:
+		default:
+			return fmt.Errorf("unsupported compression format %q, supported: %s, %s, %s, %s, %s", format,
+				CompressionUncompressed, CompressionGzip, CompressionBzip2, CompressionXz, CompressionZstd)
+		}
+	}
+
+	return nil
+}
+
+// CompressFile compresses file specified by source to every compressed format
+// from the list, results are stored next to source with respective extension
+func CompressFile(source *os.File, formats []string) error {
+	for _, format := range formats {
+		if format == CompressionUncompressed {
+			continue
+		}
+
+		err := compressFileTo(source, format)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressFileTo compresses source into single format
+func compressFileTo(source *os.File, format string) error {
+	output, err := os.Create(source.Name() + CompressionExtension(format))
 	if err != nil {
 		return err
 	}
-	defer gzFile.Close()
+	defer output.Close()
+
+	var writer io.WriteCloser
+
+	switch format {
+	case CompressionGzip:
+		writer = gzip.NewWriter(output)
+	case CompressionBzip2:
+		writer, err = bzip2.NewWriter(output, &bzip2.WriterConfig{Level: bzip2.BestCompression})
+	case CompressionXz:
+		writer, err = xz.NewWriter(output)
+	case CompressionZstd:
+		writer, err = zstd.NewWriter(output)
+	default:
+		err = fmt.Errorf("unsupported compression format %q", format)
+	}
 
-	gzWriter := gzip.NewWriter(gzFile)
-	defer gzWriter.Close()
+	if err != nil {
+		return err
+	}
 
-	source.Seek(0, 0)
-	_, err = io.Copy(gzWriter, source)
-	if err != nil || onlyGzip {
+	_, err = source.Seek(0, 0)
+	if err == nil {
+		_, err = io.Copy(writer, source)
+	}
+
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command("bzip2", "-k", "-f", source.Name())
-	return cmd.Run()
+	return output.Close()
 }
@@ -3,9 +3,12 @@ package utils
 import (
 	"compress/bzip2"
 	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
 	. "gopkg.in/check.v1"
 )
 
@@ -26,33 +29,62 @@ func (s *CompressSuite) TearDownTest(c *C) {
 	s.tempfile.Close()
 }
 
-func (s *CompressSuite) TestCompress(c *C) {
-	err := CompressFile(s.tempfile, false)
+func (s *CompressSuite) readCompressed(c *C, ext string, decompress func(io.Reader) (io.Reader, error)) string {
+	file, err := os.Open(s.tempfile.Name() + ext)
 	c.Assert(err, IsNil)
+	defer file.Close()
 
-	file, err := os.Open(s.tempfile.Name() + ".gz")
+	reader, err := decompress(file)
 	c.Assert(err, IsNil)
 
-	gzReader, err := gzip.NewReader(file)
+	buf, err := ioutil.ReadAll(reader)
 	c.Assert(err, IsNil)
 
-	buf, err := ioutil.ReadAll(gzReader)
+	return string(buf)
+}
+
+func (s *CompressSuite) TestCompress(c *C) {
+	err := CompressFile(s.tempfile, []string{CompressionUncompressed, CompressionGzip, CompressionBzip2, CompressionXz, CompressionZstd})
 	c.Assert(err, IsNil)
 
-	gzReader.Close()
-	file.Close()
+	c.Check(s.readCompressed(c, ".gz", func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	}), Equals, testString)
 
-	c.Check(string(buf), Equals, testString)
+	c.Check(s.readCompressed(c, ".bz2", func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	}), Equals, testString)
 
-	file, err = os.Open(s.tempfile.Name() + ".bz2")
-	c.Assert(err, IsNil)
+	c.Check(s.readCompressed(c, ".xz", func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	}), Equals, testString)
 
-	bzReader := bzip2.NewReader(file)
+	c.Check(s.readCompressed(c, ".zst", func(r io.Reader) (io.Reader, error) {
+		return zstd.NewReader(r)
+	}), Equals, testString)
+}
 
-	_, err = bzReader.Read(buf)
+func (s *CompressSuite) TestCompressOnlyListed(c *C) {
+	err := CompressFile(s.tempfile, []string{CompressionGzip})
 	c.Assert(err, IsNil)
 
-	file.Close()
+	_, err = os.Stat(s.tempfile.Name() + ".gz")
+	c.Check(err, IsNil)
+
+	for _, ext := range []string{".bz2", ".xz", ".zst"} {
+		_, err = os.Stat(s.tempfile.Name() + ext)
+		c.Check(os.IsNotExist(err), Equals, true)
+	}
+}
+
+func (s *CompressSuite) TestValidateCompression(c *C) {
+	c.Check(ValidateCompression(DefaultCompression), IsNil)
+	c.Check(ValidateCompression([]string{"zst", "xz"}), IsNil)
+	c.Check(ValidateCompression(nil), ErrorMatches, "list of compression formats is empty")
+	c.Check(ValidateCompression([]string{"gz", "lzma"}), ErrorMatches, "unsupported compression format \"lzma\".*")
+}
 
-	c.Check(string(buf), Equals, testString)
+func (s *CompressSuite) TestCompressionExtension(c *C) {
+	c.Check(CompressionExtension(CompressionUncompressed), Equals, "")
+	c.Check(CompressionExtension(CompressionZstd), Equals, ".zst")
 }
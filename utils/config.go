@@ -12,7 +12,9 @@ type ConfigStructure struct { // nolint: maligned
 	DownloadConcurrency    int                              `json:"downloadConcurrency"`
 	DownloadLimit          int64                            `json:"downloadSpeedLimit"`
 	DownloadRetries        int                              `json:"downloadRetries"`
+	LocalArchiveRoots      []string                         `json:"localArchiveRoots"`
 	DatabaseOpenAttempts   int                              `json:"databaseOpenAttempts"`
+	DatabaseBackend        string                           `json:"databaseBackend"`
 	Architectures          []string                         `json:"architectures"`
 	DepFollowSuggests      bool                             `json:"dependencyFollowSuggests"`
 	DepFollowRecommends    bool                             `json:"dependencyFollowRecommends"`
@@ -27,9 +29,11 @@ type ConfigStructure struct { // nolint: maligned
 	PpaDistributorID       string                           `json:"ppaDistributorID"`
 	PpaCodename            string                           `json:"ppaCodename"`
 	SkipContentsPublishing bool                             `json:"skipContentsPublishing"`
+	PublishCompression     []string                         `json:"publishCompression"`
 	FileSystemPublishRoots map[string]FileSystemPublishRoot `json:"FileSystemPublishEndpoints"`
 	S3PublishRoots         map[string]S3PublishRoot         `json:"S3PublishEndpoints"`
 	SwiftPublishRoots      map[string]SwiftPublishRoot      `json:"SwiftPublishEndpoints"`
+	APIAuth                APIAuthConfig                    `json:"apiAuth"`
 }
 
 // FileSystemPublishRoot describes single filesystem publishing entry point
@@ -72,12 +76,36 @@ type SwiftPublishRoot struct {
 	Container      string `json:"container"`
 }
 
+// APIAuthConfig describes authentication & authorization for aptly REST API
+//
+// Authentication is enabled as soon as any token or user is configured
+type APIAuthConfig struct {
+	// Tokens maps identity to static bearer token
+	Tokens map[string]string `json:"tokens"`
+	// Users maps user name to bcrypt hash of the password (HTTP basic auth)
+	Users map[string]string `json:"users"`
+	// Permissions limit what identity is allowed to do, identities
+	// not listed here have full access
+	Permissions map[string][]APIPermission `json:"permissions"`
+}
+
+// APIPermission allows list of HTTP methods on list of resources
+type APIPermission struct {
+	// Methods is a list of HTTP methods (GET, POST, ...), "*" for any method
+	Methods []string `json:"methods"`
+	// Resources is a list of resource paths relative to /api/, path segments
+	// are matched as glob patterns, e.g. "repos/ci-*"
+	Resources []string `json:"resources"`
+}
+
 // Config is configuration for aptly, shared by all modules
 var Config = ConfigStructure{
 	RootDir:                filepath.Join(os.Getenv("HOME"), ".aptly"),
 	DownloadConcurrency:    4,
 	DownloadLimit:          0,
+	LocalArchiveRoots:      []string{},
 	DatabaseOpenAttempts:   -1,
+	DatabaseBackend:        "goleveldb",
 	Architectures:          []string{},
 	DepFollowSuggests:      false,
 	DepFollowRecommends:    false,
@@ -90,9 +118,15 @@ var Config = ConfigStructure{
 	SkipLegacyPool:         false,
 	PpaDistributorID:       "ubuntu",
 	PpaCodename:            "",
+	PublishCompression:     DefaultCompression,
 	FileSystemPublishRoots: map[string]FileSystemPublishRoot{},
 	S3PublishRoots:         map[string]S3PublishRoot{},
 	SwiftPublishRoots:      map[string]SwiftPublishRoot{},
+	APIAuth: APIAuthConfig{
+		Tokens:      map[string]string{},
+		Users:       map[string]string{},
+		Permissions: map[string][]APIPermission{},
+	},
 }
 
 // LoadConfig loads configuration from json file
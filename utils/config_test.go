@@ -60,7 +60,9 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"  \"downloadConcurrency\": 5,\n"+
 		"  \"downloadSpeedLimit\": 0,\n"+
 		"  \"downloadRetries\": 0,\n"+
+		"  \"localArchiveRoots\": null,\n"+
 		"  \"databaseOpenAttempts\": 5,\n"+
+		"  \"databaseBackend\": \"\",\n"+
 		"  \"architectures\": null,\n"+
 		"  \"dependencyFollowSuggests\": false,\n"+
 		"  \"dependencyFollowRecommends\": false,\n"+
@@ -75,6 +77,7 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"  \"ppaDistributorID\": \"\",\n"+
 		"  \"ppaCodename\": \"\",\n"+
 		"  \"skipContentsPublishing\": false,\n"+
+		"  \"publishCompression\": null,\n"+
 		"  \"FileSystemPublishEndpoints\": {\n"+
 		"    \"test\": {\n"+
 		"      \"rootDir\": \"/opt/aptly-publish\",\n"+
@@ -114,6 +117,11 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"      \"prefix\": \"\",\n"+
 		"      \"container\": \"repo\"\n"+
 		"    }\n"+
+		"  },\n"+
+		"  \"apiAuth\": {\n"+
+		"    \"tokens\": null,\n"+
+		"    \"users\": null,\n"+
+		"    \"permissions\": null\n"+
 		"  }\n"+
 		"}")
 }